@@ -0,0 +1,193 @@
+package catalog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry 一个去重后的系统提示词
+type Entry struct {
+	Index     int       `json:"index"` // 序号，从1开始
+	Hash      string    `json:"hash"`  // 提示词的sha256
+	Prompt    string    `json:"prompt"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+	Models    []string  `json:"models"`
+	Clients   []string  `json:"clients"` // 发起请求的客户端，通常是User-Agent
+}
+
+// FileName 导出时的文件名
+func (e *Entry) FileName() string {
+	return fmt.Sprintf("%03d-%s.md", e.Index, e.Hash[:12])
+}
+
+// Hash 计算提示词的hash，忽略前后空白
+func Hash(prompt string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(prompt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Catalog 系统提示词目录，可以并发调用
+type Catalog struct {
+	mutex   sync.Mutex
+	entries []*Entry
+	hashes  map[string]*Entry
+	dirty   map[string]bool // 上次导出之后新增或者变化的提示词hash
+}
+
+// New 创建系统提示词目录
+func New() *Catalog {
+	return &Catalog{hashes: make(map[string]*Entry), dirty: make(map[string]bool)}
+}
+
+// Add 记录一次出现，返回记录后的副本，seen表示之前已经出现过
+func (c *Catalog) Add(prompt, model, client string, t time.Time) (entry Entry, seen bool) {
+	hash := Hash(prompt)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, seen := c.hashes[hash]
+	if !seen {
+		e = &Entry{
+			Index:     len(c.entries) + 1,
+			Hash:      hash,
+			Prompt:    strings.TrimSpace(prompt),
+			FirstSeen: t,
+		}
+		c.entries = append(c.entries, e)
+		c.hashes[hash] = e
+	}
+	e.Count++
+	if t.After(e.LastSeen) {
+		e.LastSeen = t
+	}
+	if model != "" && !slices.Contains(e.Models, model) {
+		e.Models = append(e.Models, model)
+	}
+	if client != "" && !slices.Contains(e.Clients, client) {
+		e.Clients = append(e.Clients, client)
+	}
+	c.dirty[hash] = true
+	return e.clone(), seen
+}
+
+func (e *Entry) clone() Entry {
+	c := *e
+	c.Models = slices.Clone(e.Models)
+	c.Clients = slices.Clone(e.Clients)
+	return c
+}
+
+// Entries 所有的系统提示词，按首次出现的顺序
+func (c *Catalog) Entries() []Entry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entries := make([]Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e.clone())
+	}
+	return entries
+}
+
+// fence 返回比文本中最长的连续反引号更长的代码块标记
+func fence(s string) string {
+	longest, n := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			n++
+			longest = max(longest, n)
+		} else {
+			n = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// Markdown 导出为markdown
+func (e *Entry) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# System prompt #%d\n\n", e.Index)
+	fmt.Fprintf(&b, "- Hash: `%s`\n", e.Hash)
+	fmt.Fprintf(&b, "- First seen: %s\n", e.FirstSeen.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Last seen: %s\n", e.LastSeen.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Count: %d\n", e.Count)
+	fmt.Fprintf(&b, "- Models: %s\n", strings.Join(e.Models, ", "))
+	fmt.Fprintf(&b, "- Clients: %s\n", strings.Join(e.Clients, ", "))
+	fmt.Fprintf(&b, "- Length: %d characters\n\n", len([]rune(e.Prompt)))
+	f := fence(e.Prompt)
+	fmt.Fprintf(&b, "%stext\n%s\n%s\n", f, e.Prompt, f)
+	return b.String()
+}
+
+// Export 把每个系统提示词导出为dir下的一个markdown文件，并生成index.md索引
+func (c *Catalog) Export(dir string) error {
+	c.mutex.Lock()
+	c.dirty = make(map[string]bool)
+	c.mutex.Unlock()
+	return c.write(dir, c.Entries())
+}
+
+// Flush 只导出上次导出之后新增或者变化的系统提示词，并更新index.md，没有变化时什么都不做
+func (c *Catalog) Flush(dir string) error {
+	c.mutex.Lock()
+	var changed []Entry
+	for _, e := range c.entries {
+		if c.dirty[e.Hash] {
+			changed = append(changed, e.clone())
+		}
+	}
+	c.dirty = make(map[string]bool)
+	c.mutex.Unlock()
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if err := c.write(dir, changed); err != nil {
+		// 下次再试
+		c.mutex.Lock()
+		for _, e := range changed {
+			c.dirty[e.Hash] = true
+		}
+		c.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// write 导出entries，index.md总是包含所有的系统提示词
+func (c *Catalog) write(dir string, entries []Entry) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.WriteFile(filepath.Join(dir, e.FileName()), []byte(e.Markdown()), 0o644); err != nil {
+			return err
+		}
+	}
+
+	var index strings.Builder
+	index.WriteString("# System prompts\n\n")
+	index.WriteString("| # | Count | First seen | Last seen | Models | Clients | File |\n")
+	index.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, e := range c.Entries() {
+		fmt.Fprintf(&index, "| %d | %d | %s | %s | %s | %s | [%s](%s) |\n",
+			e.Index, e.Count, e.FirstSeen.Format(time.RFC3339), e.LastSeen.Format(time.RFC3339),
+			tableCell(strings.Join(e.Models, ", ")), tableCell(strings.Join(e.Clients, ", ")), e.FileName(), e.FileName())
+	}
+	return os.WriteFile(filepath.Join(dir, "index.md"), []byte(index.String()), 0o644)
+}
+
+// tableCell 转义markdown表格中的竖线
+func tableCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
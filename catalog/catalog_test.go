@@ -0,0 +1,83 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCatalogAdd(t *testing.T) {
+	c := New()
+	now := time.Now()
+
+	e, seen := c.Add("You are a helper", "qwen3", "ollama-js", now)
+	if seen || e.Index != 1 || e.Count != 1 {
+		t.Fatal("first add", e, seen)
+	}
+	c.Add("Another prompt", "llama3", "", now)
+	e, seen = c.Add("  You are a helper\n", "llama3", "ollama-js", now.Add(time.Minute))
+	if !seen || e.Index != 1 || e.Count != 2 {
+		t.Fatal("second add", e, seen)
+	}
+	if strings.Join(e.Models, ",") != "qwen3,llama3" || len(e.Clients) != 1 {
+		t.Fatal(e.Models, e.Clients)
+	}
+	if !e.LastSeen.Equal(now.Add(time.Minute)) || !e.FirstSeen.Equal(now) {
+		t.Fatal(e.FirstSeen, e.LastSeen)
+	}
+	if entries := c.Entries(); len(entries) != 2 || entries[1].Index != 2 {
+		t.Fatal(entries)
+	}
+}
+
+func TestCatalogExport(t *testing.T) {
+	c := New()
+	e, _ := c.Add("Use ```go blocks``` for code", "qwen3", "curl/8.0", time.Now())
+
+	dir := t.TempDir()
+	if err := c.Export(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, e.FileName()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "````text\nUse ```go blocks``` for code\n````") {
+		t.Fatal(string(data))
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), e.FileName()) {
+		t.Fatal(string(index))
+	}
+}
+
+func TestCatalogFlush(t *testing.T) {
+	c := New()
+	dir := t.TempDir()
+	first, _ := c.Add("You are a helper", "qwen3", "", time.Now())
+	if err := c.Flush(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, first.FileName())); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有变化时不会重写
+	os.Remove(filepath.Join(dir, first.FileName()))
+	second, _ := c.Add("Another prompt", "qwen3", "", time.Now())
+	if err := c.Flush(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, first.FileName())); !os.IsNotExist(err) {
+		t.Fatal("unchanged prompt should not be written again")
+	}
+	index, _ := os.ReadFile(filepath.Join(dir, "index.md"))
+	if !strings.Contains(string(index), first.FileName()) || !strings.Contains(string(index), second.FileName()) {
+		t.Fatal(string(index))
+	}
+}
@@ -0,0 +1,159 @@
+package catalog
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/llmparser"
+)
+
+// ToolEntry 一个去重后的工具定义，同名工具定义变化时会产生新的记录
+type ToolEntry struct {
+	Name        string         `json:"name"`
+	Hash        string         `json:"hash"` // 工具定义的sha256
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+	FirstSeen   time.Time      `json:"first_seen"`
+	LastSeen    time.Time      `json:"last_seen"`
+	Count       int            `json:"count"`
+	Models      []string       `json:"models"`
+	Clients     []string       `json:"clients"`
+}
+
+// maxToolSets 最多记录多少个agent的工具集合，超过后丢弃最久没有请求的
+const maxToolSets = 1024
+
+// toolSet 一个agent最近一次的工具集合
+type toolSet struct {
+	tools []llmparser.LLMTool
+	used  uint64 // 最近一次使用的序号
+}
+
+// ToolCatalog 工具目录，同时记录每个客户端最近一次的工具集合，用于发现agent工具的变化，可以并发调用
+type ToolCatalog struct {
+	mutex    sync.Mutex
+	entries  []*ToolEntry
+	hashes   map[string]*ToolEntry
+	toolSets map[string]*toolSet // 工具集合的key -> 最近一次的工具集合
+	used     uint64
+	changed  bool // 上次导出之后是否有变化
+}
+
+// NewToolCatalog 创建工具目录
+func NewToolCatalog() *ToolCatalog {
+	return &ToolCatalog{
+		hashes:   make(map[string]*ToolEntry),
+		toolSets: make(map[string]*toolSet),
+	}
+}
+
+// Add 记录一次请求中的工具，返回相对同一个setKey上一次工具集合的变化，first表示这个setKey第一次声明工具
+// setKey用于区分不同的agent，同一个User-Agent下可能有多个进程，为空时使用client
+func (c *ToolCatalog) Add(tools []llmparser.LLMTool, model, client, setKey string, t time.Time) (diff llmparser.ToolsDiff, first bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(tools) > 0 {
+		c.changed = true
+	}
+	for _, tool := range tools {
+		hash := Hash(tool.ToolDefinition())
+		e, ok := c.hashes[hash]
+		if !ok {
+			e = &ToolEntry{
+				Name:        tool.Function.Name,
+				Hash:        hash,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+				FirstSeen:   t,
+			}
+			c.entries = append(c.entries, e)
+			c.hashes[hash] = e
+		}
+		e.Count++
+		if t.After(e.LastSeen) {
+			e.LastSeen = t
+		}
+		if model != "" && !slices.Contains(e.Models, model) {
+			e.Models = append(e.Models, model)
+		}
+		if client != "" && !slices.Contains(e.Clients, client) {
+			e.Clients = append(e.Clients, client)
+		}
+	}
+
+	if setKey == "" {
+		setKey = client
+	}
+	c.used++
+	set, ok := c.toolSets[setKey]
+	if !ok {
+		c.evictToolSet()
+		set = &toolSet{}
+		c.toolSets[setKey] = set
+	}
+	previous := set.tools
+	set.tools, set.used = tools, c.used
+	return llmparser.DiffTools(previous, tools), !ok
+}
+
+// evictToolSet 工具集合太多时丢弃最久没有使用的
+func (c *ToolCatalog) evictToolSet() {
+	if len(c.toolSets) < maxToolSets {
+		return
+	}
+	oldest := ""
+	for key, set := range c.toolSets {
+		if oldest == "" || set.used < c.toolSets[oldest].used {
+			oldest = key
+		}
+	}
+	delete(c.toolSets, oldest)
+}
+
+// Entries 所有的工具定义，按首次出现的顺序
+func (c *ToolCatalog) Entries() []ToolEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entries := make([]ToolEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entry := *e
+		entry.Models = slices.Clone(e.Models)
+		entry.Clients = slices.Clone(e.Clients)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// ExportJSON 把所有的工具定义导出到json文件
+func (c *ToolCatalog) ExportJSON(path string) error {
+	c.mutex.Lock()
+	c.changed = false
+	c.mutex.Unlock()
+
+	data, err := json.MarshalIndent(c.Entries(), "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		c.mutex.Lock()
+		c.changed = true
+		c.mutex.Unlock()
+	}
+	return err
+}
+
+// Flush 上次导出之后有变化时重新导出到json文件
+func (c *ToolCatalog) Flush(path string) error {
+	c.mutex.Lock()
+	changed := c.changed
+	c.mutex.Unlock()
+	if !changed {
+		return nil
+	}
+	return c.ExportJSON(path)
+}
@@ -0,0 +1,85 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/llmparser"
+)
+
+func newTool(name, desc string) llmparser.LLMTool {
+	var tool llmparser.LLMTool
+	tool.Type = "function"
+	tool.Function.Name, tool.Function.Description = name, desc
+	return tool
+}
+
+func TestToolCatalog(t *testing.T) {
+	c := NewToolCatalog()
+	now := time.Now()
+
+	diff, first := c.Add([]llmparser.LLMTool{newTool("read", "read a file"), newTool("write", "write a file")}, "qwen3", "agent/1.0", "", now)
+	if !first || len(diff.Added) != 2 {
+		t.Fatal(first, diff)
+	}
+	diff, first = c.Add([]llmparser.LLMTool{newTool("read", "read a file"), newTool("write", "write a file")}, "qwen3", "agent/1.0", "", now)
+	if first || !diff.Empty() {
+		t.Fatal(first, diff)
+	}
+	diff, _ = c.Add([]llmparser.LLMTool{newTool("read", "read a text file"), newTool("shell", "run a command")}, "qwen3", "agent/1.0", "", now)
+	if strings.Join(llmparser.ToolNames(diff.Added), ",") != "shell" ||
+		strings.Join(llmparser.ToolNames(diff.Removed), ",") != "write" ||
+		strings.Join(llmparser.ToolNames(diff.Changed), ",") != "read" {
+		t.Fatal(diff)
+	}
+	// 其他客户端有自己的工具集合
+	if _, first = c.Add(nil, "qwen3", "other", "", now); !first {
+		t.Fatal("other client should be first")
+	}
+	// 同一个User-Agent下的其他进程也有自己的工具集合
+	if diff, first = c.Add([]llmparser.LLMTool{newTool("read", "read a text file")}, "qwen3", "agent/1.0", "agent/1.0|node[42]|", now); !first || len(diff.Added) != 1 {
+		t.Fatal("other process should be first", diff)
+	}
+
+	entries := c.Entries()
+	if len(entries) != 4 || entries[0].Count != 2 {
+		t.Fatal(entries)
+	}
+
+	path := filepath.Join(t.TempDir(), "tools.json")
+	if err := c.ExportJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []ToolEntry
+	if err = json.Unmarshal(data, &exported); err != nil || len(exported) != 4 || exported[3].Name != "shell" {
+		t.Fatal(err, string(data))
+	}
+}
+
+func TestToolCatalogEviction(t *testing.T) {
+	c := NewToolCatalog()
+	tools := []llmparser.LLMTool{newTool("read", "read a file")}
+	for i := 0; i < maxToolSets+10; i++ {
+		c.Add(tools, "qwen3", "agent/1.0", fmt.Sprint(i), time.Now())
+		// 一直使用的不会被丢弃
+		c.Add(tools, "qwen3", "agent/1.0", "main", time.Now())
+	}
+	if len(c.toolSets) != maxToolSets {
+		t.Fatalf("expected %d tool sets, got %d", maxToolSets, len(c.toolSets))
+	}
+	if _, first := c.Add(tools, "qwen3", "agent/1.0", "main", time.Now()); first {
+		t.Fatal("recently used tool set should be kept")
+	}
+	if _, ok := c.toolSets["0"]; ok {
+		t.Fatal("oldest tool set should be evicted")
+	}
+}
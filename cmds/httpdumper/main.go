@@ -4,23 +4,57 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"github.com/LubyRuffy/localdumper/displayfilter"
 	"github.com/LubyRuffy/localdumper/httpdumper"
-	"github.com/google/gopacket"
+	"github.com/LubyRuffy/localdumper/jsonl"
+	"log"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 )
 
-func parseConfig() *httpdumper.Config {
+func parseConfig() (*httpdumper.Config, httpdumper.Notifier) {
 	var cfg httpdumper.Config
+	var displayFilter, output string
 	flag.StringVar(&cfg.Device, "i", "", "Network interface to capture packets from. (e.g., en0, eth0)")
 	flag.StringVar(&cfg.PcapFile, "r", "", "Pcap file to read packets from.")
+	flag.BoolVar(&cfg.Realtime, "realtime", false, "Replay the pcap file with its original pacing instead of full speed.")
 	flag.StringVar(&cfg.BPFFilter, "f", "tcp", "BPF filter for capturing packets. Use 'tcp' for all TCP traffic.")
 	//flag.IntVar(&cfg.SnapLen, "s", -1, "SnapLen for pcap packet capture.")
 	flag.BoolVar(&cfg.PromiscuousMode, "p", false, "Set interface to promiscuous mode.")
+	flag.BoolVar(&cfg.ResolveProcess, "P", false, "Resolve the local process owning each connection. (linux only)")
+	flag.StringVar(&output, "output", "text", "Output format: text or jsonl.")
+	flag.StringVar(&displayFilter, "Y", "", "Display filter expression. (e.g., 'path ~ \"/api\" && status >= 400')")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nDisplay filter fields:\n%s", displayfilter.Fields())
+	}
 	flag.Parse()
-	return &cfg
+
+	if cfg.ResolveProcess && !httpdumper.ProcessSupported {
+		log.Println("-P is only supported on linux, ignored")
+		cfg.ResolveProcess = false
+	}
+
+	var n httpdumper.Notifier
+	switch output {
+	case "text":
+		n = &Notifier{}
+	case "jsonl":
+		n = jsonl.NewNotifier(jsonl.NewWriter(os.Stdout))
+	default:
+		log.Fatalln("unknown output format:", output)
+	}
+	if displayFilter != "" {
+		filter, err := displayfilter.Compile(displayFilter)
+		if err != nil {
+			log.Fatalln("invalid display filter:", err)
+		}
+		n = displayfilter.NewNotifier(filter, n)
+	}
+	return &cfg, n
 }
 
 type Notifier struct {
@@ -59,15 +93,18 @@ func (n *Notifier) OnResponse(resp *httpdumper.Response) {
 	fmt.Println(strings.Repeat("<", 58))
 }
 
-func (n *Notifier) OnTcpSession(id string, net, transport gopacket.Flow) {
-	fmt.Printf("New TCP session: %s\n", id)
+func (n *Notifier) OnTcpSession(session *httpdumper.TcpSession) {
+	fmt.Printf("New TCP session: %s\n", session.ID)
+	if session.SrcProcess != nil || session.DstProcess != nil {
+		fmt.Printf("    Process: %s -> %s\n", session.SrcProcess, session.DstProcess)
+	}
 }
 
 func main() {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
-	hd := httpdumper.New(parseConfig(), &Notifier{})
+	hd := httpdumper.New(parseConfig())
 	doneChan := make(chan struct{}, 1)
 	go func() {
 		defer close(doneChan)
@@ -76,8 +113,12 @@ func main() {
 		}
 	}()
 
-	<-signalChan
-	fmt.Println("\nReceived interrupt, shutting down...")
-	hd.Stop()
-	<-doneChan
+	// 读取pcap文件时处理完成会自动退出
+	select {
+	case <-signalChan:
+		fmt.Fprintln(os.Stderr, "\nReceived interrupt, shutting down...")
+		hd.Stop()
+		<-doneChan
+	case <-doneChan:
+	}
 }
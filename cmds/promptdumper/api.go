@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/LubyRuffy/localdumper/jsonl"
+	"github.com/LubyRuffy/localdumper/llmparser"
+	"github.com/fatih/color"
+)
+
+// onAPIRequest 向量计算、模型列表和模型管理等接口的请求，只显示一行摘要
+// 向量计算和重排序请求还会显示输入的文本，并和同一个连接上之后的对话请求关联
+func (n *Notifier) onAPIRequest(req *httpdumper.Request, apiReq llmparser.APIRequest) {
+	n.apiRequests.Store(req.ID, apiReq)
+	if r := retrievalOf(apiReq); r != nil {
+		n.addRetrieval(req, r)
+	}
+
+	if n.jsonl != nil {
+		n.jsonl.Write(jsonl.NewRequestEvent(req))
+		return
+	}
+	if n.viewer != nil {
+		return
+	}
+
+	n.printLock.Lock()
+	defer n.printLock.Unlock()
+	color.Yellow("%s request: %s\n", apiReq.Provider().Name(), apiReq.Summary())
+	if req.Process != nil {
+		fmt.Printf("Process: %s %s\n", req.Process, strings.Join(req.Process.Cmdline, " "))
+	}
+	if r := retrievalOf(apiReq); r != nil {
+		n.printRetrievalInputs(r)
+	}
+}
+
+// retrieval 连接上等待之后的对话请求的向量计算或者重排序请求
+type retrieval struct {
+	*llmparser.Retrieval
+	time time.Time
+}
+
+// 连接上最多保留的向量计算和重排序请求，以及保留的时间
+const (
+	maxRetrievals = 8
+	retrievalTTL  = 10 * time.Minute
+)
+
+// retrievalOf 向量计算和重排序请求的输入，其他请求返回nil
+func retrievalOf(apiReq llmparser.APIRequest) *llmparser.Retrieval {
+	if r, ok := apiReq.(llmparser.Retriever); ok {
+		return r.Retrieval()
+	}
+	return nil
+}
+
+// addRetrieval 记录连接上的向量计算和重排序请求，之后同一个连接上的对话请求会显示关联
+func (n *Notifier) addRetrieval(req *httpdumper.Request, r *llmparser.Retrieval) {
+	n.retrievalLock.Lock()
+	defer n.retrievalLock.Unlock()
+	if n.retrievals == nil {
+		n.retrievals = make(map[string][]retrieval)
+	}
+	// 清理已经断开或者没有后续对话请求的连接
+	for key, items := range n.retrievals {
+		if req.Time.Sub(items[len(items)-1].time) > retrievalTTL {
+			delete(n.retrievals, key)
+		}
+	}
+	key := req.ConnectionKey()
+	items := append(n.retrievals[key], retrieval{Retrieval: r, time: req.Time})
+	if len(items) > maxRetrievals {
+		items = items[len(items)-maxRetrievals:]
+	}
+	n.retrievals[key] = items
+}
+
+// takeRetrievals 取出同一个连接上之前的向量计算和重排序请求
+func (n *Notifier) takeRetrievals(req *httpdumper.Request) []retrieval {
+	n.retrievalLock.Lock()
+	defer n.retrievalLock.Unlock()
+	key := req.ConnectionKey()
+	items := n.retrievals[key]
+	delete(n.retrievals, key)
+	return items
+}
+
+// printRetrievalInputs 显示向量计算和重排序的输入文本，不显示向量
+func (n *Notifier) printRetrievalInputs(r *llmparser.Retrieval) {
+	if r.Query != "" {
+		color.Blue("  Query: %s\n", r.Query)
+	}
+	for i, input := range r.Inputs {
+		if !n.fullContext {
+			input = llmparser.Truncate(input, 120)
+		}
+		fmt.Printf("  [%d] %s\n", i+1, input)
+	}
+}
+
+// printRetrievals 显示同一个连接上之前的向量计算和重排序请求，以及有哪些输入出现在这次对话请求中
+func (n *Notifier) printRetrievals(req *httpdumper.Request, llmReq *llmRequest) {
+	for _, r := range llmReq.retrievals {
+		used := r.UsedIn(llmReq.LLMRequest)
+		line := fmt.Sprintf("Retrieval: %s (%s before), %d of %d used in this request", r.Summary(), req.Time.Sub(r.time).Round(time.Millisecond), len(used), len(r.Inputs))
+		if len(used) > 0 {
+			var indexes []string
+			for _, i := range used {
+				indexes = append(indexes, fmt.Sprintf("[%d]", i+1))
+			}
+			line += ": " + strings.Join(indexes, " ")
+		}
+		color.Magenta("%s\n", line)
+	}
+}
+
+// onAPIResponse 向量计算、模型列表和模型管理等接口的响应
+func (n *Notifier) onAPIResponse(resp *httpdumper.Response, apiReq llmparser.APIRequest) {
+	if n.jsonl != nil {
+		n.jsonl.Write(jsonl.NewResponseEvent(resp))
+		return
+	}
+	if n.viewer != nil {
+		return
+	}
+
+	apiResp := apiReq.ParseResponse(resp)
+	if apiResp == nil {
+		return
+	}
+
+	n.printLock.Lock()
+	defer n.printLock.Unlock()
+	color.Green("%s response: %s (%s)\n", apiReq.Provider().Name(), apiReq.Summary(), resp.Status)
+	for _, line := range apiResp.Summary() {
+		fmt.Printf("  %s\n", line)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// runeWidth 终端中的显示宽度，中日韩等宽字符占两格
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115F,
+		r >= 0x2E80 && r <= 0xA4CF,
+		r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF,
+		r >= 0xFE30 && r <= 0xFE4F,
+		r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1FAFF,
+		r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
+
+func stringWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// wrapLines 按行拆分，并把超过width的行折行
+func wrapLines(s string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\t", "    "), "\n") {
+		line = strings.TrimRight(line, "\r")
+		for stringWidth(line) > width {
+			w, i := 0, 0
+			for j, r := range line {
+				if w+runeWidth(r) > width {
+					i = j
+					break
+				}
+				w += runeWidth(r)
+			}
+			lines = append(lines, line[:i])
+			line = line[i:]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+type diffLine struct {
+	op          diffOp
+	left, right string
+}
+
+// diffLines 基于最长公共子序列的行级diff
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] 表示a[i:]和b[j:]的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var result []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			result = append(result, diffLine{op: diffEqual, left: a[i], right: b[j]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			result = append(result, diffLine{op: diffInsert, right: b[j]})
+			j++
+		default:
+			result = append(result, diffLine{op: diffDelete, left: a[i]})
+			i++
+		}
+	}
+	return result
+}
+
+// pad 补齐空格到指定显示宽度
+func pad(s string, width int) string {
+	if w := stringWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// printSideBySide 左右对比显示两段文本，连续的删除和新增会并排显示
+func printSideBySide(w io.Writer, leftTitle, left, rightTitle, right string, width int) {
+	column := (width - 3) / 2
+	if column < 10 {
+		column = 10
+	}
+
+	red := color.New(color.FgRed).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+
+	fmt.Fprintf(w, "%s | %s\n", pad(leftTitle, column), rightTitle)
+	fmt.Fprintf(w, "%s-+-%s\n", strings.Repeat("-", column), strings.Repeat("-", column))
+
+	lines := diffLines(wrapLines(left, column), wrapLines(right, column))
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffEqual {
+			fmt.Fprintf(w, "%s   %s\n", pad(lines[i].left, column), lines[i].right)
+			i++
+			continue
+		}
+
+		// 收集连续的修改块
+		var deleted, inserted []string
+		for ; i < len(lines) && lines[i].op != diffEqual; i++ {
+			if lines[i].op == diffDelete {
+				deleted = append(deleted, lines[i].left)
+			} else {
+				inserted = append(inserted, lines[i].right)
+			}
+		}
+		for k := 0; k < max(len(deleted), len(inserted)); k++ {
+			l, r, mark := "", "", "|"
+			if k < len(deleted) {
+				l = deleted[k]
+			} else {
+				mark = ">"
+			}
+			if k < len(inserted) {
+				r = inserted[k]
+			} else {
+				mark = "<"
+			}
+			fmt.Fprintf(w, "%s %s %s\n", red(pad(l, column)), mark, green(r))
+		}
+	}
+}
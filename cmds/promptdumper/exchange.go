@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LubyRuffy/localdumper/displayfilter"
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/LubyRuffy/localdumper/llmparser"
+)
+
+// viewer 交互式界面，收到请求和响应时都会调用Update，同一个请求的exchange用ID区分
+type viewer interface {
+	Update(e *exchange)
+	Run() error // 阻塞直到用户退出
+	Stop()      // 让Run返回，可以在任意goroutine中调用
+}
+
+// exchange 交互式界面中的一次llm调用，响应之前resp和decoded为空
+// 收到响应时创建新的exchange替换，不修改已经交给界面的
+type exchange struct {
+	req     *httpdumper.Request
+	resp    *httpdumper.Response
+	llmReq  *llmparser.LLMRequest
+	thread  *llmparser.Thread
+	decoded *llmparser.DecodedResponse
+	metrics *llmparser.Metrics // 包括本地分词器的估计
+}
+
+// ID 请求的ID
+func (e *exchange) ID() string {
+	return e.req.ID
+}
+
+// Client 发起请求的进程或者容器，都没有时使用源地址
+func (e *exchange) Client() string {
+	if e.req.Process != nil {
+		return e.req.Process.String()
+	}
+	if e.req.SrcContainer != nil {
+		return e.req.SrcContainer.String()
+	}
+	return e.req.Net.Src().String() + ":" + e.req.Transport.Src().String()
+}
+
+// Latency 请求到响应完成的耗时，还没有响应时为0
+func (e *exchange) Latency() time.Duration {
+	if e.resp == nil {
+		return 0
+	}
+	return e.resp.Time.Sub(e.req.Time)
+}
+
+// Tokens 输入和输出的token数，比如 1200/85，估计的带~
+func (e *exchange) Tokens() string {
+	if e.metrics == nil || (e.metrics.PromptTokens == 0 && e.metrics.CompletionTokens == 0) {
+		return ""
+	}
+	s := fmt.Sprintf("%d/%d", e.metrics.PromptTokens, e.metrics.CompletionTokens)
+	if e.metrics.Estimated {
+		s = "~" + s
+	}
+	return s
+}
+
+// Status 状态码，还没有响应时为空
+func (e *exchange) Status() string {
+	if e.resp == nil || e.resp.Response == nil {
+		return ""
+	}
+	return fmt.Sprint(e.resp.StatusCode)
+}
+
+// Error 错误响应或者流式响应中的错误
+func (e *exchange) Error() *llmparser.LLMError {
+	if e.decoded == nil {
+		return nil
+	}
+	return e.decoded.Error
+}
+
+// Contains 搜索，请求和响应的文本中包含s，不区分大小写
+func (e *exchange) Contains(s string) bool {
+	s = strings.ToLower(s)
+	texts := []string{e.llmReq.Model, e.Client(), e.req.URL.String(), e.llmReq.System, e.llmReq.Prompt}
+	for _, msg := range e.llmReq.Messages {
+		texts = append(texts, msg.Content, msg.Reasoning)
+	}
+	for _, tool := range e.llmReq.Tools {
+		texts = append(texts, tool.Function.Name)
+	}
+	if e.decoded != nil {
+		texts = append(texts, e.decoded.Content, e.decoded.Reasoning)
+	}
+	for _, text := range texts {
+		if strings.Contains(strings.ToLower(text), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 是否满足显示过滤器，引用了响应字段的表达式在响应之前不满足
+func (e *exchange) Match(filter *displayfilter.Filter) bool {
+	if filter.NeedsResponse() && e.resp == nil {
+		return false
+	}
+	return filter.Match(displayfilter.NewEnv(e.req, e.resp).WithLLMRequest(e.llmReq))
+}
+
+// maxRawBody 原始http中最多显示的body字节数
+const maxRawBody = 256 * 1024
+
+// rawBody 非文本的body只显示长度，过长的截断
+func rawBody(body []byte) string {
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("[%d bytes of binary data]", len(body))
+	}
+	if len(body) > maxRawBody {
+		n := maxRawBody
+		for n > 0 && !utf8.RuneStart(body[n]) {
+			n--
+		}
+		return string(body[:n]) + fmt.Sprintf("\n[%d bytes total]", len(body))
+	}
+	return string(body)
+}
+
+// RawRequest 原始http请求
+func (e *exchange) RawRequest() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s %s\n", e.req.Method, e.req.URL.RequestURI(), e.req.Proto)
+	if e.req.Host != "" {
+		fmt.Fprintf(&b, "Host: %s\n", e.req.Host)
+	}
+	redactHeader(e.req.Header).Write(&b)
+	b.WriteString("\n")
+	b.WriteString(rawBody(e.req.Body))
+	return strings.ReplaceAll(b.String(), "\r\n", "\n")
+}
+
+// credentialHeaders 包含密钥的请求头，显示时隐藏，cookie见redactCookie
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "Api-Key", "X-Goog-Api-Key"}
+
+// redactHeader 隐藏密钥，只保留认证方式和最后4个字符，方便区分不同的key；cookie只保留名字
+func redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range credentialHeaders {
+		for i, value := range header.Values(name) {
+			scheme, secret, ok := strings.Cut(value, " ")
+			if !ok {
+				scheme, secret = "", value
+			} else {
+				scheme += " "
+			}
+			tail := ""
+			if len(secret) > 16 {
+				tail = secret[len(secret)-4:]
+			}
+			header[name][i] = scheme + "***" + tail
+		}
+	}
+	for i, value := range header.Values("Cookie") {
+		header["Cookie"][i] = redactCookie(value)
+	}
+	for i, value := range header.Values("Set-Cookie") {
+		// 只有第一项是cookie，后面的Path、Expires等属性保留
+		first, attrs, ok := strings.Cut(value, ";")
+		header["Set-Cookie"][i] = redactCookie(first)
+		if ok {
+			header["Set-Cookie"][i] += ";" + attrs
+		}
+	}
+	return header
+}
+
+// redactCookie 隐藏 a=1; b=2 中的值
+func redactCookie(value string) string {
+	pairs := strings.Split(value, ";")
+	for i, pair := range pairs {
+		name, _, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		pairs[i] = name + "=***"
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// RawResponse 原始http响应，还没有响应时为空
+func (e *exchange) RawResponse() string {
+	if e.resp == nil || e.resp.Response == nil {
+		return ""
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s\n", e.resp.Proto, e.resp.Status)
+	redactHeader(e.resp.Header).Write(&b)
+	b.WriteString("\n")
+	b.WriteString(rawBody(e.resp.Body))
+	return strings.ReplaceAll(b.String(), "\r\n", "\n")
+}
@@ -2,183 +2,199 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"strings"
-	"sync"
 	"syscall"
 	"time"
 
+	"github.com/LubyRuffy/localdumper/catalog"
+	"github.com/LubyRuffy/localdumper/displayfilter"
+	"github.com/LubyRuffy/localdumper/dockerinfo"
 	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/LubyRuffy/localdumper/jsonl"
 	"github.com/LubyRuffy/localdumper/llmparser"
-	"github.com/fatih/color"
-	"github.com/google/gopacket"
+	"github.com/LubyRuffy/localdumper/store"
+	"github.com/LubyRuffy/localdumper/tokenizer"
 )
 
-type Notifier struct {
-	llmRequests sync.Map
-	printLock   sync.Mutex
-}
-
-func (n *Notifier) OnRequest(req *httpdumper.Request) {
-	// 目前请求大模型基本都是json
-	// 同时url相对比较固定
-	llmReq := llmparser.ParseRequest(req)
-	if llmReq == nil {
-		return
+func parseConfig() (*httpdumper.Config, *Notifier) {
+	var cfg httpdumper.Config
+	n := Notifier{threader: llmparser.NewThreader(), catalog: catalog.New(), tools: catalog.NewToolCatalog()}
+	var dockerHost, displayFilter, output, dbPath, tokenizerFile, webAddr string
+	flag.StringVar(&cfg.Device, "i", "lo0", "Network interface to capture packets from. (e.g., lo0, lo)")
+	flag.StringVar(&cfg.PcapFile, "r", "", "Pcap file to read packets from instead of live capture.")
+	flag.BoolVar(&cfg.Realtime, "realtime", false, "Replay the pcap file with its original pacing instead of full speed.")
+	flag.StringVar(&cfg.BPFFilter, "f", "tcp and (port 11434 or port 1234)", "BPF filter for capturing packets.")
+	flag.StringVar(&dockerHost, "docker", "", "Resolve endpoints to docker containers via this docker host. (e.g., "+dockerinfo.DefaultHost+")")
+	flag.BoolVar(&cfg.ResolveProcess, "resolve-process", true, "Resolve the local process owning each connection. (linux only)")
+	flag.StringVar(&n.processName, "process", "", "Only show requests sent by processes whose name contains this. (e.g., cursor)")
+	flag.BoolVar(&n.fullContext, "full-context", false, "Print the full message history, repeated system prompts and tool definitions of every request instead of only what changed.")
+	flag.StringVar(&n.promptsDir, "prompts", "", "Export every distinct system prompt as markdown into this directory as they appear.")
+	flag.StringVar(&n.toolsFile, "tools", "", "Export every distinct tool definition into this json file as they appear.")
+	flag.StringVar(&n.mediaDir, "save-media", "", "Save images, audio and files sent to the model into this directory.")
+	flag.StringVar(&tokenizerFile, "tokenizer", "", "Estimate prompt tokens with this huggingface tokenizer.json. (e.g., the model's tokenizer.json)")
+	flag.StringVar(&output, "output", "text", "Output format: text, jsonl or tui. (tui: browse llm calls in an interactive terminal ui)")
+	flag.StringVar(&webAddr, "web", "", "Serve a web ui on this address instead of printing, a bare port listens on 127.0.0.1 only. (e.g., :8765 or 192.168.1.10:8765)")
+	flag.StringVar(&dbPath, "db", "", "Save every llm exchange into this SQLite database. (search with: promptdumper query -db file)")
+	flag.StringVar(&displayFilter, "Y", "", "Display filter expression. (e.g., 'model == \"qwen3\" && len(tools) > 0')")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s [query|replay]:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nDisplay filter fields:\n%s", displayfilter.Fields())
 	}
+	flag.Parse()
 
-	n.llmRequests.Store(req.ID, time.Now())
+	// 读取文件时不抓包，也没有本地进程可以解析
+	if cfg.PcapFile != "" {
+		cfg.Device = ""
+		cfg.ResolveProcess = false
+	}
 
-	n.printLock.Lock()
-	defer n.printLock.Unlock()
+	if tokenizerFile != "" {
+		tk, err := tokenizer.Load(tokenizerFile)
+		if err != nil {
+			log.Fatalln("load tokenizer failed:", err)
+		}
+		n.tokenizer = tk
+	}
 
-	color.Yellow(strings.Repeat(">", 58))
-	fmt.Printf("New request: %s\n", req.URL.String())
+	if n.promptsDir != "" || n.toolsFile != "" {
+		n.startFlush(5 * time.Second)
+	}
 
-	if llmReq.Model != "" {
-		fmt.Printf("Model: %s\n", llmReq.Model)
-		if llmReq.System != "" {
-			fmt.Printf("System: %s\n", llmReq.System)
+	if n.mediaDir != "" {
+		if err := os.MkdirAll(n.mediaDir, 0o755); err != nil {
+			log.Fatalln(err)
 		}
-		if llmReq.Prompt != "" {
-			fmt.Printf("Prompt: %s\n", llmReq.Prompt)
-		}
-		if len(llmReq.Messages) > 0 {
-			for _, msg := range llmReq.Messages {
-				switch msg.Role {
-				case "system":
-					color.Red("%s\n", msg.Content)
-					// fmt.Printf("System: %s\n", msg.Content)
-				case "user":
-					color.Blue("%s\n", msg.Content)
-					// fmt.Printf("%s\n", msg.Content)
-				case "tool":
-					fmt.Printf("Tool response: %s\n", msg.Content)
-				case "assistant":
-					fmt.Printf("Assistant: ")
-					if msg.Content != "" {
-						fmt.Printf("%s\n", msg.Content)
-					}
-					if msg.ToolCalls != nil {
-						fmt.Printf("%s\n", msg.ToolCallsString())
-					}
-				default:
-					fmt.Printf("Message: %s\n", msg.Content)
-				}
-			}
-		}
-		// if len(llmReq.Tools) > 0 {
-		// 	for _, tool := range llmReq.Tools {
-		// 		fmt.Printf("Tool: %s\n", tool.Function.Name)
-		// 	}
-		// }
 	}
-	color.Yellow(strings.Repeat(">", 58))
-}
 
-func (n *Notifier) OnResponse(resp *httpdumper.Response) {
-	if resp.Request == nil || resp.Request.ID == "" {
-		return
+	switch output {
+	case "text":
+	case "jsonl":
+		n.jsonl = jsonl.NewWriter(os.Stdout)
+	case "tui":
+		n.viewer = newTUI()
+	default:
+		log.Fatalln("unknown output format:", output)
 	}
-	// 对应的请求是llm请求
-	if _, ok := n.llmRequests.Load(resp.Request.ID); !ok {
-		return
+
+	if webAddr != "" {
+		if n.viewer != nil || n.jsonl != nil {
+			log.Fatalln("-web cannot be used with -output", output)
+		}
+		n.viewer = newWebUI(webAddr)
 	}
 
-	n.printLock.Lock()
-	defer n.printLock.Unlock()
+	if n.processName != "" && cfg.PcapFile != "" {
+		log.Fatalln("-process cannot be used when reading a pcap file")
+	}
+	if n.processName != "" && !httpdumper.ProcessSupported {
+		log.Fatalln("-process is only supported on linux")
+	}
+	if n.processName != "" && !cfg.ResolveProcess {
+		log.Fatalln("-process requires -resolve-process")
+	}
 
-	color.Green(strings.Repeat("<", 58))
-	fmt.Printf("New response: %s\n", resp.Request.URL)
-	ct := resp.Header.Get("Content-Type")
-	response := ""
-	if strings.HasPrefix(ct, "application/x-ndjson") {
-		lines := strings.Split(string(resp.Body), "\n")
-		for _, line := range lines {
-			if line == "" {
-				continue
-			}
-			var llmResp llmparser.LLMResponse
-			if err := json.Unmarshal([]byte(line), &llmResp); err != nil {
-				continue
-			}
-			response += llmResp.String()
-		}
-	} else if strings.HasPrefix(ct, "application/json") {
-		var llmResp llmparser.LLMResponse
-		if err := json.Unmarshal(resp.Body, &llmResp); err != nil {
-			return
+	if dockerHost != "" && cfg.PcapFile != "" {
+		log.Println("-docker is ignored when reading a pcap file")
+		dockerHost = ""
+	}
+	if dockerHost != "" {
+		resolver, err := dockerinfo.New(dockerHost)
+		if err != nil {
+			log.Fatalln(err)
 		}
-		response += llmResp.String()
-	} else if strings.HasPrefix(ct, "text/event-stream") {
-		lines := strings.Split(string(resp.Body), "\n")
-		for _, line := range lines {
-			if line == "" {
-				continue
-			}
-			if !strings.HasPrefix(line, "data:") {
-				continue
-			}
-			line = strings.TrimPrefix(line, "data:")
-			line = strings.TrimSpace(line)
-			if line == "" || line == "[DONE]" {
-				continue
-			}
+		cfg.ContainerResolver = resolver
+	}
 
-			var llmResp llmparser.LLMResponse
-			if err := json.Unmarshal([]byte(line), &llmResp); err != nil {
-				log.Printf("Failed to unmarshal response: %s\n", err)
-				continue
-			}
-			response += llmResp.String()
+	if displayFilter != "" {
+		filter, err := displayfilter.Compile(displayFilter)
+		if err != nil {
+			log.Fatalln("invalid display filter:", err)
 		}
-	} else {
-		fmt.Printf("unknown content type: %s\n", ct)
+		n.filter = filter
 	}
 
-	think := ""
-	if strings.HasPrefix(strings.Trim(response, "\r\n\t "), "<think>") {
-		think = strings.Split(response, "</think>")[0] + "</think>"
-		response = strings.Split(response, "</think>")[1]
+	if dbPath != "" {
+		s, err := store.Open(dbPath)
+		if err != nil {
+			log.Fatalln("open database failed:", err)
+		}
+		n.store = store.NewWriter(s, 1024)
 	}
+	return &cfg, &n
+}
 
-	if think != "" {
-		color.Cyan("%s\n", think)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "query":
+			runQuery(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		}
 	}
-	color.Blue("%s\n", response)
 
-	color.Green(strings.Repeat("<", 58))
+	cfg, n := parseConfig()
+	defer n.Close()
 
-	n.llmRequests.Delete(resp.Request.ID)
-
-}
-
-func (n *Notifier) OnTcpSession(id string, net, transport gopacket.Flow) {
-	fmt.Printf("New TCP session: %s\n", id)
-}
-
-func main() {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
-	hd := httpdumper.New(&httpdumper.Config{
-		Device:    "lo0", // 本地抓包
-		BPFFilter: "tcp and (port 11434 or port 1234)",
-	}, &Notifier{})
+	// 终端界面运行期间日志显示在状态栏，要在开始抓包之前重定向
+	if t, ok := n.viewer.(*tui); ok {
+		defer t.captureLog()()
+	}
+
+	hd := httpdumper.New(cfg, n.notifier())
 	doneChan := make(chan struct{}, 1)
+	var startErr error
 	go func() {
 		defer close(doneChan)
-		if err := hd.Start(context.Background()); err != nil {
-			panic(err)
-		}
+		startErr = hd.Start(context.Background())
 	}()
 
-	<-signalChan
-	fmt.Println("\nReceived interrupt, shutting down...")
+	// 交互式界面一直运行到用户退出
+	viewerDone := make(chan struct{})
+	if n.viewer != nil {
+		go func() {
+			defer close(viewerDone)
+			if err := n.viewer.Run(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+
+	// 读取pcap文件时处理完成会自动退出，有交互式界面时继续浏览
+	select {
+	case <-signalChan:
+		fmt.Fprintln(os.Stderr, "\nReceived interrupt, shutting down...")
+	case <-viewerDone:
+	case <-doneChan:
+		if startErr != nil {
+			// 先退出界面恢复终端，否则错误信息看不到
+			if n.viewer != nil {
+				n.viewer.Stop()
+				<-viewerDone
+			}
+			log.SetOutput(os.Stderr)
+			n.Close()
+			log.Fatalln(startErr)
+		}
+		if n.viewer == nil {
+			return
+		}
+		select {
+		case <-signalChan:
+		case <-viewerDone:
+		}
+		return
+	}
 	hd.Stop()
 	<-doneChan
 }
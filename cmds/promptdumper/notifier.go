@@ -0,0 +1,489 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/catalog"
+	"github.com/LubyRuffy/localdumper/displayfilter"
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/LubyRuffy/localdumper/jsonl"
+	"github.com/LubyRuffy/localdumper/llmparser"
+	"github.com/LubyRuffy/localdumper/store"
+	"github.com/fatih/color"
+)
+
+// containersString 连接两端的容器信息，都没有则返回空
+func containersString(src, dst *httpdumper.Container) string {
+	if src == nil && dst == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s -> %s", containerName(src), containerName(dst))
+}
+
+func containerName(c *httpdumper.Container) string {
+	if c == nil {
+		return "host"
+	}
+	return c.String()
+}
+
+// llmRequest 等待响应的llm请求
+type llmRequest struct {
+	*llmparser.LLMRequest
+	thread      *llmparser.Thread
+	seenPrompts map[string]catalog.Entry // 提示词hash -> 之前已经出现过的系统提示词
+	toolsDiff   llmparser.ToolsDiff      // 相对同一个客户端上一次请求的工具变化
+	firstTools  bool                     // 这个客户端第一次声明工具
+	tokens      *llmparser.PromptTokens  // 使用本地分词器估计的token数
+	retrievals  []retrieval              // 同一个连接上之前的向量计算和重排序请求
+}
+
+type Notifier struct {
+	llmRequests sync.Map // 请求ID -> *llmRequest
+	apiRequests sync.Map // 请求ID -> llmparser.APIRequest
+	printLock   sync.Mutex
+
+	retrievalLock sync.Mutex
+	retrievals    map[string][]retrieval // 连接 -> 等待之后的对话请求的向量计算和重排序请求
+
+	threader    *llmparser.Threader
+	fullContext bool                   // 对话请求是否显示完整的历史消息和重复的系统提示词
+	catalog     *catalog.Catalog       // 系统提示词目录
+	promptsDir  string                 // 不为空时把系统提示词导出到这个目录
+	tools       *catalog.ToolCatalog   // 工具目录
+	toolsFile   string                 // 不为空时把工具定义导出到这个json文件
+	mediaDir    string                 // 不为空时把请求中的图片、音频和文件保存到这个目录
+	tokenizer   llmparser.TokenCounter // 不为空时用于估计请求的token数
+	processName string                 // 只显示进程名包含processName的请求
+	jsonl       *jsonl.Writer          // 不为空时输出jsonl而不是彩色文本
+	store       *store.Writer          // 不为空时保存每次llm调用
+	viewer      viewer                 // 不为空时在交互式界面中显示，不输出文本
+	flushStop   chan struct{}          // 关闭后停止定时导出
+	flushDone   chan struct{}
+
+	filter *displayfilter.Filter // 显示过滤器
+}
+
+// notifier 返回实际使用的通知器，有显示过滤器时进行包装
+func (n *Notifier) notifier() httpdumper.Notifier {
+	if n.filter != nil {
+		return displayfilter.NewNotifier(n.filter, n)
+	}
+	return n
+}
+
+// startFlush 定时导出新的系统提示词和工具定义，异常退出时也不会丢失太多
+func (n *Notifier) startFlush(interval time.Duration) {
+	n.flushStop, n.flushDone = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(n.flushDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-n.flushStop:
+				return
+			case <-ticker.C:
+				n.flush()
+			}
+		}
+	}()
+}
+
+// flush 导出上次导出之后的变化
+func (n *Notifier) flush() {
+	if n.promptsDir != "" {
+		if err := n.catalog.Flush(n.promptsDir); err != nil {
+			log.Println("export system prompts failed:", err)
+		}
+	}
+	if n.toolsFile != "" {
+		if err := n.tools.Flush(n.toolsFile); err != nil {
+			log.Println("export tools failed:", err)
+		}
+	}
+}
+
+// Close 释放资源
+func (n *Notifier) Close() {
+	if n.flushStop != nil {
+		close(n.flushStop)
+		<-n.flushDone
+	}
+	if n.promptsDir != "" {
+		if err := n.catalog.Export(n.promptsDir); err != nil {
+			log.Println("export system prompts failed:", err)
+		}
+	}
+	if n.toolsFile != "" {
+		if err := n.tools.ExportJSON(n.toolsFile); err != nil {
+			log.Println("export tools failed:", err)
+		}
+	}
+	if n.store != nil {
+		n.store.Close()
+		if dropped := n.store.Dropped(); dropped > 0 {
+			log.Printf("%d exchanges were not saved to the database\n", dropped)
+		}
+	}
+}
+
+func (n *Notifier) OnRequest(req *httpdumper.Request) {
+	// 目前请求大模型基本都是json
+	// 同时url相对比较固定
+	llmReq := llmparser.ParseRequest(req)
+	if llmReq == nil {
+		if apiReq := llmparser.ParseAPIRequest(req); apiReq != nil {
+			if n.processName == "" || req.Process.MatchName(n.processName) {
+				n.onAPIRequest(req, apiReq)
+			}
+		}
+		return
+	}
+	if n.processName != "" && !req.Process.MatchName(n.processName) {
+		return
+	}
+
+	pending := &llmRequest{
+		LLMRequest:  llmReq,
+		thread:      n.threader.Track(llmReq),
+		seenPrompts: n.catalogPrompts(req, llmReq),
+		retrievals:  n.takeRetrievals(req),
+	}
+	if n.mediaDir != "" {
+		n.saveMedia(llmReq)
+	}
+	if n.tokenizer != nil {
+		pending.tokens = llmReq.CountTokens(n.tokenizer)
+	}
+	if len(llmReq.Tools) > 0 {
+		pending.toolsDiff, pending.firstTools = n.tools.Add(llmReq.Tools, llmReq.Model, clientName(req), toolSetKey(req), req.Time)
+	}
+	n.llmRequests.Store(req.ID, pending)
+
+	if n.jsonl != nil {
+		n.jsonl.Write(jsonl.NewRequestEvent(req))
+		return
+	}
+	if n.viewer != nil {
+		e := &exchange{req: req, llmReq: llmReq, thread: pending.thread}
+		if pending.tokens != nil {
+			e.metrics = &llmparser.Metrics{PromptTokens: pending.tokens.Total, Estimated: true}
+		}
+		n.viewer.Update(e)
+		return
+	}
+
+	n.printLock.Lock()
+	defer n.printLock.Unlock()
+	n.printRequest(req, pending)
+}
+
+// clientName 发起请求的客户端，优先使用User-Agent
+func clientName(req *httpdumper.Request) string {
+	if ua := req.Header.Get("User-Agent"); ua != "" {
+		return ua
+	}
+	if req.Process != nil {
+		return req.Process.Name
+	}
+	return ""
+}
+
+// toolSetKey 区分不同agent的工具集合：同一个User-Agent可能来自不同的进程或者容器
+// 不使用系统提示词，很多agent会在里面放时间、当前目录等每次都变的内容
+func toolSetKey(req *httpdumper.Request) string {
+	return strings.Join([]string{clientName(req), req.Process.String(), containerName(req.SrcContainer)}, "|")
+}
+
+// catalogPrompts 把请求中的系统提示词记录到目录，返回之前已经出现过的
+func (n *Notifier) catalogPrompts(req *httpdumper.Request, llmReq *llmparser.LLMRequest) map[string]catalog.Entry {
+	var seen map[string]catalog.Entry
+	for _, prompt := range llmReq.SystemPrompts() {
+		if e, ok := n.catalog.Add(prompt, llmReq.Model, clientName(req), req.Time); ok {
+			if seen == nil {
+				seen = make(map[string]catalog.Entry)
+			}
+			seen[e.Hash] = e
+		}
+	}
+	return seen
+}
+
+// seenBefore 重复出现的系统提示词只显示摘要
+func (n *Notifier) seenBefore(llmReq *llmRequest, prompt string) (string, bool) {
+	e, ok := llmReq.seenPrompts[catalog.Hash(prompt)]
+	if !ok || n.fullContext {
+		return "", false
+	}
+	return fmt.Sprintf("seen before (#%d, %d times, %d characters)", e.Index, e.Count, len([]rune(e.Prompt))), true
+}
+
+func (n *Notifier) printRequest(req *httpdumper.Request, llmReq *llmRequest) {
+	color.Yellow(strings.Repeat(">", 58))
+	fmt.Printf("New request: %s\n", req.URL.String())
+	if provider := llmparser.DetectProvider(req); provider != "" {
+		fmt.Printf("Provider: %s\n", provider.Name())
+	}
+	if containers := containersString(req.SrcContainer, req.DstContainer); containers != "" {
+		fmt.Printf("Container: %s\n", containers)
+	}
+	if req.Process != nil {
+		fmt.Printf("Process: %s %s\n", req.Process, strings.Join(req.Process.Cmdline, " "))
+	}
+
+	if llmReq.Model != "" {
+		fmt.Printf("Model: %s\n", llmReq.Model)
+		if options := llmReq.Summary(); len(options) > 0 {
+			fmt.Printf("Options: %s\n", strings.Join(options, " "))
+		}
+		if schema := llmReq.OutputSchema(); schema != nil {
+			fmt.Printf("Output schema:\n%s", llmparser.RenderSchema(schema, "  "))
+		}
+		if llmReq.tokens != nil {
+			fmt.Printf("Tokens: %s\n", llmReq.tokens)
+		}
+		n.printRetrievals(req, llmReq)
+		if warning := llmReq.ContextWarning(n.tokenizer); warning != "" {
+			color.Red("Warning: %s\n", warning)
+		}
+		if llmReq.System != "" {
+			if summary, ok := n.seenBefore(llmReq, llmReq.System); ok {
+				fmt.Printf("System: %s\n", summary)
+			} else {
+				fmt.Printf("System: %s\n", llmReq.System)
+			}
+		}
+		if llmReq.Prompt != "" {
+			fmt.Printf("Prompt: %s\n", llmReq.Prompt)
+		}
+		if llmReq.InputPrefix != "" || llmReq.InputSuffix != "" {
+			for _, extra := range llmReq.InputExtra {
+				fmt.Printf("Extra context: %s (%d characters)\n", extra.FileName, len([]rune(extra.Text)))
+			}
+			color.Blue("Prefix: %s\n", llmReq.InputPrefix)
+			color.Blue("Suffix: %s\n", llmReq.InputSuffix)
+		}
+		if llmReq.Grammar != "" {
+			fmt.Printf("Grammar (%d rules):\n", len(llmReq.GrammarRules()))
+			for _, line := range strings.Split(strings.TrimSpace(llmReq.Grammar), "\n") {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+		n.printMedia(llmparser.ImageParts(llmReq.Images))
+		messages := llmReq.Messages
+		if thread := llmReq.thread; thread != nil {
+			if thread.Continuation && !n.fullContext {
+				messages = thread.NewMessages
+				color.Magenta("Conversation: %s, turn %d, %d earlier messages hidden\n", thread.ConversationID, thread.Turn, thread.Hidden)
+			} else {
+				color.Magenta("Conversation: %s, turn %d\n", thread.ConversationID, thread.Turn)
+			}
+		}
+		if len(messages) > 0 {
+			for _, msg := range messages {
+				switch msg.Role {
+				case "system":
+					if summary, ok := n.seenBefore(llmReq, msg.Content); ok {
+						color.Red("System: %s\n", summary)
+					} else {
+						color.Red("%s\n", msg.Content)
+					}
+					// fmt.Printf("System: %s\n", msg.Content)
+				case "user":
+					color.Blue("%s\n", msg.Content)
+					// fmt.Printf("%s\n", msg.Content)
+				case "tool":
+					fmt.Printf("Tool response: %s\n", msg.Content)
+				case "assistant":
+					if msg.Reasoning != "" {
+						color.Cyan("Reasoning: %s\n", msg.Reasoning)
+					}
+					fmt.Printf("Assistant: ")
+					if msg.Content != "" {
+						fmt.Printf("%s\n", msg.Content)
+					}
+					if msg.ToolCalls != nil {
+						fmt.Printf("%s\n", msg.ToolCallsString())
+					}
+				default:
+					fmt.Printf("Message: %s\n", msg.Content)
+				}
+				n.printMedia(msg.Media())
+			}
+		}
+		n.printTools(llmReq)
+	}
+	color.Yellow(strings.Repeat(">", 58))
+}
+
+// mediaPath 图片、音频和文件保存的路径，按内容hash命名，相同的内容只保存一次
+func (n *Notifier) mediaPath(part *llmparser.ContentPart) string {
+	sum := sha256.Sum256(part.Data)
+	return filepath.Join(n.mediaDir, hex.EncodeToString(sum[:8])+part.Extension())
+}
+
+// saveMedia 保存请求中所有内联的图片、音频和文件
+func (n *Notifier) saveMedia(llmReq *llmparser.LLMRequest) {
+	parts := llmparser.ImageParts(llmReq.Images)
+	for _, msg := range llmReq.Messages {
+		parts = append(parts, msg.Media()...)
+	}
+	for _, part := range parts {
+		if len(part.Data) == 0 {
+			continue
+		}
+		path := n.mediaPath(&part)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := os.WriteFile(path, part.Data, 0o644); err != nil {
+			log.Println("save media failed:", err)
+		}
+	}
+}
+
+// printMedia 显示图片、音频和文件的摘要
+func (n *Notifier) printMedia(parts []llmparser.ContentPart) {
+	for _, part := range parts {
+		if n.mediaDir != "" && len(part.Data) > 0 {
+			color.Magenta("%s saved to %s\n", part.Summary(), n.mediaPath(&part))
+		} else {
+			color.Magenta("%s\n", part.Summary())
+		}
+	}
+}
+
+// printTools 客户端第一次声明工具时显示全部定义，之后只显示变化
+func (n *Notifier) printTools(llmReq *llmRequest) {
+	if len(llmReq.Tools) == 0 {
+		return
+	}
+	if llmReq.firstTools || n.fullContext {
+		color.Cyan("Tools (%d):\n", len(llmReq.Tools))
+		for _, tool := range llmReq.Tools {
+			fmt.Print(tool.Render())
+		}
+		return
+	}
+
+	diff := llmReq.toolsDiff
+	if diff.Empty() {
+		color.Cyan("Tools (%d): unchanged\n", len(llmReq.Tools))
+		return
+	}
+	color.Cyan("Tools (%d): changed\n", len(llmReq.Tools))
+	for _, tool := range diff.Added {
+		color.Green("+ %s", tool.Render())
+	}
+	for _, tool := range diff.Changed {
+		color.Yellow("~ %s", tool.Render())
+	}
+	for _, tool := range diff.Removed {
+		color.Red("- %s\n", tool.Signature())
+	}
+}
+
+func (n *Notifier) OnResponse(resp *httpdumper.Response) {
+	if resp.Request == nil || resp.Request.ID == "" {
+		return
+	}
+	// 对应的请求是llm请求
+	v, ok := n.llmRequests.LoadAndDelete(resp.Request.ID)
+	if !ok {
+		if v, ok := n.apiRequests.LoadAndDelete(resp.Request.ID); ok {
+			n.onAPIResponse(resp, v.(llmparser.APIRequest))
+		}
+		return
+	}
+	pending := v.(*llmRequest)
+	llmReq := pending.LLMRequest
+
+	decoded := llmparser.DecodeResponse(resp)
+	response, think, metrics, llmErr := decoded.Content, decoded.Reasoning, decoded.Metrics, decoded.Error
+	// 后端没有返回token用量时使用本地分词器估计
+	if pending.tokens != nil && (metrics == nil || metrics.PromptTokens == 0) {
+		estimated := &llmparser.Metrics{PromptTokens: pending.tokens.Total, Estimated: true}
+		if metrics == nil || metrics.CompletionTokens == 0 {
+			estimated.CompletionTokens = n.tokenizer.Count(think + response)
+		}
+		metrics = metrics.Merge(estimated)
+	}
+	if pending.thread != nil {
+		n.threader.AddResponse(pending.thread.ConversationID, response)
+	}
+
+	if n.store != nil {
+		n.store.Save(store.NewExchange(resp, llmReq, response, think, decoded.ToolCalls, metrics))
+	}
+
+	if n.viewer != nil {
+		n.viewer.Update(&exchange{req: resp.Request, resp: resp, llmReq: llmReq, thread: pending.thread, decoded: decoded, metrics: metrics})
+		return
+	}
+
+	if n.jsonl != nil {
+		n.jsonl.Write(jsonl.NewResponseEvent(resp))
+		exchange := jsonl.NewExchangeEvent(resp, llmReq, response, think)
+		if pending.thread != nil {
+			exchange.Conversation, exchange.Turn = pending.thread.ConversationID, pending.thread.Turn
+		}
+		exchange.Usage = metrics
+		exchange.Error = llmErr
+		n.jsonl.Write(exchange)
+		return
+	}
+
+	n.printLock.Lock()
+	defer n.printLock.Unlock()
+
+	color.Green(strings.Repeat("<", 58))
+	fmt.Printf("New response: %s (%s)\n", resp.Request.URL, resp.Status)
+	if containers := containersString(resp.DstContainer, resp.SrcContainer); containers != "" {
+		fmt.Printf("Container: %s\n", containers)
+	}
+
+	if think != "" {
+		color.Cyan("Reasoning: %s\n", think)
+	}
+	if response != "" || llmErr == nil && len(decoded.ToolCalls) == 0 {
+		color.Blue("%s\n", response)
+	}
+	if len(decoded.ToolCalls) > 0 {
+		msg := llmparser.LLMMessage{ToolCalls: decoded.ToolCalls}
+		color.Yellow("%s\n", msg.ToolCallsString())
+	}
+	if llmErr != nil {
+		color.New(color.FgHiRed, color.Bold).Printf("Error: %s\n", llmErr)
+	}
+	if decoded.FinishReason != "" {
+		fmt.Printf("Finish reason: %s\n", decoded.FinishReason)
+	}
+	if metrics != nil {
+		color.Magenta("Metrics: %s\n", metrics)
+	}
+	if info := llmparser.LMStudioInfo(resp); info != "" {
+		color.Magenta("Runtime: %s\n", info)
+	}
+
+	color.Green(strings.Repeat("<", 58))
+}
+
+func (n *Notifier) OnTcpSession(session *httpdumper.TcpSession) {
+	if n.jsonl != nil {
+		n.jsonl.Write(jsonl.NewSessionEvent(session))
+		return
+	}
+	if n.viewer != nil {
+		return
+	}
+	fmt.Printf("New TCP session: %s\n", session.ID)
+}
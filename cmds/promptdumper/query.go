@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/store"
+	"github.com/fatih/color"
+)
+
+// parseTime 支持RFC3339、2006-01-02、2006-01-02 15:04:05，以及24h这样的相对时间（表示当前时间之前）
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", s)
+}
+
+// truncate 截断用于单行显示
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
+
+// runQuery promptdumper query 子命令，查询保存的llm调用
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	var q store.Query
+	var dbPath, since, until string
+	var full bool
+	fs.StringVar(&dbPath, "db", "promptdumper.db", "SQLite database to query.")
+	fs.StringVar(&q.Model, "model", "", "Model name contains this.")
+	fs.StringVar(&q.System, "system", "", "System prompt contains this text.")
+	fs.StringVar(&q.Text, "text", "", "Request or response contains this text.")
+	fs.StringVar(&q.Tool, "tool", "", "Request declares this tool.")
+	fs.StringVar(&since, "since", "", "Requests after this time. (e.g., 2025-06-10, '2025-06-10 15:04:05', 24h)")
+	fs.StringVar(&until, "until", "", "Requests before this time.")
+	fs.IntVar(&q.Limit, "limit", 20, "Max number of results.")
+	fs.BoolVar(&full, "full", false, "Print the full system prompt, request and response.")
+	fs.Parse(args)
+
+	var err error
+	if q.Since, err = parseTime(since); err != nil {
+		log.Fatalln(err)
+	}
+	if q.Until, err = parseTime(until); err != nil {
+		log.Fatalln(err)
+	}
+
+	if _, err = os.Stat(dbPath); err != nil {
+		log.Fatalln(err)
+	}
+	s, err := store.Open(dbPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer s.Close()
+
+	exchanges, err := s.Search(&q)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, e := range exchanges {
+		color.Yellow("#%d  %s  %s  %s -> %s  %s  %s",
+			e.ID, e.RequestTime.Format("2006-01-02 15:04:05"), e.Model, e.Client, e.Server, e.Process, e.Duration().Round(time.Millisecond))
+		if len(e.Tools) > 0 {
+			fmt.Printf("    Tools: %s\n", strings.Join(e.Tools, ", "))
+		}
+		if metrics := e.Metrics(); metrics != nil {
+			fmt.Printf("    Metrics: %s\n", metrics)
+		}
+		if !full {
+			if e.System != "" {
+				fmt.Printf("    System: %s\n", truncate(e.System, 100))
+			}
+			fmt.Printf("    Response: %s\n", truncate(e.Response, 100))
+			continue
+		}
+
+		fmt.Printf("URL: %s\n", e.URL)
+		if e.System != "" {
+			color.Red("%s\n", e.System)
+		}
+		fmt.Printf("%s\n", e.Request)
+		if e.Reasoning != "" {
+			color.Cyan("%s\n", e.Reasoning)
+		}
+		color.Blue("%s\n", e.Response)
+		if e.ToolCalls != "" {
+			fmt.Printf("Tool calls: %s\n", e.ToolCalls)
+		}
+	}
+	fmt.Printf("%d results\n", len(exchanges))
+}
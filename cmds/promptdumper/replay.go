@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/LubyRuffy/localdumper/llmparser"
+	"github.com/LubyRuffy/localdumper/store"
+	"github.com/fatih/color"
+	"github.com/google/gopacket"
+	"github.com/tidwall/gjson"
+)
+
+// replayItem 一次要重放的llm调用
+type replayItem struct {
+	title    string        // 用于显示的来源
+	path     string        // 原始请求的路径和参数
+	header   http.Header   // 原始请求头
+	body     []byte        // 原始请求体
+	model    string        // 原始模型
+	response string        // 原始响应
+	duration time.Duration // 原始耗时
+}
+
+// pcapCollector 从pcap文件中收集llm请求和响应
+type pcapCollector struct {
+	mutex     sync.Mutex
+	requests  []*httpdumper.Request
+	responses map[string]*httpdumper.Response
+}
+
+func (c *pcapCollector) OnTcpSession(*httpdumper.TcpSession) {}
+
+func (c *pcapCollector) OnRequest(req *httpdumper.Request) {
+	if !llmparser.IsLLMRequest(req) {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, req)
+}
+
+func (c *pcapCollector) OnResponse(resp *httpdumper.Response) {
+	if resp.Request == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.responses[resp.Request.ID] = resp
+}
+
+// sortRequests 按请求时间排序，不同连接的回调顺序和组包的时机有关，每次读取可能不同
+// 时间相同时按连接排序，request的ID每次随机生成，不能用来排序；同一个连接中的请求保持原来的顺序
+func (c *pcapCollector) sortRequests() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	sort.SliceStable(c.requests, func(i, j int) bool {
+		a, b := c.requests[i], c.requests[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.Net.String()+" "+a.Transport.String() < b.Net.String()+" "+b.Transport.String()
+	})
+}
+
+// loadPcapItems 读取pcap文件中的llm调用，index从1开始，0表示全部
+func loadPcapItems(file, bpf string, index int) ([]*replayItem, error) {
+	c := &pcapCollector{responses: make(map[string]*httpdumper.Response)}
+	hd := httpdumper.New(&httpdumper.Config{PcapFile: file, BPFFilter: bpf}, c)
+	if err := hd.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	c.sortRequests()
+
+	if index > len(c.requests) {
+		return nil, fmt.Errorf("only %d llm requests in %s", len(c.requests), file)
+	}
+
+	var items []*replayItem
+	for i, req := range c.requests {
+		if index > 0 && i+1 != index {
+			continue
+		}
+		item := &replayItem{
+			title:  fmt.Sprintf("%s #%d", file, i+1),
+			path:   req.URL.RequestURI(),
+			header: req.Header,
+			body:   req.Body,
+			model:  gjson.GetBytes(req.Body, "model").String(),
+		}
+		if resp, ok := c.responses[req.ID]; ok {
+			item.response = responseText(resp)
+			item.duration = resp.Time.Sub(req.Time)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// loadStoreItem 读取数据库中保存的llm调用
+func loadStoreItem(dbPath string, id int64) (*replayItem, error) {
+	s, err := store.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	e, err := s.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("exchange #%d: %w", id, err)
+	}
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return nil, err
+	}
+	return &replayItem{
+		title:    fmt.Sprintf("%s #%d", dbPath, id),
+		path:     u.RequestURI(),
+		header:   http.Header{"Content-Type": []string{"application/json"}},
+		body:     []byte(e.Request),
+		model:    e.Model,
+		response: e.Response,
+		duration: e.Duration(),
+	}, nil
+}
+
+// overrideModel 替换请求体中的model字段，其他内容保持原样
+func overrideModel(body []byte, model string) []byte {
+	result := gjson.GetBytes(body, "model")
+	if !result.Exists() || result.Index <= 0 {
+		return body
+	}
+	quoted, _ := json.Marshal(model)
+	var buf bytes.Buffer
+	buf.Write(body[:result.Index])
+	buf.Write(quoted)
+	buf.Write(body[result.Index+len(result.Raw):])
+	return buf.Bytes()
+}
+
+// replay 把请求重新发送到baseURL，返回重组后的响应
+func replay(client *http.Client, baseURL string, item *replayItem, model string) (string, time.Duration, error) {
+	body := item.body
+	if model != "" {
+		body = overrideModel(body, model)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+item.path, bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	for _, key := range []string{"Content-Type", "Accept", "Authorization"} {
+		if v := item.header.Get(key); v != "" {
+			req.Header.Set(key, v)
+		}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, err
+	}
+	duration := time.Since(start)
+	if resp.StatusCode >= 400 {
+		return "", duration, fmt.Errorf("%s: %s", resp.Status, respBody)
+	}
+
+	newResp := httpdumper.NewResponse(nil, resp, gopacket.Flow{}, gopacket.Flow{})
+	newResp.SetBody(respBody)
+	return responseText(newResp), duration, nil
+}
+
+// responseText 用于对比的响应内容，流式响应中的错误追加在最后
+func responseText(resp *httpdumper.Response) string {
+	decoded := llmparser.DecodeResponse(resp)
+	if decoded.Error != nil {
+		return strings.TrimSpace(decoded.Content + "\nError: " + decoded.Error.String())
+	}
+	return decoded.Content
+}
+
+// runReplay promptdumper replay 子命令，重放保存的或者pcap中的llm请求，并和原始响应对比
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	var dbPath, pcapFile, bpf, baseURL, model string
+	var id int64
+	var index, width int
+	var timeout time.Duration
+	fs.StringVar(&dbPath, "db", "", "Replay a stored exchange from this SQLite database, use with -id.")
+	fs.Int64Var(&id, "id", 0, "Exchange ID in the database. (see: promptdumper query)")
+	fs.StringVar(&pcapFile, "r", "", "Replay llm requests extracted from this pcap file.")
+	fs.StringVar(&bpf, "f", "tcp", "BPF filter when reading the pcap file.")
+	fs.IntVar(&index, "index", 0, "Only replay the Nth llm request in the pcap file, 0 means all.")
+	fs.StringVar(&baseURL, "url", "http://127.0.0.1:11434", "Base URL of the model server to replay against.")
+	fs.StringVar(&model, "model", "", "Override the model of the request.")
+	fs.IntVar(&width, "width", 160, "Width of the side-by-side diff.")
+	fs.DurationVar(&timeout, "timeout", 10*time.Minute, "Timeout of each replayed request.")
+	fs.Parse(args)
+
+	var items []*replayItem
+	switch {
+	case dbPath != "" && pcapFile != "":
+		log.Fatalln("-db and -r can't be used together")
+	case dbPath != "":
+		if id == 0 {
+			log.Fatalln("-id is required with -db")
+		}
+		item, err := loadStoreItem(dbPath, id)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		items = append(items, item)
+	case pcapFile != "":
+		var err error
+		if items, err = loadPcapItems(pcapFile, bpf, index); err != nil {
+			log.Fatalln(err)
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	for _, item := range items {
+		newModel := item.model
+		if model != "" {
+			newModel = model
+		}
+
+		color.Yellow(strings.Repeat("=", width))
+		fmt.Printf("Replay %s: %s%s\n", item.title, baseURL, item.path)
+		response, duration, err := replay(client, baseURL, item, model)
+		if err != nil {
+			color.Red("replay failed: %v\n", err)
+			continue
+		}
+		printSideBySide(os.Stdout,
+			fmt.Sprintf("original: %s (%s)", item.model, item.duration.Round(time.Millisecond)), item.response,
+			fmt.Sprintf("replay: %s (%s)", newModel, duration.Round(time.Millisecond)), response,
+			width)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/fatih/color"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/tidwall/gjson"
+)
+
+func TestOverrideModel(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"user","content":"hi"}], "model" : "qwen3:0.6b","stream":true}`)
+	got := string(overrideModel(body, "qwen3:4b"))
+	if got != `{"messages":[{"role":"user","content":"hi"}], "model" : "qwen3:4b","stream":true}` {
+		t.Fatal(got)
+	}
+	if got = string(overrideModel([]byte(`{"prompt":"x"}`), "a")); got != `{"prompt":"x"}` {
+		t.Fatal(got)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		if r.URL.Path != "/api/chat" || !strings.Contains(buf.String(), `"model":"new"`) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(`{"message":{"role":"assistant","content":"Hello"},"done":false}` + "\n" +
+			`{"message":{"role":"assistant","content":" world"},"done":true}` + "\n"))
+	}))
+	defer srv.Close()
+
+	item := &replayItem{
+		path:   "/api/chat",
+		header: http.Header{"Content-Type": []string{"application/json"}},
+		body:   []byte(`{"model":"old","messages":[]}`),
+	}
+	response, _, err := replay(srv.Client(), srv.URL, item, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != "Hello world" {
+		t.Fatalf("unexpected response: %q", response)
+	}
+}
+
+func TestPrintSideBySide(t *testing.T) {
+	color.NoColor = true
+	var buf bytes.Buffer
+	printSideBySide(&buf, "a", "same\nold line\nend", "b", "same\nnew line\nend\nextra", 43)
+	expected := "a                    | b\n" +
+		"---------------------+---------------------\n" +
+		"same                   same\n" +
+		"old line             | new line\n" +
+		"end                    end\n" +
+		"                     > extra\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected diff:\n%s", buf.String())
+	}
+}
+
+func TestPcapCollectorOrder(t *testing.T) {
+	start := time.Now()
+	newReq := func(port int, model string, offset time.Duration) *httpdumper.Request {
+		body := `{"model":"` + model + `","messages":[]}`
+		r, _ := http.NewRequest("POST", "http://localhost:11434/api/chat", strings.NewReader(body))
+		netFlow := gopacket.NewFlow(layers.EndpointIPv4, net.IPv4(127, 0, 0, 1).To4(), net.IPv4(127, 0, 0, 1).To4())
+		tcpFlow := gopacket.NewFlow(layers.EndpointTCPPort, []byte{byte(port >> 8), byte(port)}, []byte{0x2c, 0xaa})
+		req := httpdumper.NewRequest(r, netFlow, tcpFlow)
+		req.SetBody([]byte(body))
+		req.Time = start.Add(offset)
+		return req
+	}
+
+	// 两个连接交错回调，顺序和请求时间不一致
+	c := &pcapCollector{}
+	for _, req := range []*httpdumper.Request{
+		newReq(50001, "b2", 2*time.Second),
+		newReq(50000, "a1", time.Second),
+		newReq(50000, "a3", 3*time.Second),
+		newReq(50001, "b1", time.Second),
+	} {
+		c.OnRequest(req)
+	}
+	c.sortRequests()
+
+	var models []string
+	for _, req := range c.requests {
+		models = append(models, gjson.GetBytes(req.Body, "model").String())
+	}
+	if got := strings.Join(models, ","); got != "a1,b1,b2,a3" {
+		t.Fatalf("unexpected order: %s", got)
+	}
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/displayfilter"
+	"github.com/LubyRuffy/localdumper/llmparser"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// 详情的标签页
+var tuiTabs = []string{"System", "Messages", "Tools", "Response", "Reasoning", "Raw"}
+
+// maxExchanges 界面中最多保留的llm调用，超过后丢弃最早的
+const maxExchanges = 2000
+
+const tuiHelp = "q quit  / search  f filter  esc clear  tab focus  1-6 ←→ tabs  F follow"
+
+// tui 终端界面：上面是llm调用列表，中间是详情，最下面是搜索和过滤的输入框以及状态栏
+// 抓包的goroutine通过Update提交数据，界面的goroutine在apply中统一更新
+type tui struct {
+	app    *tview.Application
+	table  *tview.Table
+	tabs   *tview.TextView
+	detail *tview.TextView
+	input  *tview.InputField
+	status *tview.TextView
+	bottom *tview.Pages
+
+	lock      sync.Mutex
+	exchanges []*exchange // 按请求时间
+	updated   []*exchange // 上次刷新之后添加或者替换的
+	removed   []string    // 上次刷新之后超过maxExchanges丢弃的请求ID
+	logLine   string      // 最近一条日志
+	changed   chan struct{}
+
+	// 以下只在界面的goroutine中访问
+	visible    []*exchange // 满足搜索和过滤条件的
+	search     string
+	filter     *displayfilter.Filter
+	inputKind  string // search或者filter
+	tab        int
+	follow     bool      // 自动选中最新的
+	refreshing bool      // refresh中选中行不改变follow
+	selected   string    // 选中的请求ID
+	shown      *exchange // 详情中显示的
+	shownTab   int
+}
+
+// newTUI 创建终端界面
+func newTUI() *tui {
+	t := &tui{
+		app:     tview.NewApplication(),
+		table:   tview.NewTable(),
+		tabs:    tview.NewTextView(),
+		detail:  tview.NewTextView(),
+		input:   tview.NewInputField(),
+		status:  tview.NewTextView(),
+		bottom:  tview.NewPages(),
+		changed: make(chan struct{}, 1),
+		follow:  true,
+	}
+
+	t.table.SetSelectable(true, false).SetFixed(1, 0).SetBorders(false)
+	t.table.SetBorder(true).SetTitle(" LLM calls ")
+	t.table.SetSelectionChangedFunc(func(row, _ int) {
+		// 用户选中最后一行时自动跟随最新的
+		if !t.refreshing {
+			t.follow = row == len(t.visible)
+		}
+		t.selectRow(row)
+	})
+	t.setHeader()
+
+	t.tabs.SetDynamicColors(true).SetRegions(true).SetWrap(false)
+	t.detail.SetDynamicColors(true).SetWrap(true).SetScrollable(true)
+	t.detail.SetBorder(true)
+	t.status.SetDynamicColors(true)
+
+	t.input.SetDoneFunc(t.inputDone)
+	t.bottom.AddPage("status", t.status, true, true)
+	t.bottom.AddPage("input", t.input, true, false)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(t.table, 0, 2, true).
+		AddItem(t.tabs, 1, 0, false).
+		AddItem(t.detail, 0, 3, false).
+		AddItem(t.bottom, 1, 0, false)
+	t.app.SetRoot(layout, true).SetInputCapture(t.onKey)
+	t.renderTabs()
+	t.renderStatus()
+	return t
+}
+
+// Update 添加或者替换一次llm调用，可以在任意goroutine中调用
+func (t *tui) Update(e *exchange) {
+	t.lock.Lock()
+	replaced := false
+	for i := len(t.exchanges) - 1; i >= 0; i-- {
+		if t.exchanges[i].ID() == e.ID() {
+			t.exchanges[i] = e
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		t.exchanges = append(t.exchanges, e)
+		if n := len(t.exchanges) - maxExchanges; n > 0 {
+			for _, old := range t.exchanges[:n] {
+				t.removed = append(t.removed, old.ID())
+			}
+			t.exchanges = t.exchanges[n:]
+		}
+	}
+	t.updated = append(t.updated, e)
+	t.lock.Unlock()
+	t.notify()
+}
+
+// Write 日志显示在状态栏，运行期间代替标准错误输出
+func (t *tui) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	t.logLine = strings.TrimSpace(string(p))
+	t.lock.Unlock()
+	t.notify()
+	return len(p), nil
+}
+
+// notify 通知界面刷新，多次通知合并为一次
+func (t *tui) notify() {
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+// tuiRefreshInterval 两次刷新之间的最短间隔，抓包很快时合并多次更新
+const tuiRefreshInterval = 100 * time.Millisecond
+
+// Run 运行界面直到用户退出
+// 日志要在开始抓包之前调用captureLog重定向，否则会破坏界面
+func (t *tui) Run() error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.changed:
+				t.app.QueueUpdateDraw(t.apply)
+			}
+			time.Sleep(tuiRefreshInterval)
+		}
+	}()
+	return t.app.Run()
+}
+
+// Stop 退出界面并恢复终端，可以在任意goroutine中调用，界面还没有运行时等到运行后再退出
+func (t *tui) Stop() {
+	go t.app.QueueUpdate(t.app.Stop)
+}
+
+// captureLog 日志显示在状态栏，返回的函数恢复标准错误输出
+func (t *tui) captureLog() (restore func()) {
+	log.SetOutput(t)
+	return func() { log.SetOutput(os.Stderr) }
+}
+
+// apply 把上次刷新之后的变化应用到列表，只对变化的调用判断搜索和过滤条件
+func (t *tui) apply() {
+	t.lock.Lock()
+	updated, removed := t.updated, t.removed
+	t.updated, t.removed = nil, nil
+	t.lock.Unlock()
+
+	for _, id := range removed {
+		if i := t.visibleIndex(id); i >= 0 {
+			t.visible = append(t.visible[:i], t.visible[i+1:]...)
+			t.table.RemoveRow(i + 1)
+		}
+	}
+	for _, e := range updated {
+		i := t.visibleIndex(e.ID())
+		switch matched := t.matches(e); {
+		case i >= 0 && matched:
+			t.visible[i] = e
+			t.setRow(i+1, e)
+		case i >= 0:
+			t.visible = append(t.visible[:i], t.visible[i+1:]...)
+			t.table.RemoveRow(i + 1)
+		case matched && !t.dropped(e, removed):
+			t.visible = append(t.visible, e)
+			t.setRow(len(t.visible), e)
+		}
+	}
+	t.restoreSelection()
+}
+
+// visibleIndex 请求在列表中的位置，不在列表中时返回-1，新的调用都在后面所以从后往前找
+func (t *tui) visibleIndex(id string) int {
+	for i := len(t.visible) - 1; i >= 0; i-- {
+		if t.visible[i].ID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// dropped 调用在同一次刷新中添加后又被丢弃了
+func (t *tui) dropped(e *exchange, removed []string) bool {
+	for _, id := range removed {
+		if id == e.ID() {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 是否满足搜索和过滤条件
+func (t *tui) matches(e *exchange) bool {
+	if t.search != "" && !e.Contains(t.search) {
+		return false
+	}
+	return t.filter == nil || e.Match(t.filter)
+}
+
+// refresh 搜索或者过滤条件变化后重新生成列表，保持选中的行
+func (t *tui) refresh() {
+	t.lock.Lock()
+	all := make([]*exchange, len(t.exchanges))
+	copy(all, t.exchanges)
+	t.updated, t.removed = nil, nil
+	t.lock.Unlock()
+
+	t.visible = t.visible[:0]
+	for _, e := range all {
+		if t.matches(e) {
+			t.visible = append(t.visible, e)
+		}
+	}
+
+	t.table.Clear()
+	t.setHeader()
+	for i, e := range t.visible {
+		t.setRow(i+1, e)
+	}
+	t.restoreSelection()
+}
+
+// restoreSelection 列表变化后重新选中之前的行，跟随时选中最新的
+func (t *tui) restoreSelection() {
+	selectedRow := 0
+	if i := t.visibleIndex(t.selected); i >= 0 {
+		selectedRow = i + 1
+	}
+	if len(t.visible) > 0 && (t.follow || selectedRow == 0) {
+		selectedRow = len(t.visible)
+	}
+	t.refreshing = true
+	t.table.Select(selectedRow, 0)
+	t.refreshing = false
+	t.selectRow(selectedRow)
+	t.renderStatus()
+}
+
+func (t *tui) setHeader() {
+	for i, title := range []string{"Time", "Client", "Model", "Latency", "Tokens", "Status"} {
+		t.table.SetCell(0, i, tview.NewTableCell(title).SetTextColor(tcell.ColorYellow).SetSelectable(false).SetExpansion(1))
+	}
+}
+
+func (t *tui) setRow(row int, e *exchange) {
+	latency := "…"
+	if e.resp != nil {
+		latency = e.Latency().Round(time.Millisecond).String()
+	}
+	color := tcell.ColorWhite
+	if e.Error() != nil {
+		color = tcell.ColorRed
+	} else if e.resp == nil {
+		color = tcell.ColorGray
+	}
+	cells := []string{e.req.Time.Format("15:04:05"), e.Client(), e.llmReq.Model, latency, e.Tokens(), e.Status()}
+	for i, text := range cells {
+		t.table.SetCell(row, i, tview.NewTableCell(tview.Escape(text)).SetTextColor(color).SetExpansion(1).SetMaxWidth(40))
+	}
+}
+
+// selectRow 选中行变化时显示对应的详情，row为0表示没有选中
+func (t *tui) selectRow(row int) {
+	var e *exchange
+	if row > 0 && row <= len(t.visible) {
+		e = t.visible[row-1]
+		t.selected = e.ID()
+	}
+	if e == t.shown && t.tab == t.shownTab {
+		return
+	}
+	// 同一个调用收到响应时保持滚动位置
+	sameCall := e != nil && t.shown != nil && e.ID() == t.shown.ID() && t.tab == t.shownTab
+	t.shown, t.shownTab = e, t.tab
+	offset, _ := t.detail.GetScrollOffset()
+	if e == nil {
+		t.detail.SetTitle("")
+		t.detail.SetText("")
+		return
+	}
+	t.detail.SetTitle(" " + tview.Escape(e.req.URL.String()) + " ")
+	t.detail.SetText(renderTab(e, t.tab))
+	if sameCall {
+		t.detail.ScrollTo(offset, 0)
+	} else {
+		t.detail.ScrollToBeginning()
+	}
+}
+
+func (t *tui) renderTabs() {
+	var b strings.Builder
+	for i, name := range tuiTabs {
+		fmt.Fprintf(&b, `["%d"] %d %s [""] `, i, i+1, name)
+	}
+	t.tabs.SetText(b.String()).Highlight(fmt.Sprint(t.tab))
+}
+
+func (t *tui) selectTab(tab int) {
+	t.tab = (tab + len(tuiTabs)) % len(tuiTabs)
+	t.renderTabs()
+	t.selectRow(t.selectedRow())
+}
+
+// selectedRow 选中的行号，没有选中时为0
+func (t *tui) selectedRow() int {
+	row, _ := t.table.GetSelection()
+	if row < 1 || row > len(t.visible) {
+		return 0
+	}
+	return row
+}
+
+func (t *tui) renderStatus() {
+	t.lock.Lock()
+	total, logLine := len(t.exchanges), t.logLine
+	t.lock.Unlock()
+
+	parts := []string{fmt.Sprintf("%d of %d calls", len(t.visible), total)}
+	if t.search != "" {
+		parts = append(parts, "search: "+t.search)
+	}
+	if t.filter != nil {
+		parts = append(parts, "filter: "+t.filter.String())
+	}
+	if t.follow {
+		parts = append(parts, "follow")
+	}
+	text := tview.Escape(strings.Join(parts, " │ "))
+	if logLine != "" {
+		text += " │ [red]" + tview.Escape(logLine) + "[-]"
+	}
+	t.status.SetText(text + " │ [gray]" + tuiHelp + "[-]")
+}
+
+// startInput 显示搜索或者过滤的输入框
+func (t *tui) startInput(kind string) {
+	t.inputKind = kind
+	if kind == "search" {
+		t.input.SetLabel("Search: ").SetText(t.search)
+	} else {
+		text := ""
+		if t.filter != nil {
+			text = t.filter.String()
+		}
+		t.input.SetLabel("Filter: ").SetText(text)
+	}
+	t.bottom.SwitchToPage("input")
+	t.app.SetFocus(t.input)
+}
+
+func (t *tui) inputDone(key tcell.Key) {
+	if key == tcell.KeyEnter {
+		text := strings.TrimSpace(t.input.GetText())
+		if t.inputKind == "search" {
+			t.search = text
+		} else if text == "" {
+			t.filter = nil
+		} else {
+			filter, err := displayfilter.Compile(text)
+			if err != nil {
+				t.input.SetLabel(fmt.Sprintf("Filter (%s): ", err))
+				return
+			}
+			t.filter = filter
+		}
+	}
+	t.bottom.SwitchToPage("status")
+	t.app.SetFocus(t.table)
+	t.refresh()
+}
+
+// onKey 全局快捷键，输入框有焦点时不处理
+func (t *tui) onKey(event *tcell.EventKey) *tcell.EventKey {
+	if t.app.GetFocus() == t.input {
+		return event
+	}
+	switch event.Key() {
+	case tcell.KeyTab, tcell.KeyBacktab:
+		if t.app.GetFocus() == t.table {
+			t.app.SetFocus(t.detail)
+		} else {
+			t.app.SetFocus(t.table)
+		}
+		return nil
+	case tcell.KeyLeft:
+		t.selectTab(t.tab - 1)
+		return nil
+	case tcell.KeyRight:
+		t.selectTab(t.tab + 1)
+		return nil
+	case tcell.KeyEscape:
+		t.search, t.filter = "", nil
+		t.refresh()
+		return nil
+	case tcell.KeyRune:
+	default:
+		return event
+	}
+
+	switch r := event.Rune(); {
+	case r == 'q':
+		t.app.Stop()
+	case r == '/':
+		t.startInput("search")
+	case r == 'f':
+		t.startInput("filter")
+	case r == 'F':
+		t.follow = !t.follow
+		t.restoreSelection()
+	case r >= '1' && int(r-'1') < len(tuiTabs):
+		t.selectTab(int(r - '1'))
+	default:
+		return event
+	}
+	return nil
+}
+
+// renderTab 详情标签页的内容，使用tview的颜色标记
+func renderTab(e *exchange, tab int) string {
+	var b strings.Builder
+	line := func(color, text string) {
+		if color != "" {
+			fmt.Fprintf(&b, "[%s]%s[-:-:-]\n", color, tview.Escape(text))
+		} else {
+			b.WriteString(tview.Escape(text) + "\n")
+		}
+	}
+	llmReq := e.llmReq
+
+	switch tuiTabs[tab] {
+	case "System":
+		prompts := llmReq.SystemPrompts()
+		if len(prompts) == 0 {
+			line("gray", "(no system prompt)")
+		}
+		for i, prompt := range prompts {
+			if i > 0 {
+				line("", "")
+			}
+			line("red", prompt)
+		}
+	case "Messages":
+		line("gray", fmt.Sprintf("%s %s  %s", e.req.Time.Format("2006-01-02 15:04:05"), e.Client(), e.req.URL))
+		if llmReq.Model != "" {
+			line("gray", "Model: "+llmReq.Model)
+		}
+		if options := llmReq.Summary(); len(options) > 0 {
+			line("gray", "Options: "+strings.Join(options, " "))
+		}
+		if thread := e.thread; thread != nil {
+			line("magenta", fmt.Sprintf("Conversation: %s, turn %d", thread.ConversationID, thread.Turn))
+		}
+		line("", "")
+		if llmReq.Prompt != "" {
+			line("blue::b", "prompt")
+			line("", llmReq.Prompt)
+		}
+		if llmReq.InputPrefix != "" || llmReq.InputSuffix != "" {
+			line("blue::b", "prefix")
+			line("", llmReq.InputPrefix)
+			line("blue::b", "suffix")
+			line("", llmReq.InputSuffix)
+		}
+		colors := map[string]string{"system": "red::b", "user": "blue::b", "assistant": "green::b", "tool": "yellow::b"}
+		for _, msg := range llmReq.Messages {
+			color, ok := colors[msg.Role]
+			if !ok {
+				color = "white::b"
+			}
+			line(color, msg.Role)
+			if msg.Reasoning != "" {
+				line("cyan", msg.Reasoning)
+			}
+			if msg.Content != "" {
+				line("", msg.Content)
+			}
+			if msg.ToolCalls != nil {
+				line("yellow", msg.ToolCallsString())
+			}
+			for _, part := range msg.Media() {
+				line("magenta", part.Summary())
+			}
+			line("", "")
+		}
+	case "Tools":
+		if len(llmReq.Tools) == 0 {
+			line("gray", "(no tools)")
+		}
+		for _, tool := range llmReq.Tools {
+			line("", tool.Render())
+		}
+	case "Response":
+		if e.decoded == nil {
+			line("gray", "(waiting for response)")
+			break
+		}
+		if e.decoded.Content != "" {
+			line("", e.decoded.Content)
+		}
+		if len(e.decoded.ToolCalls) > 0 {
+			msg := llmparser.LLMMessage{ToolCalls: e.decoded.ToolCalls}
+			line("yellow", msg.ToolCallsString())
+		}
+		if err := e.decoded.Error; err != nil {
+			line("red::b", "Error: "+err.String())
+		}
+		line("", "")
+		if e.decoded.FinishReason != "" {
+			line("gray", "Finish reason: "+e.decoded.FinishReason)
+		}
+		if e.metrics != nil {
+			line("magenta", "Metrics: "+e.metrics.String())
+		}
+	case "Reasoning":
+		if e.decoded == nil || e.decoded.Reasoning == "" {
+			line("gray", "(no reasoning)")
+			break
+		}
+		line("cyan", e.decoded.Reasoning)
+	case "Raw":
+		line("", e.RawRequest())
+		if raw := e.RawResponse(); raw != "" {
+			line("", "")
+			line("green", raw)
+		}
+	}
+	return b.String()
+}
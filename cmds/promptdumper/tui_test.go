@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/displayfilter"
+)
+
+func TestTUIApply(t *testing.T) {
+	ui := newTUI()
+	filter, err := displayfilter.Compile(`status == 200`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ui.filter = filter
+
+	// 还没有响应时不满足过滤条件，收到响应后追加到列表
+	e := newTestExchange(t)
+	ui.Update(&exchange{req: e.req, llmReq: e.llmReq})
+	ui.apply()
+	if len(ui.visible) != 0 || ui.table.GetRowCount() != 1 {
+		t.Fatalf("pending exchange should be filtered: %d rows", ui.table.GetRowCount())
+	}
+	ui.Update(e)
+	other := newTestExchange(t)
+	ui.Update(other)
+	ui.apply()
+	if len(ui.visible) != 2 || ui.table.GetRowCount() != 3 || ui.visible[1] != other {
+		t.Fatalf("unexpected rows: %d", ui.table.GetRowCount())
+	}
+	if ui.selected != other.ID() {
+		t.Fatal("should follow the newest exchange")
+	}
+
+	// 替换后不再满足条件的从列表中移除
+	ui.Update(&exchange{req: e.req, llmReq: e.llmReq})
+	ui.apply()
+	if len(ui.visible) != 1 || ui.table.GetRowCount() != 2 || ui.visible[0] != other {
+		t.Fatalf("unexpected rows after replace: %d", ui.table.GetRowCount())
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/displayfilter"
+	"github.com/LubyRuffy/localdumper/llmparser"
+)
+
+//go:embed web
+var webAssets embed.FS
+
+// webUI 浏览器界面，静态页面打包在程序中，新的llm调用通过SSE推送
+type webUI struct {
+	addr   string // 监听地址，只有端口时只监听127.0.0.1
+	server *http.Server
+
+	lock        sync.Mutex
+	exchanges   []*exchange // 按请求时间
+	pending     []*exchange // 等待推送给SSE连接的
+	subscribers map[*webSubscriber]struct{}
+	changed     chan struct{}
+	done        chan struct{} // Stop时关闭
+	stopOnce    sync.Once
+}
+
+// webSubscriber 一个SSE连接，只推送满足搜索和过滤条件的
+type webSubscriber struct {
+	query  webQuery
+	events chan *exchange
+}
+
+// webQuery 搜索和过滤条件，q是全文搜索，filter是显示过滤器表达式
+type webQuery struct {
+	search string
+	filter *displayfilter.Filter
+}
+
+func parseWebQuery(r *http.Request) (webQuery, error) {
+	q := webQuery{search: strings.TrimSpace(r.URL.Query().Get("q"))}
+	if expr := strings.TrimSpace(r.URL.Query().Get("filter")); expr != "" {
+		filter, err := displayfilter.Compile(expr)
+		if err != nil {
+			return q, err
+		}
+		q.filter = filter
+	}
+	return q, nil
+}
+
+func (q webQuery) match(e *exchange) bool {
+	if q.search != "" && !e.Contains(q.search) {
+		return false
+	}
+	return q.filter == nil || e.Match(q.filter)
+}
+
+// webSummary 列表中的一次llm调用
+type webSummary struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Client    string    `json:"client"`
+	Model     string    `json:"model"`
+	URL       string    `json:"url"`
+	Provider  string    `json:"provider,omitempty"`
+	Pending   bool      `json:"pending"` // 还没有响应
+	LatencyMs int64     `json:"latency_ms"`
+	Tokens    string    `json:"tokens,omitempty"`
+	Status    int       `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// webMessage 详情中的一条消息，图片等只显示摘要
+type webMessage struct {
+	Role      string              `json:"role"`
+	Content   string              `json:"content,omitempty"`
+	Reasoning string              `json:"reasoning,omitempty"`
+	ToolCalls []llmparser.LLMTool `json:"tool_calls,omitempty"`
+	Media     []string            `json:"media,omitempty"`
+}
+
+// webTool 详情中的一个工具定义
+type webTool struct {
+	Name       string            `json:"name"`
+	Rendered   string            `json:"rendered"` // 参数显示为树
+	Definition llmparser.LLMTool `json:"definition"`
+}
+
+// webDetail 详情
+type webDetail struct {
+	webSummary
+	System       []string            `json:"system"`
+	Options      []string            `json:"options,omitempty"`
+	Conversation string              `json:"conversation,omitempty"`
+	Turn         int                 `json:"turn,omitempty"`
+	Prompt       string              `json:"prompt,omitempty"`
+	Messages     []webMessage        `json:"messages"`
+	Tools        []webTool           `json:"tools"`
+	Response     string              `json:"response"`
+	Reasoning    string              `json:"reasoning,omitempty"`
+	ToolCalls    []llmparser.LLMTool `json:"tool_calls,omitempty"`
+	FinishReason string              `json:"finish_reason,omitempty"`
+	Metrics      string              `json:"metrics,omitempty"`
+	RawRequest   string              `json:"raw_request"`
+	RawResponse  string              `json:"raw_response,omitempty"`
+}
+
+func newWebSummary(e *exchange) webSummary {
+	s := webSummary{
+		ID:        e.ID(),
+		Time:      e.req.Time,
+		Client:    e.Client(),
+		Model:     e.llmReq.Model,
+		URL:       e.req.URL.String(),
+		Provider:  llmparser.DetectProvider(e.req).Name(),
+		Pending:   e.resp == nil,
+		LatencyMs: e.Latency().Milliseconds(),
+		Tokens:    e.Tokens(),
+	}
+	if e.resp != nil && e.resp.Response != nil {
+		s.Status = e.resp.StatusCode
+	}
+	if err := e.Error(); err != nil {
+		s.Error = err.String()
+	}
+	return s
+}
+
+func newWebDetail(e *exchange) *webDetail {
+	llmReq := e.llmReq
+	d := &webDetail{
+		webSummary:  newWebSummary(e),
+		System:      llmReq.SystemPrompts(),
+		Options:     llmReq.Summary(),
+		Prompt:      llmReq.Prompt,
+		Messages:    []webMessage{},
+		Tools:       []webTool{},
+		RawRequest:  e.RawRequest(),
+		RawResponse: e.RawResponse(),
+	}
+	if e.thread != nil {
+		d.Conversation, d.Turn = e.thread.ConversationID, e.thread.Turn
+	}
+	for _, msg := range llmReq.Messages {
+		m := webMessage{Role: msg.Role, Content: msg.Content, Reasoning: msg.Reasoning, ToolCalls: msg.ToolCalls}
+		for _, part := range msg.Media() {
+			m.Media = append(m.Media, part.Summary())
+		}
+		d.Messages = append(d.Messages, m)
+	}
+	for _, tool := range llmReq.Tools {
+		d.Tools = append(d.Tools, webTool{Name: tool.Function.Name, Rendered: tool.Render(), Definition: tool})
+	}
+	if e.decoded != nil {
+		d.Response, d.Reasoning = e.decoded.Content, e.decoded.Reasoning
+		d.ToolCalls, d.FinishReason = e.decoded.ToolCalls, e.decoded.FinishReason
+	}
+	if e.metrics != nil {
+		d.Metrics = e.metrics.String()
+	}
+	return d
+}
+
+// newWebUI 创建浏览器界面，addr为监听地址，比如 :8765
+func newWebUI(addr string) *webUI {
+	w := &webUI{
+		addr:        listenAddr(addr),
+		subscribers: make(map[*webSubscriber]struct{}),
+		changed:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	w.server = &http.Server{Addr: w.addr, Handler: w.handler()}
+	go w.dispatch()
+	return w
+}
+
+// listenAddr 只有端口时只监听本机，请求中有提示词和密钥，不能默认暴露给局域网
+func listenAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "127.0.0.1" + addr
+	}
+	return addr
+}
+
+// Update 添加或者替换一次llm调用，推送由dispatch完成，不会拖慢抓包的goroutine
+func (w *webUI) Update(e *exchange) {
+	w.lock.Lock()
+	replaced := false
+	for i := len(w.exchanges) - 1; i >= 0; i-- {
+		if w.exchanges[i].ID() == e.ID() {
+			w.exchanges[i] = e
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		w.exchanges = append(w.exchanges, e)
+		if len(w.exchanges) > maxExchanges {
+			w.exchanges = w.exchanges[len(w.exchanges)-maxExchanges:]
+		}
+	}
+
+	w.pending = append(w.pending, e)
+	w.lock.Unlock()
+
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch 把新的llm调用推送给满足条件的SSE连接，全文搜索比较慢，在单独的goroutine中判断
+func (w *webUI) dispatch() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.changed:
+		}
+
+		w.lock.Lock()
+		pending := w.pending
+		w.pending = nil
+		subscribers := make([]*webSubscriber, 0, len(w.subscribers))
+		for sub := range w.subscribers {
+			subscribers = append(subscribers, sub)
+		}
+		w.lock.Unlock()
+
+		for _, e := range pending {
+			for _, sub := range subscribers {
+				if sub.query.match(e) {
+					w.send(sub, e)
+				}
+			}
+		}
+	}
+}
+
+// send 推送给一个SSE连接，太慢的连接直接断开，浏览器重连后会重新加载列表
+func (w *webUI) send(sub *webSubscriber, e *exchange) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, ok := w.subscribers[sub]; !ok {
+		return
+	}
+	select {
+	case sub.events <- e:
+	default:
+		delete(w.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Run 启动http服务
+func (w *webUI) Run() error {
+	log.Printf("Web UI listening on http://%s\n", w.addr)
+	if err := w.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Stop 关闭http服务
+func (w *webUI) Stop() {
+	w.stopOnce.Do(func() { close(w.done) })
+	w.server.Close()
+}
+
+func (w *webUI) handler() http.Handler {
+	static, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServer(http.FS(static)))
+	mux.HandleFunc("GET /api/exchanges", w.handleList)
+	mux.HandleFunc("GET /api/exchanges/{id}", w.handleDetail)
+	mux.HandleFunc("GET /api/events", w.handleEvents)
+	return w.checkHost(mux)
+}
+
+// checkHost 只接受访问本机或者明确指定的监听地址，防止DNS重绑定后其他网站读取请求内容
+func (w *webUI) checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !w.allowedHost(r.Host) {
+			http.Error(rw, "invalid host "+r.Host, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (w *webUI) allowedHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	switch strings.ToLower(host) {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	// 监听0.0.0.0时不知道会用哪个地址访问，只接受本机
+	listen, _, _ := net.SplitHostPort(w.addr)
+	if ip := net.ParseIP(listen); listen == "" || ip != nil && ip.IsUnspecified() {
+		return false
+	}
+	return strings.EqualFold(host, listen)
+}
+
+func writeJSON(rw http.ResponseWriter, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(rw)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// handleList 满足条件的llm调用列表
+func (w *webUI) handleList(rw http.ResponseWriter, r *http.Request) {
+	q, err := parseWebQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.lock.Lock()
+	all := make([]*exchange, len(w.exchanges))
+	copy(all, w.exchanges)
+	w.lock.Unlock()
+
+	list := []webSummary{}
+	for _, e := range all {
+		if q.match(e) {
+			list = append(list, newWebSummary(e))
+		}
+	}
+	writeJSON(rw, list)
+}
+
+// handleDetail 一次llm调用的详情
+func (w *webUI) handleDetail(rw http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var found *exchange
+	w.lock.Lock()
+	for _, e := range w.exchanges {
+		if e.ID() == id {
+			found = e
+			break
+		}
+	}
+	w.lock.Unlock()
+	if found == nil {
+		http.NotFound(rw, r)
+		return
+	}
+	writeJSON(rw, newWebDetail(found))
+}
+
+// handleEvents SSE推送新的和收到响应的llm调用，事件名为exchange，数据为列表中的一项
+func (w *webUI) handleEvents(rw http.ResponseWriter, r *http.Request) {
+	q, err := parseWebQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := &webSubscriber{query: q, events: make(chan *exchange, 64)}
+	w.lock.Lock()
+	w.subscribers[sub] = struct{}{}
+	w.lock.Unlock()
+	defer func() {
+		w.lock.Lock()
+		if _, ok := w.subscribers[sub]; ok {
+			delete(w.subscribers, sub)
+			close(sub.events)
+		}
+		w.lock.Unlock()
+	}()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(rw, ": connected\n\n")
+	flusher.Flush()
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(rw, ": ping\n\n")
+		case e, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(newWebSummary(e))
+			fmt.Fprintf(rw, "event: exchange\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,614 @@
+'use strict';
+
+// 界面文字
+const messages = {
+  en: {
+    search: 'Search text…',
+    filter: 'Display filter, e.g. model == "qwen3" && len(tools) > 0',
+    follow: 'Follow',
+    theme: 'Theme',
+    themeAuto: 'Auto',
+    themeLight: 'Light',
+    themeDark: 'Dark',
+    language: 'Language',
+    time: 'Time',
+    client: 'Client',
+    model: 'Model',
+    latency: 'Latency',
+    tokens: 'Tokens',
+    status: 'Status',
+    calls: '{n} calls',
+    connected: 'Live',
+    disconnected: 'Disconnected, reconnecting…',
+    tabSystem: 'System',
+    tabMessages: 'Messages',
+    tabTools: 'Tools',
+    tabResponse: 'Response',
+    tabReasoning: 'Reasoning',
+    tabRaw: 'Raw',
+    selectCall: 'Select a call to see its details',
+    noCalls: 'No llm calls captured yet',
+    noSystem: 'No system prompt',
+    noMessages: 'No messages',
+    noTools: 'No tools',
+    waiting: 'Waiting for the response…',
+    noReasoning: 'No reasoning',
+    markdown: 'Markdown',
+    plain: 'Plain text',
+    options: 'Options',
+    conversation: 'Conversation {id}, turn {turn}',
+    prompt: 'Prompt',
+    toolCalls: 'Tool calls',
+    error: 'Error',
+    finishReason: 'Finish reason',
+    metrics: 'Metrics',
+    definition: 'Definition',
+    request: 'Request',
+    response: 'Response',
+    reasoning: 'Reasoning',
+  },
+  zh: {
+    search: '搜索文本…',
+    filter: '显示过滤器，比如 model == "qwen3" && len(tools) > 0',
+    follow: '跟随最新',
+    theme: '主题',
+    themeAuto: '跟随系统',
+    themeLight: '浅色',
+    themeDark: '深色',
+    language: '语言',
+    time: '时间',
+    client: '客户端',
+    model: '模型',
+    latency: '耗时',
+    tokens: 'Token',
+    status: '状态',
+    calls: '{n} 次调用',
+    connected: '实时',
+    disconnected: '连接断开，正在重连…',
+    tabSystem: '系统提示词',
+    tabMessages: '消息',
+    tabTools: '工具',
+    tabResponse: '响应',
+    tabReasoning: '思考过程',
+    tabRaw: '原始HTTP',
+    selectCall: '选择一次调用查看详情',
+    noCalls: '还没有抓到llm调用',
+    noSystem: '没有系统提示词',
+    noMessages: '没有消息',
+    noTools: '没有工具',
+    waiting: '等待响应…',
+    noReasoning: '没有思考过程',
+    markdown: 'Markdown',
+    plain: '纯文本',
+    options: '参数',
+    conversation: '会话 {id}，第 {turn} 轮',
+    prompt: 'Prompt',
+    toolCalls: '工具调用',
+    error: '错误',
+    finishReason: '结束原因',
+    metrics: '性能指标',
+    definition: '定义',
+    request: '请求',
+    response: '响应',
+    reasoning: '思考过程',
+  },
+};
+
+const tabs = ['System', 'Messages', 'Tools', 'Response', 'Reasoning', 'Raw'];
+
+const state = {
+  lang: localStorage.getItem('lang') || (navigator.language.startsWith('zh') ? 'zh' : 'en'),
+  theme: localStorage.getItem('theme') || 'auto',
+  markdown: localStorage.getItem('format') !== 'plain',
+  tab: 'Messages',
+  list: [],
+  selected: null,
+  detail: null,
+  follow: true,
+  source: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+function t(key, vars) {
+  let s = (messages[state.lang] || messages.en)[key] || messages.en[key] || key;
+  for (const [k, v] of Object.entries(vars || {})) {
+    s = s.replace('{' + k + '}', v);
+  }
+  return s;
+}
+
+function escapeHTML(s) {
+  return String(s).replace(/[&<>"']/g, (c) => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
+}
+
+// ---- 格式化和高亮 ----
+
+// prettyJSON 合法的json格式化输出，否则返回null
+function prettyJSON(text) {
+  try {
+    return JSON.stringify(JSON.parse(text), null, 2);
+  } catch (e) {
+    return null;
+  }
+}
+
+function highlightJSON(text) {
+  const re = /("(?:\\.|[^"\\])*")(\s*:)?|\b(true|false|null)\b|(-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?)/g;
+  let out = '';
+  let last = 0;
+  let m;
+  while ((m = re.exec(text))) {
+    out += escapeHTML(text.slice(last, m.index));
+    if (m[1]) {
+      out += `<span class="${m[2] ? 'j-key' : 'j-str'}">${escapeHTML(m[1])}</span>` + (m[2] ? escapeHTML(m[2]) : '');
+    } else if (m[3]) {
+      out += `<span class="j-lit">${m[3]}</span>`;
+    } else {
+      out += `<span class="j-num">${m[4]}</span>`;
+    }
+    last = re.lastIndex;
+  }
+  return out + escapeHTML(text.slice(last));
+}
+
+function jsonBlock(value) {
+  return `<pre class="code">${highlightJSON(JSON.stringify(value, null, 2))}</pre>`;
+}
+
+// codeBlock 代码块，json会格式化和高亮
+function codeBlock(code, lang) {
+  const pretty = (lang === '' || lang === 'json') ? prettyJSON(code) : null;
+  return `<pre class="code"><code>${pretty ? highlightJSON(pretty) : escapeHTML(code)}</code></pre>`;
+}
+
+function inlineMarkdown(text) {
+  const codes = [];
+  let s = escapeHTML(text).replace(/`([^`]+)`/g, (_, c) => {
+    codes.push(c);
+    return `\u0000${codes.length - 1}\u0000`;
+  });
+  s = s.replace(/\*\*([^*]+)\*\*/g, '<strong>$1</strong>')
+    .replace(/(^|[^*\w])\*([^*\s][^*]*)\*/g, '$1<em>$2</em>')
+    .replace(/\[([^\]]+)\]\((https?:\/\/[^\s)]+)\)/g, '<a href="$2" target="_blank" rel="noopener noreferrer">$1</a>');
+  return s.replace(/\u0000(\d+)\u0000/g, (_, n) => `<code>${codes[n]}</code>`);
+}
+
+// renderMarkdown 常用的markdown语法：标题、列表、引用、代码块、行内代码、粗体、斜体和链接，html会被转义
+function renderMarkdown(src) {
+  const lines = src.replace(/\r\n?/g, '\n').split('\n');
+  let html = '';
+  let para = [];
+  let list = null;
+  const flushPara = () => {
+    if (para.length) {
+      html += '<p>' + inlineMarkdown(para.join('\n')).replace(/\n/g, '<br>') + '</p>';
+      para = [];
+    }
+  };
+  const closeList = () => {
+    if (list) {
+      html += `</${list}>`;
+      list = null;
+    }
+  };
+
+  for (let i = 0; i < lines.length;) {
+    const line = lines[i];
+    const fence = line.match(/^\s*(```|~~~)\s*([\w+-]*)/);
+    if (fence) {
+      flushPara();
+      closeList();
+      const code = [];
+      for (i++; i < lines.length && !lines[i].trim().startsWith(fence[1]); i++) {
+        code.push(lines[i]);
+      }
+      i++;
+      html += codeBlock(code.join('\n'), fence[2].toLowerCase());
+      continue;
+    }
+    const heading = line.match(/^(#{1,6})\s+(.*)$/);
+    if (heading) {
+      flushPara();
+      closeList();
+      const n = heading[1].length;
+      html += `<h${n}>${inlineMarkdown(heading[2])}</h${n}>`;
+      i++;
+      continue;
+    }
+    const item = line.match(/^\s*([-*+]|\d+[.)])\s+(.*)$/);
+    if (item) {
+      flushPara();
+      const type = /\d/.test(item[1]) ? 'ol' : 'ul';
+      if (list !== type) {
+        closeList();
+        html += `<${type}>`;
+        list = type;
+      }
+      html += `<li>${inlineMarkdown(item[2])}</li>`;
+      i++;
+      continue;
+    }
+    if (/^\s*>/.test(line)) {
+      flushPara();
+      closeList();
+      const quote = [];
+      for (; i < lines.length && /^\s*>/.test(lines[i]); i++) {
+        quote.push(lines[i].replace(/^\s*>\s?/, ''));
+      }
+      html += `<blockquote>${renderMarkdown(quote.join('\n'))}</blockquote>`;
+      continue;
+    }
+    if (/^\s*(-{3,}|\*{3,}|_{3,})\s*$/.test(line)) {
+      flushPara();
+      closeList();
+      html += '<hr>';
+      i++;
+      continue;
+    }
+    if (line.trim() === '') {
+      flushPara();
+      closeList();
+      i++;
+      continue;
+    }
+    closeList();
+    para.push(line);
+    i++;
+  }
+  flushPara();
+  closeList();
+  return html;
+}
+
+// text 消息和响应的文本，根据设置显示为markdown或者纯文本，整段是json时格式化
+function text(s, cls) {
+  const pretty = prettyJSON(s);
+  if (pretty && /^\s*[[{]/.test(s)) {
+    return `<pre class="code ${cls || ''}">${highlightJSON(pretty)}</pre>`;
+  }
+  if (state.markdown) {
+    return `<div class="md ${cls || ''}">${renderMarkdown(s)}</div>`;
+  }
+  return `<div class="plain ${cls || ''}">${escapeHTML(s)}</div>`;
+}
+
+// rawHTTP 原始http：头部名称高亮，json body格式化，ndjson和sse的每一行json高亮
+function rawHTTP(raw) {
+  const split = raw.indexOf('\n\n');
+  const head = split < 0 ? raw : raw.slice(0, split);
+  const body = split < 0 ? '' : raw.slice(split + 2);
+  const headHTML = head.split('\n').map((line, i) => {
+    const colon = line.indexOf(':');
+    if (i === 0 || colon < 0) {
+      return `<strong>${escapeHTML(line)}</strong>`;
+    }
+    return `<span class="h-name">${escapeHTML(line.slice(0, colon))}</span>${escapeHTML(line.slice(colon))}`;
+  }).join('\n');
+
+  let bodyHTML;
+  const pretty = prettyJSON(body);
+  if (pretty) {
+    bodyHTML = highlightJSON(pretty);
+  } else {
+    bodyHTML = body.split('\n').map((line) => {
+      const m = line.match(/^(data:\s?)?(\s*[[{].*)$/);
+      if (m && prettyJSON(m[2]) !== null) {
+        return escapeHTML(m[1] || '') + highlightJSON(m[2]);
+      }
+      return escapeHTML(line);
+    }).join('\n');
+  }
+  return `<pre class="code">${headHTML}\n\n${bodyHTML}</pre>`;
+}
+
+// ---- 列表 ----
+
+function query() {
+  const params = new URLSearchParams();
+  const q = $('search').value.trim();
+  const filter = $('filter').value.trim();
+  if (q) params.set('q', q);
+  if (filter) params.set('filter', filter);
+  return params.toString();
+}
+
+function formatLatency(item) {
+  if (item.pending) return '…';
+  if (item.latency_ms < 1000) return item.latency_ms + 'ms';
+  return (item.latency_ms / 1000).toFixed(1) + 's';
+}
+
+function renderRow(item) {
+  const tr = document.createElement('tr');
+  tr.dataset.id = item.id;
+  tr.className = [item.pending ? 'pending' : '', item.error ? 'error' : '', item.id === state.selected ? 'selected' : ''].join(' ');
+  tr.title = item.error || item.url;
+  const time = new Date(item.time).toLocaleTimeString(state.lang === 'zh' ? 'zh-CN' : 'en-GB', { hour12: false });
+  for (const cell of [time, item.client, item.model, formatLatency(item), item.tokens || '', item.status || '']) {
+    const td = document.createElement('td');
+    td.textContent = cell;
+    tr.appendChild(td);
+  }
+  tr.addEventListener('click', () => select(item.id, false));
+  return tr;
+}
+
+function renderList() {
+  const rows = $('rows');
+  rows.replaceChildren(...state.list.map(renderRow));
+  $('count').textContent = t('calls', { n: state.list.length });
+  if (state.list.length === 0) {
+    const tr = document.createElement('tr');
+    tr.innerHTML = `<td colspan="6" class="empty">${escapeHTML(t('noCalls'))}</td>`;
+    rows.appendChild(tr);
+  }
+}
+
+// upsert 新的调用添加到最后，收到响应的替换
+function upsert(item) {
+  const i = state.list.findIndex((x) => x.id === item.id);
+  const rows = $('rows');
+  if (i >= 0) {
+    state.list[i] = item;
+    const old = rows.querySelector(`tr[data-id="${CSS.escape(item.id)}"]`);
+    if (old) old.replaceWith(renderRow(item));
+  } else {
+    if (state.list.length === 0) rows.replaceChildren();
+    state.list.push(item);
+    rows.appendChild(renderRow(item));
+    $('count').textContent = t('calls', { n: state.list.length });
+  }
+  if (state.follow && i < 0) {
+    select(item.id, true);
+  } else if (item.id === state.selected) {
+    loadDetail(true);
+  }
+}
+
+async function loadList() {
+  const resp = await fetch('api/exchanges?' + query());
+  if (!resp.ok) {
+    showFilterError(await resp.text());
+    return;
+  }
+  showFilterError('');
+  state.list = await resp.json();
+  renderList();
+  if (state.follow && state.list.length > 0) {
+    select(state.list[state.list.length - 1].id, true);
+  } else if (state.selected && !state.list.some((x) => x.id === state.selected)) {
+    select(null);
+  }
+}
+
+function showFilterError(message) {
+  $('filter-error').textContent = message.trim();
+  $('filter-error').hidden = !message;
+  $('filter').classList.toggle('invalid', !!message);
+}
+
+// connect 订阅新的调用，搜索和过滤条件变化时重新连接，连接成功后重新加载列表
+function connect() {
+  if (state.source) state.source.close();
+  const source = new EventSource('api/events?' + query());
+  state.source = source;
+  source.onopen = () => {
+    setConnected(true);
+    loadList();
+  };
+  source.onerror = () => {
+    setConnected(false);
+    // 过滤器错误时服务端返回400，不会自动重连
+    if (source.readyState === EventSource.CLOSED) loadList();
+  };
+  source.addEventListener('exchange', (e) => upsert(JSON.parse(e.data)));
+}
+
+function setConnected(on) {
+  $('conn').classList.toggle('on', on);
+  $('conn').title = t(on ? 'connected' : 'disconnected');
+}
+
+// ---- 详情 ----
+
+async function select(id, auto) {
+  // 手动选中其他调用时停止跟随
+  const last = state.list.length > 0 ? state.list[state.list.length - 1].id : null;
+  if (!auto && id !== last) {
+    state.follow = false;
+    $('follow').checked = false;
+  }
+  state.selected = id;
+  for (const tr of $('rows').children) {
+    tr.classList.toggle('selected', tr.dataset.id === id);
+    if (tr.dataset.id === id) tr.scrollIntoView({ block: 'nearest' });
+  }
+  await loadDetail(false);
+}
+
+async function loadDetail(keepScroll) {
+  const id = state.selected;
+  if (!id) {
+    state.detail = null;
+    renderDetail(false);
+    return;
+  }
+  const resp = await fetch('api/exchanges/' + encodeURIComponent(id));
+  if (id !== state.selected) return;
+  state.detail = resp.ok ? await resp.json() : null;
+  renderDetail(keepScroll);
+}
+
+function renderTabs() {
+  const d = state.detail;
+  const counts = {
+    System: d ? d.system.length : 0,
+    Messages: d ? d.messages.length : 0,
+    Tools: d ? d.tools.length : 0,
+  };
+  $('tabs').replaceChildren(...tabs.map((name) => {
+    const b = document.createElement('button');
+    b.type = 'button';
+    b.className = name === state.tab ? 'active' : '';
+    b.innerHTML = escapeHTML(t('tab' + name)) + (counts[name] ? `<span class="badge">${counts[name]}</span>` : '');
+    b.addEventListener('click', () => {
+      state.tab = name;
+      renderDetail(false);
+    });
+    return b;
+  }));
+}
+
+function empty(key) {
+  return `<div class="empty">${escapeHTML(t(key))}</div>`;
+}
+
+function toolCalls(calls) {
+  if (!calls || calls.length === 0) return '';
+  return `<h4>${escapeHTML(t('toolCalls'))}</h4>` + calls.map((c) => jsonBlock({ name: c.function.name, arguments: c.function.arguments })).join('');
+}
+
+function renderTab(d) {
+  switch (state.tab) {
+    case 'System':
+      if (d.system.length === 0) return empty('noSystem');
+      return d.system.map((s) => `<div class="message system">${text(s)}</div>`).join('');
+    case 'Messages': {
+      let html = '<div class="meta">';
+      html += `<div>${escapeHTML(new Date(d.time).toLocaleString())}  ${escapeHTML(d.client)}  ${escapeHTML(d.provider || '')}</div>`;
+      if (d.options && d.options.length) html += `<div>${escapeHTML(t('options'))}: ${escapeHTML(d.options.join(' '))}</div>`;
+      if (d.conversation) html += `<div>${escapeHTML(t('conversation', { id: d.conversation, turn: d.turn }))}</div>`;
+      html += '</div>';
+      if (d.prompt) html += `<div class="message user"><div class="role user">${escapeHTML(t('prompt'))}</div>${text(d.prompt)}</div>`;
+      if (d.messages.length === 0 && !d.prompt) return html + empty('noMessages');
+      for (const m of d.messages) {
+        const role = escapeHTML(m.role);
+        html += `<div class="message ${role}"><div class="role ${role}">${role}</div>`;
+        if (m.reasoning) html += `<details><summary>${escapeHTML(t('reasoning'))}</summary>${text(m.reasoning, 'reasoning')}</details>`;
+        if (m.content) html += text(m.content);
+        html += toolCalls(m.tool_calls);
+        for (const media of m.media || []) html += `<div class="media">${escapeHTML(media)}</div>`;
+        html += '</div>';
+      }
+      return html;
+    }
+    case 'Tools':
+      if (d.tools.length === 0) return empty('noTools');
+      return d.tools.map((tool) => `<h4>${escapeHTML(tool.name)}</h4><pre>${escapeHTML(tool.rendered)}</pre>` +
+        `<details><summary>${escapeHTML(t('definition'))}</summary>${jsonBlock(tool.definition)}</details>`).join('');
+    case 'Response': {
+      if (d.pending) return empty('waiting');
+      let html = '';
+      if (d.response) html += text(d.response);
+      html += toolCalls(d.tool_calls);
+      if (d.error) html += `<p class="error-text">${escapeHTML(t('error'))}: ${escapeHTML(d.error)}</p>`;
+      html += '<div class="meta">';
+      if (d.finish_reason) html += `<div>${escapeHTML(t('finishReason'))}: ${escapeHTML(d.finish_reason)}</div>`;
+      if (d.metrics) html += `<div>${escapeHTML(t('metrics'))}: ${escapeHTML(d.metrics)}</div>`;
+      return html + '</div>';
+    }
+    case 'Reasoning':
+      if (!d.reasoning) return empty(d.pending ? 'waiting' : 'noReasoning');
+      return text(d.reasoning, 'reasoning');
+    case 'Raw':
+      return `<h4>${escapeHTML(t('request'))}</h4>${rawHTTP(d.raw_request)}` +
+        (d.raw_response ? `<h4>${escapeHTML(t('response'))}</h4>${rawHTTP(d.raw_response)}` : '');
+  }
+  return '';
+}
+
+function renderDetail(keepScroll) {
+  const content = $('content');
+  const scroll = content.scrollTop;
+  const d = state.detail;
+  renderTabs();
+  $('detail-title').textContent = d ? d.url : '';
+  $('format').textContent = t(state.markdown ? 'plain' : 'markdown');
+  content.innerHTML = d ? renderTab(d) : empty('selectCall');
+  content.scrollTop = keepScroll ? scroll : 0;
+}
+
+// ---- 主题和语言 ----
+
+const darkQuery = window.matchMedia('(prefers-color-scheme: dark)');
+
+function applyTheme() {
+  const theme = state.theme === 'auto' ? (darkQuery.matches ? 'dark' : 'light') : state.theme;
+  document.documentElement.dataset.theme = theme;
+  $('theme').value = state.theme;
+}
+
+function applyLanguage() {
+  document.documentElement.lang = state.lang;
+  $('lang').value = state.lang;
+  for (const el of document.querySelectorAll('[data-i18n]')) el.textContent = t(el.dataset.i18n);
+  for (const el of document.querySelectorAll('[data-i18n-placeholder]')) el.placeholder = t(el.dataset.i18nPlaceholder);
+  for (const el of document.querySelectorAll('[data-i18n-title]')) el.title = t(el.dataset.i18nTitle);
+  setConnected($('conn').classList.contains('on'));
+  renderList();
+  renderDetail(true);
+}
+
+function debounce(fn, ms) {
+  let timer;
+  return () => {
+    clearTimeout(timer);
+    timer = setTimeout(fn, ms);
+  };
+}
+
+function init() {
+  $('theme').addEventListener('change', (e) => {
+    state.theme = e.target.value;
+    localStorage.setItem('theme', state.theme);
+    applyTheme();
+  });
+  darkQuery.addEventListener('change', applyTheme);
+  $('lang').addEventListener('change', (e) => {
+    state.lang = e.target.value;
+    localStorage.setItem('lang', state.lang);
+    applyLanguage();
+  });
+  $('format').addEventListener('click', () => {
+    state.markdown = !state.markdown;
+    localStorage.setItem('format', state.markdown ? 'markdown' : 'plain');
+    renderDetail(true);
+  });
+  $('follow').addEventListener('change', (e) => {
+    state.follow = e.target.checked;
+    if (state.follow && state.list.length > 0) select(state.list[state.list.length - 1].id, true);
+  });
+  $('search').addEventListener('input', debounce(connect, 300));
+  $('filter').addEventListener('keydown', (e) => {
+    if (e.key === 'Enter') connect();
+  });
+
+  // 键盘：/ 搜索，j/k或者上下键选择，1-6切换标签页
+  document.addEventListener('keydown', (e) => {
+    if (e.target.tagName === 'INPUT' || e.target.tagName === 'SELECT') {
+      if (e.key === 'Escape') e.target.blur();
+      return;
+    }
+    const i = state.list.findIndex((x) => x.id === state.selected);
+    if (e.key === '/') {
+      e.preventDefault();
+      $('search').focus();
+    } else if ((e.key === 'j' || e.key === 'ArrowDown') && i < state.list.length - 1) {
+      e.preventDefault();
+      select(state.list[i + 1].id, false);
+    } else if ((e.key === 'k' || e.key === 'ArrowUp') && i > 0) {
+      e.preventDefault();
+      select(state.list[i - 1].id, false);
+    } else if (e.key >= '1' && e.key <= String(tabs.length)) {
+      state.tab = tabs[Number(e.key) - 1];
+      renderDetail(false);
+    }
+  });
+
+  applyTheme();
+  applyLanguage();
+  connect();
+}
+
+init();
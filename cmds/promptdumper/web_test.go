@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebUI(t *testing.T) {
+	w := newWebUI(":0")
+	srv := httptest.NewServer(w.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("unexpected index: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// 只推送满足过滤条件的
+	events, err := http.Get(srv.URL + `/api/events?filter=status+%3D%3D+200`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+	reader := bufio.NewReader(events.Body)
+	if line, _ := reader.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("unexpected first line: %q", line)
+	}
+
+	e := newTestExchange(t)
+	w.Update(&exchange{req: e.req, llmReq: e.llmReq})
+	w.Update(e)
+
+	done := make(chan string, 1)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil || strings.HasPrefix(line, "data: ") {
+				done <- line
+				return
+			}
+		}
+	}()
+	select {
+	case line := <-done:
+		var s webSummary
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &s); err != nil {
+			t.Fatal(err)
+		}
+		if s.ID != e.ID() || s.Pending || s.Status != 200 || s.Tokens != "20/3" {
+			t.Fatalf("unexpected event: %+v", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+
+	var list []webSummary
+	resp, err = http.Get(srv.URL + "/api/exchanges?q=list+files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list) != 1 || list[0].Model != "qwen3" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	var detail webDetail
+	resp, err = http.Get(srv.URL + "/api/exchanges/" + e.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&detail)
+	resp.Body.Close()
+	if detail.Response != "OK" || detail.Reasoning != "use ls" || len(detail.Messages) != 2 || len(detail.Tools) != 1 || len(detail.System) != 1 {
+		t.Fatalf("unexpected detail: %+v", detail)
+	}
+
+	if resp, err = http.Get(srv.URL + "/api/exchanges?filter=model+%3D%3D"); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid filter should be rejected: %d", resp.StatusCode)
+	}
+}
+
+func TestWebUIHost(t *testing.T) {
+	w := newWebUI(":8765")
+	if w.addr != "127.0.0.1:8765" {
+		t.Fatalf("bare port should listen on localhost: %s", w.addr)
+	}
+	cases := map[string]int{"localhost:8765": 200, "127.0.0.1:8765": 200, "[::1]:8765": 200, "evil.example.com:8765": 403, "": 403}
+	for host, status := range cases {
+		r := httptest.NewRequest("GET", "/api/exchanges", nil)
+		r.Host = host
+		rw := httptest.NewRecorder()
+		w.handler().ServeHTTP(rw, r)
+		if rw.Code != status {
+			t.Fatalf("host %q: expected %d, got %d", host, status, rw.Code)
+		}
+	}
+
+	// 明确指定的监听地址也可以访问
+	w = newWebUI("192.168.1.10:8765")
+	if !w.allowedHost("192.168.1.10:8765") || newWebUI("0.0.0.0:8765").allowedHost("192.168.1.10:8765") {
+		t.Fatal("unexpected host check for explicit address")
+	}
+}
+
+func TestWebUIRedactsCookies(t *testing.T) {
+	w := newWebUI(":0")
+	e := newTestExchange(t)
+	e.req.Header.Set("Cookie", "session=abcdef; theme=dark")
+	e.resp.Header.Set("Set-Cookie", "session=ghijkl; Path=/; HttpOnly")
+	w.Update(e)
+
+	r := httptest.NewRequest("GET", "http://localhost/api/exchanges/"+e.ID(), nil)
+	rw := httptest.NewRecorder()
+	w.handler().ServeHTTP(rw, r)
+	var detail webDetail
+	if err := json.NewDecoder(rw.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(detail.RawRequest, "Cookie: session=***; theme=***\n") || strings.Contains(detail.RawRequest, "abcdef") {
+		t.Fatalf("cookie should be redacted:\n%s", detail.RawRequest)
+	}
+	if !strings.Contains(detail.RawResponse, "Set-Cookie: session=***; Path=/; HttpOnly\n") || strings.Contains(detail.RawResponse, "ghijkl") {
+		t.Fatalf("set-cookie should be redacted:\n%s", detail.RawResponse)
+	}
+}
@@ -0,0 +1,501 @@
+package displayfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 语法：
+//
+//	expr    = and { ("||" | "or") and }
+//	and     = unary { ("&&" | "and") unary }
+//	unary   = ("!" | "not") unary | compare
+//	compare = operand [ ("==" | "!=" | "<" | "<=" | ">" | ">=" | "~" | "!~" | "contains") operand ]
+//	operand = STRING | NUMBER | "true" | "false" | FIELD [ "[" STRING "]" ] | FUNC "(" expr ")" | "(" expr ")"
+//
+// 例如：model == "qwen3" && path ~ "/chat" && len(tools) > 0 && status >= 400
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex 把表达式拆分为token
+func lex(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokenLBracket, "[", i})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokenRBracket, "]", i})
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' && c == '"' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text := s[i+1 : j]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(s[i : j+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid string at %d: %v", i, err)
+				}
+				text = unquoted
+			}
+			tokens = append(tokens, token{tokenString, text, i})
+			i = j + 1
+		case c >= '0' && c <= '9' || (c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, s[i:j], i})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "~", "!"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(s)}), nil
+}
+
+// node 语法树节点
+type node interface {
+	eval(env *Env) any
+}
+
+type literalNode struct{ v any }
+
+func (n *literalNode) eval(*Env) any { return n.v }
+
+type fieldNode struct {
+	f   *field
+	key string // header["key"]
+}
+
+func (n *fieldNode) eval(env *Env) any { return n.f.get(env, n.key) }
+
+type notNode struct{ x node }
+
+func (n *notNode) eval(env *Env) any { return !truthy(n.x.eval(env)) }
+
+type logicNode struct {
+	and         bool
+	left, right node
+}
+
+func (n *logicNode) eval(env *Env) any {
+	l := truthy(n.left.eval(env))
+	if n.and {
+		return l && truthy(n.right.eval(env))
+	}
+	return l || truthy(n.right.eval(env))
+}
+
+type funcNode struct {
+	name string
+	arg  node
+}
+
+func (n *funcNode) eval(env *Env) any {
+	v := n.arg.eval(env)
+	switch n.name {
+	case "len":
+		switch x := v.(type) {
+		case string:
+			return float64(len([]rune(x)))
+		case []string:
+			return float64(len(x))
+		}
+		return nil
+	case "lower":
+		switch x := v.(type) {
+		case string:
+			return strings.ToLower(x)
+		case []string:
+			l := make([]string, len(x))
+			for i := range x {
+				l[i] = strings.ToLower(x[i])
+			}
+			return l
+		}
+		return v
+	}
+	return nil
+}
+
+var functions = map[string]bool{"len": true, "lower": true}
+
+type compareNode struct {
+	op          string
+	left, right node
+	re          *regexp.Regexp // 右值是字符串常量时预编译
+}
+
+func (n *compareNode) eval(env *Env) any {
+	l, r := n.left.eval(env), n.right.eval(env)
+	if l == nil || r == nil {
+		return false
+	}
+
+	// 列表只要有一个元素满足即可，!= 和 !~ 要求所有元素都满足
+	if list, ok := l.([]string); ok {
+		negative := n.op == "!=" || n.op == "!~"
+		for _, item := range list {
+			if n.compare(item, r) != negative {
+				return !negative
+			}
+		}
+		return negative
+	}
+	return n.compare(l, r)
+}
+
+func (n *compareNode) compare(l, r any) bool {
+	switch n.op {
+	case "~", "!~":
+		re := n.re
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(toString(r)); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(toString(l)) == (n.op == "~")
+	case "contains":
+		return strings.Contains(toString(l), toString(r))
+	}
+
+	// 数字比较
+	lf, lok := toNumber(l)
+	rf, rok := toNumber(r)
+	if lok && rok {
+		switch n.op {
+		case "==":
+			return lf == rf
+		case "!=":
+			return lf != rf
+		case "<":
+			return lf < rf
+		case "<=":
+			return lf <= rf
+		case ">":
+			return lf > rf
+		case ">=":
+			return lf >= rf
+		}
+		return false
+	}
+
+	ls, rs := toString(l), toString(r)
+	switch n.op {
+	case "==":
+		return ls == rs
+	case "!=":
+		return ls != rs
+	case "<":
+		return ls < rs
+	case "<=":
+		return ls <= rs
+	case ">":
+		return ls > rs
+	case ">=":
+		return ls >= rs
+	}
+	return false
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case float64:
+		return x != 0
+	case []string:
+		return len(x) > 0
+	}
+	return true
+}
+
+func toString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case []string:
+		return strings.Join(x, ",")
+	}
+	return ""
+}
+
+func toNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parser 递归下降解析器
+type parser struct {
+	tokens        []token
+	pos           int
+	needsResponse bool
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	for _, op := range ops {
+		if (t.kind == tokenOp || t.kind == tokenIdent) && t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||", "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&", "and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!", "not") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=", "~", "!~", "contains") {
+		return left, nil
+	}
+	op := p.next().text
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &compareNode{op: op, left: left, right: right}
+	if lit, ok := right.(*literalNode); ok && (op == "~" || op == "!~") {
+		if n.re, err = regexp.Compile(toString(lit.v)); err != nil {
+			return nil, fmt.Errorf("invalid regexp %q: %v", toString(lit.v), err)
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &literalNode{v: t.text}, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+		}
+		return &literalNode{v: f}, nil
+	case tokenLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, fmt.Errorf("missing ) for ( at %d", t.pos)
+		}
+		return x, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{v: true}, nil
+		case "false":
+			return &literalNode{v: false}, nil
+		}
+
+		if functions[t.text] && p.peek().kind == tokenLParen {
+			p.next()
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.next().kind != tokenRParen {
+				return nil, fmt.Errorf("missing ) for %s( at %d", t.text, t.pos)
+			}
+			return &funcNode{name: t.text, arg: arg}, nil
+		}
+
+		f, ok := fields[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q at %d", t.text, t.pos)
+		}
+		if f.response {
+			p.needsResponse = true
+		}
+		n := &fieldNode{f: f}
+		if f.indexed {
+			if p.next().kind != tokenLBracket {
+				return nil, fmt.Errorf("field %s requires a key, e.g. %s[\"name\"]", t.text, t.text)
+			}
+			key := p.next()
+			if key.kind != tokenString || p.next().kind != tokenRBracket {
+				return nil, fmt.Errorf("invalid key for %s at %d", t.text, key.pos)
+			}
+			n.key = key.text
+		}
+		return n, nil
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// Filter 编译后的显示过滤器
+type Filter struct {
+	expr          string
+	root          node
+	needsResponse bool
+}
+
+// Compile 编译过滤表达式
+func Compile(expr string) (*Filter, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Filter{
+		expr:          expr,
+		root:          root,
+		needsResponse: p.needsResponse,
+	}, nil
+}
+
+// Match 判断是否满足过滤条件
+func (f *Filter) Match(env *Env) bool {
+	return truthy(f.root.eval(env))
+}
+
+// NeedsResponse 表达式是否引用了响应的字段，这种情况必须等到响应才能判断
+func (f *Filter) NeedsResponse() bool {
+	return f.needsResponse
+}
+
+// String 原始表达式
+func (f *Filter) String() string {
+	return f.expr
+}
@@ -0,0 +1,141 @@
+package displayfilter
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+)
+
+func newRequest(t *testing.T, raw, body string) *httpdumper.Request {
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httpdumper.NewRequest(req, gopacket.Flow{}, gopacket.Flow{})
+	r.SetBody([]byte(body))
+	return r
+}
+
+func newResponse(t *testing.T, req *httpdumper.Request, raw string) *httpdumper.Response {
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), req.Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httpdumper.NewResponse(req, resp, gopacket.Flow{}, gopacket.Flow{})
+}
+
+func TestFilterMatch(t *testing.T) {
+	req := newRequest(t, "POST /api/chat HTTP/1.1\r\nHost: localhost:11434\r\nContent-Type: application/json\r\nUser-Agent: ollama-js\r\n\r\n",
+		`{"model":"qwen3","messages":[{"role":"system","content":"You are a helper"},{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"get_weather"}}]}`)
+	resp := newResponse(t, req, "HTTP/1.1 500 Internal Server Error\r\nContent-Type: application/json\r\nContent-Length: 2\r\n\r\n{}")
+
+	cases := []struct {
+		expr  string
+		match bool
+	}{
+		{`model == "qwen3"`, true},
+		{`model == "qwen3" && path ~ "/chat" && len(tools) > 0 && status >= 400`, true},
+		{`model != "qwen3"`, false},
+		{`tools == "get_weather"`, true},
+		{`tools != "get_weather"`, false},
+		{`system contains "helper"`, true},
+		{`lower(header["user-agent"]) ~ "^ollama"`, true},
+		{`roles == "assistant"`, false},
+		{`!(status < 400) and method == 'POST'`, true},
+		{`status == 200 || len(messages) == 2`, true},
+		{`llm && not prompt`, true},
+		{`header["X-Missing"] == ""`, false},
+		{`len(media) == 0`, true},
+		{`num_ctx == 0 && temperature < 0 && !format`, true},
+	}
+	for _, c := range cases {
+		f, err := Compile(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := f.Match(NewEnv(req, resp)); got != c.match {
+			t.Fatalf("%s: expected %v, got %v", c.expr, c.match, got)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		`model ==`,
+		`unknown == 1`,
+		`(model == "a"`,
+		`path ~ "("`,
+		`header == "a"`,
+		`model == "a" extra`,
+		`"unterminated`,
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+	}
+}
+
+type recorder struct {
+	requests, responses int
+}
+
+func (r *recorder) OnTcpSession(*httpdumper.TcpSession) {}
+func (r *recorder) OnRequest(*httpdumper.Request)       { r.requests++ }
+func (r *recorder) OnResponse(*httpdumper.Response)     { r.responses++ }
+
+func TestNotifier(t *testing.T) {
+	req := newRequest(t, "GET /api/tags HTTP/1.1\r\nHost: localhost\r\n\r\n", "")
+	resp := newResponse(t, req, "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n")
+
+	f, _ := Compile(`status == 404`)
+	rec := &recorder{}
+	n := NewNotifier(f, rec)
+	n.OnRequest(req)
+	if rec.requests != 0 {
+		t.Fatal("request should wait for the response")
+	}
+	n.OnResponse(resp)
+	if rec.requests != 1 || rec.responses != 1 {
+		t.Fatalf("unexpected notifications: %+v", rec)
+	}
+
+	f, _ = Compile(`method == "POST"`)
+	rec = &recorder{}
+	n = NewNotifier(f, rec)
+	n.OnRequest(req)
+	n.OnResponse(resp)
+	if rec.requests != 0 || rec.responses != 0 {
+		t.Fatalf("unexpected notifications: %+v", rec)
+	}
+
+	f, _ = Compile(`method == "GET"`)
+	rec = &recorder{}
+	n = NewNotifier(f, rec)
+	n.OnRequest(req)
+	n.OnResponse(resp)
+	n.OnResponse(resp)
+	if rec.requests != 1 || rec.responses != 1 {
+		t.Fatalf("response should follow the request once: %+v", rec)
+	}
+}
+
+func TestFields(t *testing.T) {
+	// 字段名超过原来的固定宽度时也要对齐
+	fields["a_very_long_field_name"] = fields["model"]
+	defer delete(fields, "a_very_long_field_name")
+
+	lines := strings.Split(strings.TrimSuffix(Fields(), "\n"), "\n")
+	if len(lines) != len(fields) {
+		t.Fatalf("expected %d lines, got %d", len(fields), len(lines))
+	}
+	column := len("  a_very_long_field_name  ")
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "  ") || len(line) <= column || line[column-2:column] != "  " || line[column] == ' ' {
+			t.Fatalf("descriptions should be aligned: %q", line)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package displayfilter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/LubyRuffy/localdumper/llmparser"
+)
+
+// Env 过滤器求值的上下文，Response可以为空
+type Env struct {
+	Request  *httpdumper.Request
+	Response *httpdumper.Response
+
+	llmReq    *llmparser.LLMRequest
+	llmParsed bool
+}
+
+// NewEnv 创建上下文
+func NewEnv(req *httpdumper.Request, resp *httpdumper.Response) *Env {
+	if req == nil && resp != nil {
+		req = resp.Request
+	}
+	return &Env{Request: req, Response: resp}
+}
+
+// WithLLMRequest 使用已经解析好的llm请求，避免重复解析
+func (e *Env) WithLLMRequest(llmReq *llmparser.LLMRequest) *Env {
+	e.llmReq = llmReq
+	e.llmParsed = true
+	return e
+}
+
+// LLMRequest 延迟解析llm请求，不是llm请求返回nil
+func (e *Env) LLMRequest() *llmparser.LLMRequest {
+	if !e.llmParsed {
+		e.llmParsed = true
+		if e.Request != nil && e.Request.Request != nil {
+			e.llmReq = llmparser.ParseRequest(e.Request)
+		}
+	}
+	return e.llmReq
+}
+
+// field 可以在表达式中使用的字段
+type field struct {
+	desc     string
+	response bool // 是否是响应的字段
+	indexed  bool // 是否需要key，比如header["User-Agent"]
+	get      func(env *Env, key string) any
+}
+
+func requestField(desc string, get func(req *httpdumper.Request) any) *field {
+	return &field{desc: desc, get: func(env *Env, _ string) any {
+		if env.Request == nil || env.Request.Request == nil {
+			return nil
+		}
+		return get(env.Request)
+	}}
+}
+
+func responseField(desc string, get func(resp *httpdumper.Response) any) *field {
+	return &field{desc: desc, response: true, get: func(env *Env, _ string) any {
+		if env.Response == nil || env.Response.Response == nil {
+			return nil
+		}
+		return get(env.Response)
+	}}
+}
+
+func llmField(desc string, get func(llmReq *llmparser.LLMRequest) any) *field {
+	return &field{desc: desc, get: func(env *Env, _ string) any {
+		llmReq := env.LLMRequest()
+		if llmReq == nil {
+			return nil
+		}
+		return get(llmReq)
+	}}
+}
+
+var fields = map[string]*field{
+	"method": requestField("request method", func(req *httpdumper.Request) any { return req.Method }),
+	"url":    requestField("request url", func(req *httpdumper.Request) any { return req.URL.String() }),
+	"path":   requestField("request path", func(req *httpdumper.Request) any { return req.URL.Path }),
+	"host":   requestField("request host", func(req *httpdumper.Request) any { return req.Host }),
+	"src": requestField("client ip:port", func(req *httpdumper.Request) any {
+		return req.Net.Src().String() + ":" + req.Transport.Src().String()
+	}),
+	"dst": requestField("server ip:port", func(req *httpdumper.Request) any {
+		return req.Net.Dst().String() + ":" + req.Transport.Dst().String()
+	}),
+	"port": requestField("server port", func(req *httpdumper.Request) any {
+		port, _ := strconv.ParseFloat(req.Transport.Dst().String(), 64)
+		return port
+	}),
+	"body": requestField("request body", func(req *httpdumper.Request) any { return string(req.Body) }),
+	"header": {desc: "request header, e.g. header[\"User-Agent\"]", indexed: true, get: func(env *Env, key string) any {
+		if env.Request == nil || env.Request.Request == nil || len(env.Request.Header.Values(key)) == 0 {
+			return nil
+		}
+		return env.Request.Header.Get(key)
+	}},
+	"process": requestField("client process name", func(req *httpdumper.Request) any {
+		if req.Process == nil {
+			return nil
+		}
+		return req.Process.Name
+	}),
+	"container": requestField("client container name", func(req *httpdumper.Request) any {
+		if req.SrcContainer == nil {
+			return nil
+		}
+		return req.SrcContainer.Name
+	}),
+
+	"status": responseField("response status code", func(resp *httpdumper.Response) any {
+		return float64(resp.StatusCode)
+	}),
+	"content_type": responseField("response content type", func(resp *httpdumper.Response) any {
+		return resp.Header.Get("Content-Type")
+	}),
+	"resp.body": responseField("response body", func(resp *httpdumper.Response) any { return string(resp.Body) }),
+	"resp.header": {desc: "response header, e.g. resp.header[\"Server\"]", response: true, indexed: true, get: func(env *Env, key string) any {
+		if env.Response == nil || env.Response.Response == nil || len(env.Response.Header.Values(key)) == 0 {
+			return nil
+		}
+		return env.Response.Header.Get(key)
+	}},
+
+	"llm":    {desc: "true if it's a llm request", get: func(env *Env, _ string) any { return env.LLMRequest() != nil }},
+	"model":  llmField("llm model", func(llmReq *llmparser.LLMRequest) any { return llmReq.Model }),
+	"prompt": llmField("llm prompt of generate", func(llmReq *llmparser.LLMRequest) any { return llmReq.Prompt }),
+	"system": llmField("llm system prompt", func(llmReq *llmparser.LLMRequest) any { return llmReq.SystemPrompts() }),
+	"messages": llmField("llm message contents", func(llmReq *llmparser.LLMRequest) any {
+		messages := []string{}
+		for _, msg := range llmReq.Messages {
+			messages = append(messages, msg.Content)
+		}
+		return messages
+	}),
+	"media": llmField("mime types of llm images, audio and files (type if unknown)", func(llmReq *llmparser.LLMRequest) any {
+		parts := llmparser.ImageParts(llmReq.Images)
+		for _, msg := range llmReq.Messages {
+			parts = append(parts, msg.Media()...)
+		}
+		media := []string{}
+		for _, part := range parts {
+			if part.MimeType != "" {
+				media = append(media, part.MimeType)
+			} else {
+				media = append(media, part.Type)
+			}
+		}
+		return media
+	}),
+	"num_ctx":    llmField("ollama options.num_ctx, 0 if not set", func(llmReq *llmparser.LLMRequest) any { return float64(llmReq.NumCtx()) }),
+	"max_tokens": llmField("max output tokens, 0 if not set", func(llmReq *llmparser.LLMRequest) any { return float64(llmReq.MaxOutputTokens()) }),
+	"format":     llmField("structured output format (json, json_schema...)", func(llmReq *llmparser.LLMRequest) any { return llmReq.OutputFormat() }),
+	"temperature": llmField("sampling temperature, -1 if not set", func(llmReq *llmparser.LLMRequest) any {
+		if t, ok := llmReq.TemperatureValue(); ok {
+			return t
+		}
+		return -1.0
+	}),
+	"roles": llmField("llm message roles", func(llmReq *llmparser.LLMRequest) any {
+		roles := []string{}
+		for _, msg := range llmReq.Messages {
+			roles = append(roles, msg.Role)
+		}
+		return roles
+	}),
+	"tools": llmField("llm tool names", func(llmReq *llmparser.LLMRequest) any {
+		tools := []string{}
+		for _, tool := range llmReq.Tools {
+			tools = append(tools, tool.Function.Name)
+		}
+		return tools
+	}),
+}
+
+// Fields 所有支持的字段及其说明，用于帮助信息
+func Fields() string {
+	var names []string
+	width := 0
+	for name := range fields {
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  %-*s  %s\n", width, name, fields[name].desc)
+	}
+	return sb.String()
+}
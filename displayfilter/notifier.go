@@ -0,0 +1,55 @@
+package displayfilter
+
+import "github.com/LubyRuffy/localdumper/httpdumper"
+
+// Notifier 用显示过滤器包装一个httpdumper.Notifier，只有满足条件的请求和响应才会通知下去
+// 如果表达式引用了响应字段（比如status），请求不会单独通知，等收到响应后一起判断和通知
+type Notifier struct {
+	filter   *Filter
+	next     httpdumper.Notifier
+	requests *httpdumper.FilterNotifier // 不引用响应字段时按请求过滤，响应跟随对应的请求
+}
+
+// NewNotifier 创建过滤通知器
+func NewNotifier(filter *Filter, next httpdumper.Notifier) *Notifier {
+	return &Notifier{
+		filter: filter,
+		next:   next,
+		requests: httpdumper.NewFilterNotifier(next, func(req *httpdumper.Request) bool {
+			return filter.Match(NewEnv(req, nil))
+		}),
+	}
+}
+
+// OnTcpSession 会话直接透传
+func (n *Notifier) OnTcpSession(session *httpdumper.TcpSession) {
+	n.next.OnTcpSession(session)
+}
+
+// OnRequest 请求
+func (n *Notifier) OnRequest(req *httpdumper.Request) {
+	if n.filter.NeedsResponse() {
+		return
+	}
+	n.requests.OnRequest(req)
+}
+
+// OnResponse 响应
+func (n *Notifier) OnResponse(resp *httpdumper.Response) {
+	if resp.Request == nil {
+		if n.filter.Match(NewEnv(nil, resp)) {
+			n.next.OnResponse(resp)
+		}
+		return
+	}
+
+	if !n.filter.NeedsResponse() {
+		n.requests.OnResponse(resp)
+		return
+	}
+
+	if n.filter.Match(NewEnv(resp.Request, resp)) {
+		n.next.OnRequest(resp.Request)
+		n.next.OnResponse(resp)
+	}
+}
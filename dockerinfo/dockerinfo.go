@@ -0,0 +1,227 @@
+package dockerinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+)
+
+// DefaultHost 默认的docker socket地址
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// Resolver 通过Docker Engine API把端点的ip和端口解析为容器，实现了httpdumper.ContainerResolver
+// 容器列表会被缓存，在后台刷新，解析时不会访问docker api：
+// 找不到时最多每refreshInterval刷新一次，超过ttl的缓存不再使用，避免ip被新容器复用后仍然归属到旧容器
+type Resolver struct {
+	client          *http.Client
+	baseURL         string
+	refreshInterval time.Duration
+	ttl             time.Duration
+
+	mutex      sync.Mutex
+	refreshing bool                             // 后台正在刷新
+	requested  time.Time                        // 上一次发起刷新的时间
+	refreshed  time.Time                        // 缓存的时间
+	byIP       map[string]*httpdumper.Container // 容器网络ip -> 容器
+	byPort     map[string][]portBinding         // 发布到主机的端口 -> 绑定
+	hostIPs    map[string]bool                  // 宿主机的地址，端口绑定到0.0.0.0时用于判断
+}
+
+// portBinding 发布到主机的端口绑定
+type portBinding struct {
+	ip        string // 绑定的主机地址，0.0.0.0或者::表示所有地址
+	container *httpdumper.Container
+}
+
+// New 创建一个解析器，host支持unix:///path/to/docker.sock、tcp://host:port和http://host:port
+func New(host string) (*Resolver, error) {
+	if host == "" {
+		host = DefaultHost
+	}
+
+	r := &Resolver{
+		refreshInterval: 5 * time.Second,
+		ttl:             time.Minute,
+	}
+
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		socket := strings.TrimPrefix(host, "unix://")
+		r.baseURL = "http://docker"
+		r.client = &http.Client{
+			Timeout: time.Second * 3,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+	case strings.HasPrefix(host, "tcp://"):
+		r.baseURL = "http://" + strings.TrimPrefix(host, "tcp://")
+		r.client = &http.Client{Timeout: time.Second * 3}
+	case strings.HasPrefix(host, "http://"), strings.HasPrefix(host, "https://"):
+		r.baseURL = strings.TrimSuffix(host, "/")
+		r.client = &http.Client{Timeout: time.Second * 3}
+	default:
+		return nil, fmt.Errorf("unsupported docker host: %s", host)
+	}
+
+	// 启动时同步加载一次，之后都在后台刷新
+	r.requested = time.Now()
+	if err := r.refresh(); err != nil {
+		log.Println("docker containers refresh failed:", err)
+	}
+	return r, nil
+}
+
+// dockerContainer /containers/json 返回的容器信息，只保留需要的字段
+type dockerContainer struct {
+	ID    string   `json:"Id"`
+	Names []string `json:"Names"`
+	Image string   `json:"Image"`
+	Ports []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string `json:"IPAddress"`
+			GlobalIPv6Address string `json:"GlobalIPv6Address"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// refresh 重新获取容器列表，不持有锁，完成后替换缓存
+func (r *Resolver) refresh() error {
+	resp, err := r.client.Get(r.baseURL + "/containers/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker api returned %s", resp.Status)
+	}
+
+	var containers []dockerContainer
+	if err = json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return err
+	}
+
+	byIP := make(map[string]*httpdumper.Container)
+	byPort := make(map[string][]portBinding)
+	for _, c := range containers {
+		container := &httpdumper.Container{
+			ID:    c.ID,
+			Image: c.Image,
+		}
+		if len(c.ID) > 12 {
+			container.ID = c.ID[:12]
+		}
+		if len(c.Names) > 0 {
+			container.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		for _, network := range c.NetworkSettings.Networks {
+			if network.IPAddress != "" {
+				byIP[network.IPAddress] = container
+			}
+			if network.GlobalIPv6Address != "" {
+				byIP[network.GlobalIPv6Address] = container
+			}
+		}
+		for _, port := range c.Ports {
+			if port.PublicPort == 0 || (port.Type != "" && port.Type != "tcp") {
+				continue
+			}
+			key := strconv.Itoa(port.PublicPort)
+			byPort[key] = append(byPort[key], portBinding{ip: port.IP, container: container})
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.byIP = byIP
+	r.byPort = byPort
+	r.hostIPs = hostIPs()
+	r.refreshed = time.Now()
+	return nil
+}
+
+// hostIPs 宿主机所有网卡的地址，包括回环地址
+func hostIPs() map[string]bool {
+	ips := map[string]bool{"127.0.0.1": true, "::1": true}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ips
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips[ipNet.IP.String()] = true
+		}
+	}
+	return ips
+}
+
+// lookup 查找缓存，调用时需要持有锁
+func (r *Resolver) lookup(ip, port string) *httpdumper.Container {
+	if c, ok := r.byIP[ip]; ok {
+		return c
+	}
+	// 通过端口映射访问的，ip必须是绑定的宿主机地址
+	for _, b := range r.byPort[port] {
+		if b.ip == ip {
+			return b.container
+		}
+		if unspecified := b.ip == "" || net.ParseIP(b.ip).IsUnspecified(); unspecified && r.hostIPs[ip] {
+			return b.container
+		}
+	}
+	return nil
+}
+
+// ResolveContainer 实现httpdumper.ContainerResolver，只查缓存，不会阻塞
+// 找不到或者缓存即将过期时在后台刷新，新启动的容器在刷新后的连接上才能解析到
+func (r *Resolver) ResolveContainer(ip, port string) *httpdumper.Container {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	age := time.Since(r.refreshed)
+	var c *httpdumper.Container
+	if age < r.ttl {
+		c = r.lookup(ip, port)
+	}
+	if c == nil || age > r.ttl/2 {
+		r.refreshAsync()
+	}
+	return c
+}
+
+// refreshAsync 在后台刷新，同时只有一个刷新，最多每refreshInterval一次，调用时需要持有锁
+func (r *Resolver) refreshAsync() {
+	if r.refreshing || time.Since(r.requested) < r.refreshInterval {
+		return
+	}
+	r.refreshing = true
+	r.requested = time.Now()
+	go func() {
+		if err := r.refresh(); err != nil {
+			log.Println("docker containers refresh failed:", err)
+		}
+		r.mutex.Lock()
+		r.refreshing = false
+		r.mutex.Unlock()
+	}()
+}
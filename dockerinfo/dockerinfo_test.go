@@ -0,0 +1,107 @@
+package dockerinfo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const containersJSON = `[
+  {
+    "Id": "8dfafdbc3a40e5a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5",
+    "Names": ["/ollama"],
+    "Image": "ollama/ollama:latest",
+    "Ports": [{"IP": "0.0.0.0", "PrivatePort": 11434, "PublicPort": 11434, "Type": "tcp"}],
+    "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}
+  },
+  {
+    "Id": "1234567890ab",
+    "Names": ["/agent"],
+    "Image": "my/agent:dev",
+    "Ports": [],
+    "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.3"}}}
+  }
+]`
+
+func TestResolveContainer(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(containersJSON))
+	}))
+	defer srv.Close()
+
+	r, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := r.ResolveContainer("172.17.0.3", "51234")
+	if c == nil || c.Name != "agent" || c.Image != "my/agent:dev" {
+		t.Fatalf("unexpected container: %+v", c)
+	}
+	c = r.ResolveContainer("127.0.0.1", "11434")
+	if c == nil || c.Name != "ollama" || c.ID != "8dfafdbc3a40" {
+		t.Fatalf("unexpected container: %+v", c)
+	}
+	if c = r.ResolveContainer("10.0.0.1", "80"); c != nil {
+		t.Fatalf("expected nil, got %+v", c)
+	}
+	// 发布的端口只匹配宿主机的地址，其他主机上的同一个端口不是这个容器
+	if c = r.ResolveContainer("203.0.113.5", "11434"); c != nil {
+		t.Fatalf("expected nil, got %+v", c)
+	}
+	// 找不到时在后台刷新，最多每refreshInterval一次
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 api call, got %d", n)
+	}
+}
+
+func TestResolveContainerExpires(t *testing.T) {
+	var body atomic.Value
+	body.Store(containersJSON)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body.Load().(string)))
+	}))
+	defer srv.Close()
+
+	r, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ttl, r.refreshInterval = 50*time.Millisecond, 0
+	if c := r.ResolveContainer("172.17.0.3", "51234"); c == nil || c.Name != "agent" {
+		t.Fatalf("unexpected container: %+v", c)
+	}
+
+	// ip被新的容器复用，过期的缓存不再使用
+	body.Store(strings.ReplaceAll(containersJSON, `"/agent"`, `"/other"`))
+	time.Sleep(60 * time.Millisecond)
+	if c := r.ResolveContainer("172.17.0.3", "51234"); c != nil {
+		t.Fatalf("expired cache should not be used: %+v", c)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if c := r.ResolveContainer("172.17.0.3", "51234"); c != nil && c.Name == "other" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cache was not refreshed in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNewUnsupportedHost(t *testing.T) {
+	if _, err := New("ssh://host"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -1,7 +1,10 @@
 package httpdumper
 
 import (
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/google/gopacket"
 	"github.com/google/uuid"
@@ -11,18 +14,45 @@ import (
 type Config struct {
 	Device          string `json:"device"`          // 设备接口，比如lo0
 	PcapFile        string `json:"pcapFile"`        // pcap本地文件，跟Device冲突，必须二选一
+	Realtime        bool   `json:"realtime"`        // 读取pcap文件时按原始的时间间隔处理，默认全速处理
 	BPFFilter       string `json:"bpfFilter"`       // 抓包语法过滤器
 	PromiscuousMode bool   `json:"promiscuousMode"` // 混杂模式，默认本地抓包就不需要
 	Verbose         bool   `json:"verbose"`         // 是否打印详细信息
+	ResolveProcess  bool   `json:"resolveProcess"`  // 是否解析连接所属的本地进程，目前只支持linux
+
+	ContainerResolver ContainerResolver `json:"-"` // 容器归属解析器，可选，为空则不解析，读取pcap文件时不使用
 
 	snapLen int // 最多获取多长的数据包，这里必须是0，所有包都获取，不然http解析就被截断了。不能直接设置，仅用于调试
 }
 
 // Notifier 通知器
+//
+// 注意：OnTcpSession 的参数从 (id string, net, transport gopacket.Flow) 改成了 *TcpSession，
+// 原来的参数对应 session.ID、session.Net 和 session.Transport，实现了旧接口的代码需要改一下签名。
 type Notifier interface {
-	OnTcpSession(id string, net, transport gopacket.Flow) // 新的TCP会话，只通知一次
-	OnRequest(req *Request)                               // http请求
-	OnResponse(resp *Response)                            // http响应
+	OnTcpSession(session *TcpSession) // 新的TCP会话，只通知一次
+	OnRequest(req *Request)           // http请求
+	OnResponse(resp *Response)        // http响应
+}
+
+// Container 容器信息
+type Container struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// String 用于打印
+func (c *Container) String() string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s(%s)", c.Name, c.Image)
+}
+
+// ContainerResolver 根据端点的ip和端口解析所属的容器，解析不到返回nil
+type ContainerResolver interface {
+	ResolveContainer(ip, port string) *Container
 }
 
 // Request http请求
@@ -31,17 +61,43 @@ type Request struct {
 
 	ID             string
 	Net, Transport gopacket.Flow
+	Time           time.Time // 请求读取完成时最后一个包的时间
 	Body           []byte
-	processedBody  bool
+	bodyOnce       sync.Once
+	bodyDone       chan struct{} // SetBody之后关闭
+
+	SrcContainer, DstContainer *Container // 两端所属的容器，没有配置ContainerResolver时为nil
+	Process                    *Process   // 发起请求的本地进程，没有开启ResolveProcess时为nil
 }
 
 // SetBody 设置请求体，只能设置一次
 func (r *Request) SetBody(body []byte) {
-	if r.processedBody {
-		return
+	r.bodyOnce.Do(func() {
+		r.Body = body
+		if r.bodyDone != nil {
+			close(r.bodyDone)
+		}
+	})
+}
+
+// waitBody 等待另一个goroutine设置请求体，超时返回false
+func (r *Request) waitBody(timeout time.Duration) bool {
+	if r.bodyDone == nil {
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-r.bodyDone:
+		return true
+	case <-timer.C:
+		return false
 	}
-	r.processedBody = true
-	r.Body = body
+}
+
+// ConnectionKey 请求所在的tcp连接，同一个连接上的请求相同
+func (r *Request) ConnectionKey() string {
+	return createConnectionKey(r.Net, r.Transport)
 }
 
 // NewRequest 创建一个请求
@@ -51,6 +107,7 @@ func NewRequest(req *http.Request, net, transport gopacket.Flow) *Request {
 		Request:   req,
 		Net:       net,
 		Transport: transport,
+		bodyDone:  make(chan struct{}),
 	}
 }
 
@@ -60,8 +117,11 @@ type Response struct {
 
 	Request        *Request
 	Net, Transport gopacket.Flow
+	Time           time.Time // 响应读取完成时最后一个包的时间
 	Body           []byte
 	processedBody  bool
+
+	SrcContainer, DstContainer *Container // 两端所属的容器，没有配置ContainerResolver时为nil
 }
 
 // SetBody 设置响应体，只能设置一次
@@ -40,7 +40,17 @@ func getHandle(cfg *Config) (*pcap.Handle, error) {
 }
 
 func (hd *HttpDumper) processPackets(handle *pcap.Handle) {
-	streamFactory := &httpStreamFactory{notifier: hd.n, Verbose: hd.cfg.Verbose}
+	streamFactory := &httpStreamFactory{
+		notifier:          hd.n,
+		Verbose:           hd.cfg.Verbose,
+		containerResolver: hd.cfg.ContainerResolver,
+		resolveProcess:    hd.cfg.ResolveProcess,
+	}
+	// 读取文件时抓包的机器和当前的容器、进程都没有关系
+	if hd.cfg.PcapFile != "" {
+		streamFactory.containerResolver = nil
+		streamFactory.resolveProcess = false
+	}
 	streamPool := tcpassembly.NewStreamPool(streamFactory)
 	assembler := tcpassembly.NewAssembler(streamPool)
 
@@ -51,6 +61,10 @@ func (hd *HttpDumper) processPackets(handle *pcap.Handle) {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
+	// 按原始时间间隔处理pcap文件
+	var firstPacketTime, startTime time.Time
+	realtime := hd.cfg.Realtime && hd.cfg.PcapFile != ""
+
 _out:
 	for {
 		select {
@@ -64,13 +78,28 @@ _out:
 				log.Println("Error decoding a packet:", packet.ErrorLayer().Error())
 				continue
 			}
+			if realtime {
+				if firstPacketTime.IsZero() {
+					firstPacketTime, startTime = packet.Metadata().Timestamp, time.Now()
+				}
+				if !hd.sleepUntil(startTime.Add(packet.Metadata().Timestamp.Sub(firstPacketTime))) {
+					assembler.FlushAll()
+					break _out
+				}
+			}
 			if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
 				tcp, _ := tcpLayer.(*layers.TCP)
-				assembler.Assemble(packet.NetworkLayer().NetworkFlow(), tcp)
+				streamFactory.packetTime.Store(packet.Metadata().Timestamp.UnixNano())
+				assembler.AssembleWithTimestamp(packet.NetworkLayer().NetworkFlow(), tcp, packet.Metadata().Timestamp)
 				//assembler.AssembleWithContext(packet.NetworkLayer().NetworkFlow(), tcp, nil)
 			}
 		case <-ticker.C:
-			flushed, closed := assembler.FlushOlderThan(time.Now().Add(-2 * time.Minute))
+			// 读取文件时以数据包的时间为准
+			now := time.Now()
+			if hd.cfg.PcapFile != "" {
+				now = time.Unix(0, streamFactory.packetTime.Load())
+			}
+			flushed, closed := assembler.FlushOlderThan(now.Add(-2 * time.Minute))
 			if flushed > 0 {
 				log.Printf("Flushed %d old streams, closed %d streams\n", flushed, closed)
 			}
@@ -84,6 +113,22 @@ _out:
 	log.Println("done")
 }
 
+// sleepUntil 等待到指定时间，被取消时返回false
+func (hd *HttpDumper) sleepUntil(t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-hd.ctx.Done():
+		return false
+	}
+}
+
 type HttpDumper struct {
 	cfg    *Config
 	n      Notifier
@@ -0,0 +1,310 @@
+package httpdumper
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType 事件类型
+type EventType int
+
+const (
+	EventTcpSession EventType = iota + 1
+	EventRequest
+	EventResponse
+)
+
+// String 用于打印
+func (t EventType) String() string {
+	switch t {
+	case EventTcpSession:
+		return "tcp_session"
+	case EventRequest:
+		return "request"
+	case EventResponse:
+		return "response"
+	}
+	return "unknown"
+}
+
+// Event 通知事件，根据Type只有对应的字段有值
+type Event struct {
+	Type     EventType
+	Session  *TcpSession
+	Request  *Request
+	Response *Response
+}
+
+// Dispatch 把事件通知给n
+func (e Event) Dispatch(n Notifier) {
+	switch e.Type {
+	case EventTcpSession:
+		n.OnTcpSession(e.Session)
+	case EventRequest:
+		n.OnRequest(e.Request)
+	case EventResponse:
+		n.OnResponse(e.Response)
+	}
+}
+
+// MultiNotifier 把事件按顺序分发给多个通知器
+type MultiNotifier struct {
+	notifiers []Notifier
+}
+
+// NewMultiNotifier 创建一个分发通知器
+func NewMultiNotifier(notifiers ...Notifier) *MultiNotifier {
+	return &MultiNotifier{notifiers: notifiers}
+}
+
+func (m *MultiNotifier) OnTcpSession(session *TcpSession) {
+	for _, n := range m.notifiers {
+		n.OnTcpSession(session)
+	}
+}
+
+func (m *MultiNotifier) OnRequest(req *Request) {
+	for _, n := range m.notifiers {
+		n.OnRequest(req)
+	}
+}
+
+func (m *MultiNotifier) OnResponse(resp *Response) {
+	for _, n := range m.notifiers {
+		n.OnResponse(resp)
+	}
+}
+
+// DefaultFilterTTL 请求通过过滤后，等待对应响应的默认最长时间
+const DefaultFilterTTL = 10 * time.Minute
+
+// FilterNotifier 过滤通知器，过滤函数为nil表示全部通过
+// 响应先要求对应的请求通过了RequestFilter，再判断ResponseFilter
+type FilterNotifier struct {
+	Next           Notifier
+	SessionFilter  func(session *TcpSession) bool
+	RequestFilter  func(req *Request) bool
+	ResponseFilter func(resp *Response) bool
+	TTL            time.Duration // 通过的请求最多等待响应多久，超过后响应不再通知，0表示DefaultFilterTTL
+
+	mutex  sync.Mutex
+	passed map[string]time.Time // 通过的请求ID -> 通过的时间
+	swept  time.Time            // 上次清理过期请求的时间
+}
+
+// NewFilterNotifier 创建按请求过滤的通知器，响应跟随对应的请求
+func NewFilterNotifier(next Notifier, requestFilter func(req *Request) bool) *FilterNotifier {
+	return &FilterNotifier{
+		Next:          next,
+		RequestFilter: requestFilter,
+	}
+}
+
+func (f *FilterNotifier) ttl() time.Duration {
+	if f.TTL > 0 {
+		return f.TTL
+	}
+	return DefaultFilterTTL
+}
+
+// pass 记录通过的请求，顺便清理一直没有等到响应的请求，比如连接中途断开
+func (f *FilterNotifier) pass(id string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	now := time.Now()
+	if f.passed == nil {
+		f.passed = make(map[string]time.Time)
+		f.swept = now
+	}
+	f.passed[id] = now
+
+	ttl := f.ttl()
+	if now.Sub(f.swept) < ttl {
+		return
+	}
+	f.swept = now
+	for id, t := range f.passed {
+		if now.Sub(t) > ttl {
+			delete(f.passed, id)
+		}
+	}
+}
+
+// passedRequest 请求是否通过了过滤，响应只会通知一次
+func (f *FilterNotifier) passedRequest(id string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	t, ok := f.passed[id]
+	delete(f.passed, id)
+	return ok && time.Since(t) <= f.ttl()
+}
+
+func (f *FilterNotifier) OnTcpSession(session *TcpSession) {
+	if f.SessionFilter == nil || f.SessionFilter(session) {
+		f.Next.OnTcpSession(session)
+	}
+}
+
+func (f *FilterNotifier) OnRequest(req *Request) {
+	if f.RequestFilter == nil {
+		f.Next.OnRequest(req)
+		return
+	}
+	if f.RequestFilter(req) {
+		f.pass(req.ID)
+		f.Next.OnRequest(req)
+	}
+}
+
+func (f *FilterNotifier) OnResponse(resp *Response) {
+	if f.RequestFilter != nil {
+		if resp.Request == nil || !f.passedRequest(resp.Request.ID) {
+			return
+		}
+	}
+	if f.ResponseFilter == nil || f.ResponseFilter(resp) {
+		f.Next.OnResponse(resp)
+	}
+}
+
+// DropPolicy 队列满了之后的处理策略
+type DropPolicy int
+
+const (
+	DropPolicyBlock  DropPolicy = iota // 阻塞等待，会拖慢组包
+	DropPolicyNewest                   // 丢弃新的事件
+	DropPolicyOldest                   // 丢弃最旧的事件
+)
+
+// ChannelNotifier 把事件转换为channel，队列有界，满了按DropPolicy处理
+type ChannelNotifier struct {
+	events  chan Event
+	policy  DropPolicy
+	dropped atomic.Uint64
+
+	mutex     sync.RWMutex
+	closed    bool
+	done      chan struct{} // Close时关闭，唤醒阻塞在DropPolicyBlock的push
+	closeOnce sync.Once
+}
+
+// NewChannelNotifier 创建一个channel通知器，size是队列长度，最小为1
+func NewChannelNotifier(size int, policy DropPolicy) *ChannelNotifier {
+	if size < 1 {
+		size = 1
+	}
+	return &ChannelNotifier{
+		events: make(chan Event, size),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+}
+
+// Events 事件channel，Close之后会被关闭
+func (c *ChannelNotifier) Events() <-chan Event {
+	return c.events
+}
+
+// Dropped 丢弃的事件数
+func (c *ChannelNotifier) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// Close 关闭channel，之后的事件都会被丢弃
+// DropPolicyBlock时还在等待的事件也会被丢弃
+func (c *ChannelNotifier) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.closed = true
+		close(c.events)
+	})
+}
+
+func (c *ChannelNotifier) push(e Event) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.closed {
+		c.dropped.Add(1)
+		return
+	}
+
+	switch c.policy {
+	case DropPolicyBlock:
+		select {
+		case c.events <- e:
+		case <-c.done:
+			c.dropped.Add(1)
+		}
+	case DropPolicyNewest:
+		select {
+		case c.events <- e:
+		default:
+			c.dropped.Add(1)
+		}
+	case DropPolicyOldest:
+		for {
+			select {
+			case c.events <- e:
+				return
+			default:
+			}
+			select {
+			case <-c.events:
+				c.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+func (c *ChannelNotifier) OnTcpSession(session *TcpSession) {
+	c.push(Event{Type: EventTcpSession, Session: session})
+}
+
+func (c *ChannelNotifier) OnRequest(req *Request) {
+	c.push(Event{Type: EventRequest, Request: req})
+}
+
+func (c *ChannelNotifier) OnResponse(resp *Response) {
+	c.push(Event{Type: EventResponse, Response: resp})
+}
+
+// AsyncNotifier 异步通知器，事件先放入有界队列，再由单独的goroutine按顺序通知给Next
+// 这样慢的UI不会阻塞组包的goroutine；注意丢弃策略可能导致只收到响应而没有请求
+type AsyncNotifier struct {
+	*ChannelNotifier
+	Next Notifier
+	done chan struct{}
+}
+
+// NewAsyncNotifier 创建异步通知器
+func NewAsyncNotifier(next Notifier, size int, policy DropPolicy) *AsyncNotifier {
+	a := &AsyncNotifier{
+		ChannelNotifier: NewChannelNotifier(size, policy),
+		Next:            next,
+		done:            make(chan struct{}),
+	}
+	go func() {
+		defer close(a.done)
+		for e := range a.Events() {
+			e.Dispatch(a.Next)
+		}
+	}()
+	return a
+}
+
+// Close 停止接收事件，并等待队列中的事件处理完成
+func (a *AsyncNotifier) Close() {
+	a.ChannelNotifier.Close()
+	<-a.done
+}
+
+var (
+	_ Notifier = (*MultiNotifier)(nil)
+	_ Notifier = (*FilterNotifier)(nil)
+	_ Notifier = (*ChannelNotifier)(nil)
+	_ Notifier = (*AsyncNotifier)(nil)
+)
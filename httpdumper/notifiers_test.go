@@ -0,0 +1,142 @@
+package httpdumper
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+type countNotifier struct {
+	mutex                         sync.Mutex
+	sessions, requests, responses int
+}
+
+func (c *countNotifier) OnTcpSession(*TcpSession) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.sessions++
+}
+
+func (c *countNotifier) OnRequest(*Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests++
+}
+
+func (c *countNotifier) OnResponse(*Response) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.responses++
+}
+
+func newTestRequest(method string) *Request {
+	req, _ := http.NewRequest(method, "http://localhost/api/chat", nil)
+	return NewRequest(req, gopacket.Flow{}, gopacket.Flow{})
+}
+
+func TestMultiAndFilterNotifier(t *testing.T) {
+	a, b := &countNotifier{}, &countNotifier{}
+	n := NewMultiNotifier(a, NewFilterNotifier(b, func(req *Request) bool {
+		return req.Method == http.MethodPost
+	}))
+
+	get, post := newTestRequest(http.MethodGet), newTestRequest(http.MethodPost)
+	n.OnTcpSession(&TcpSession{})
+	n.OnRequest(get)
+	n.OnRequest(post)
+	n.OnResponse(NewResponse(get, &http.Response{}, gopacket.Flow{}, gopacket.Flow{}))
+	n.OnResponse(NewResponse(post, &http.Response{}, gopacket.Flow{}, gopacket.Flow{}))
+
+	if a.sessions != 1 || a.requests != 2 || a.responses != 2 {
+		t.Fatalf("unexpected a: %+v", a)
+	}
+	if b.sessions != 1 || b.requests != 1 || b.responses != 1 {
+		t.Fatalf("unexpected b: %+v", b)
+	}
+}
+
+func TestFilterNotifierEviction(t *testing.T) {
+	c := &countNotifier{}
+	f := NewFilterNotifier(c, nil)
+	f.OnRequest(newTestRequest(http.MethodGet))
+	if c.requests != 1 || len(f.passed) != 0 {
+		t.Fatalf("requests without a filter should not be remembered: %d", len(f.passed))
+	}
+
+	f = NewFilterNotifier(c, func(*Request) bool { return true })
+	f.TTL = time.Millisecond
+	lost := newTestRequest(http.MethodGet)
+	f.OnRequest(lost)
+	time.Sleep(5 * time.Millisecond)
+	f.OnRequest(newTestRequest(http.MethodGet))
+	if _, ok := f.passed[lost.ID]; ok || len(f.passed) != 1 {
+		t.Fatalf("expired request should be evicted: %d", len(f.passed))
+	}
+	f.OnResponse(NewResponse(lost, &http.Response{}, gopacket.Flow{}, gopacket.Flow{}))
+	if c.responses != 0 {
+		t.Fatal("response of an evicted request should be dropped")
+	}
+}
+
+func TestChannelNotifierDropPolicy(t *testing.T) {
+	newest := NewChannelNotifier(2, DropPolicyNewest)
+	oldest := NewChannelNotifier(2, DropPolicyOldest)
+	var reqs []*Request
+	for i := 0; i < 5; i++ {
+		req := newTestRequest(http.MethodGet)
+		reqs = append(reqs, req)
+		newest.OnRequest(req)
+		oldest.OnRequest(req)
+	}
+	newest.Close()
+	oldest.Close()
+
+	if newest.Dropped() != 3 || oldest.Dropped() != 3 {
+		t.Fatalf("unexpected dropped: %d %d", newest.Dropped(), oldest.Dropped())
+	}
+	if e := <-newest.Events(); e.Type != EventRequest || e.Request != reqs[0] {
+		t.Fatal("DropPolicyNewest should keep the first events")
+	}
+	if e := <-oldest.Events(); e.Request != reqs[3] {
+		t.Fatal("DropPolicyOldest should keep the last events")
+	}
+}
+
+func TestChannelNotifierClose(t *testing.T) {
+	zero := NewChannelNotifier(0, DropPolicyOldest)
+	zero.OnRequest(newTestRequest(http.MethodGet))
+	zero.OnRequest(newTestRequest(http.MethodGet))
+	if zero.Dropped() != 1 {
+		t.Fatalf("size 0 should hold one event, dropped %d", zero.Dropped())
+	}
+
+	// 没有消费者时阻塞的push不能让Close死锁
+	block := NewChannelNotifier(1, DropPolicyBlock)
+	block.OnRequest(newTestRequest(http.MethodGet))
+	pushed := make(chan struct{})
+	go func() {
+		block.OnRequest(newTestRequest(http.MethodGet))
+		close(pushed)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	block.Close()
+	<-pushed
+	if block.Dropped() != 1 {
+		t.Fatalf("blocked event should be dropped, dropped %d", block.Dropped())
+	}
+}
+
+func TestAsyncNotifier(t *testing.T) {
+	c := &countNotifier{}
+	a := NewAsyncNotifier(c, 16, DropPolicyBlock)
+	for i := 0; i < 100; i++ {
+		a.OnRequest(newTestRequest(http.MethodGet))
+	}
+	a.Close()
+	if c.requests != 100 || a.Dropped() != 0 {
+		t.Fatalf("unexpected: %d requests, %d dropped", c.requests, a.Dropped())
+	}
+}
@@ -0,0 +1,45 @@
+package httpdumper
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// Process 本地进程信息
+type Process struct {
+	PID     int      `json:"pid"`
+	Name    string   `json:"name"`    // 进程名
+	Exe     string   `json:"exe"`     // 可执行文件路径
+	Cmdline []string `json:"cmdline"` // 命令行参数
+}
+
+// String 用于打印
+func (p *Process) String() string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s[%d]", p.Name, p.PID)
+}
+
+// MatchName 进程名或者可执行文件名包含name（不区分大小写）
+func (p *Process) MatchName(name string) bool {
+	if p == nil {
+		return false
+	}
+	name = strings.ToLower(name)
+	return strings.Contains(strings.ToLower(p.Name), name) ||
+		strings.Contains(strings.ToLower(filepath.Base(p.Exe)), name)
+}
+
+// TcpSession TCP会话
+type TcpSession struct {
+	ID             string
+	Net, Transport gopacket.Flow
+	Time           time.Time // 第一个包的时间
+
+	SrcProcess, DstProcess *Process // 两端所属的本地进程，没有开启ResolveProcess或者不是本地连接时为nil
+}
@@ -0,0 +1,239 @@
+//go:build linux
+
+package httpdumper
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/gopacket"
+)
+
+// ProcessSupported 当前系统是否支持解析连接所属的本地进程
+const ProcessSupported = true
+
+// socketEntry /proc/net/tcp{,6} 中的一行
+type socketEntry struct {
+	localIP, remoteIP     net.IP
+	localPort, remotePort int
+	inode                 string
+}
+
+// parseProcNetIP 解析/proc/net/tcp中的地址，每4个字节是主机字节序（小端）
+func parseProcNetIP(s string) net.IP {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b)%4 != 0 {
+		return nil
+	}
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return b
+}
+
+// parseProcNetAddr 解析 0100007F:2CAA 这样的地址
+func parseProcNetAddr(s string) (net.IP, int, bool) {
+	ipStr, portStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, false
+	}
+	ip := parseProcNetIP(ipStr)
+	port, err := strconv.ParseInt(portStr, 16, 32)
+	if ip == nil || err != nil {
+		return nil, 0, false
+	}
+	return ip, int(port), true
+}
+
+// readSockets 读取/proc/net/tcp和/proc/net/tcp6
+func readSockets() []socketEntry {
+	var entries []socketEntry
+	for _, file := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // 跳过表头
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 {
+				continue
+			}
+			localIP, localPort, ok1 := parseProcNetAddr(fields[1])
+			remoteIP, remotePort, ok2 := parseProcNetAddr(fields[2])
+			if !ok1 || !ok2 || fields[9] == "0" {
+				continue
+			}
+			entries = append(entries, socketEntry{
+				localIP:    localIP,
+				localPort:  localPort,
+				remoteIP:   remoteIP,
+				remotePort: remotePort,
+				inode:      fields[9],
+			})
+		}
+		f.Close()
+	}
+	return entries
+}
+
+// findInode 查找本地端是local、远端是remote的socket inode
+func findInode(entries []socketEntry, local, remote gopacket.Endpoint, localPort, remotePort gopacket.Endpoint) string {
+	lip, rip := net.ParseIP(local.String()), net.ParseIP(remote.String())
+	lport, _ := strconv.Atoi(localPort.String())
+	rport, _ := strconv.Atoi(remotePort.String())
+	for _, e := range entries {
+		if e.localPort == lport && e.remotePort == rport && e.localIP.Equal(lip) && e.remoteIP.Equal(rip) {
+			return e.inode
+		}
+	}
+	return ""
+}
+
+// readProcess 读取进程信息
+func readProcess(pid int) *Process {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	p := &Process{PID: pid}
+	if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		p.Name = strings.TrimSpace(string(comm))
+	}
+	p.Exe, _ = os.Readlink(filepath.Join(dir, "exe"))
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		p.Cmdline = strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	}
+	return p
+}
+
+// maxRecentPids 最多记住多少个最近持有连接的进程
+const maxRecentPids = 64
+
+// recentPids 最近找到过连接的进程，新连接大多来自同一批客户端，先查它们就不用每次都遍历整个/proc
+var recentPids struct {
+	sync.Mutex
+	pids []int
+}
+
+// scanFds 在pid的/proc/<pid>/fd中查找这些socket inode，返回是否找到了，进程不存在时ok为false
+func scanFds(pid int, inodes map[string]*Process) (found, ok bool) {
+	fdDir := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+	fds, err := os.ReadDir(fdDir)
+	if err != nil {
+		return false, false
+	}
+	var p *Process
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+		if owner, exists := inodes[inode]; exists && owner == nil {
+			if p == nil {
+				p = readProcess(pid)
+			}
+			inodes[inode] = p
+			found = true
+		}
+	}
+	return found, true
+}
+
+// left 还有多少个inode没有找到进程
+func left(inodes map[string]*Process) int {
+	n := 0
+	for _, p := range inodes {
+		if p == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// rememberPid 记录最近找到过连接的进程
+func rememberPid(pid int) {
+	for i, p := range recentPids.pids {
+		if p == pid {
+			recentPids.pids = append(recentPids.pids[:i], recentPids.pids[i+1:]...)
+			break
+		}
+	}
+	recentPids.pids = append(recentPids.pids, pid)
+	if len(recentPids.pids) > maxRecentPids {
+		recentPids.pids = recentPids.pids[len(recentPids.pids)-maxRecentPids:]
+	}
+}
+
+// findProcesses 找到持有这些socket inode的进程，先查最近的进程，找不到再遍历/proc/*/fd
+func findProcesses(inodes map[string]*Process) {
+	recentPids.Lock()
+	defer recentPids.Unlock()
+
+	alive := recentPids.pids[:0]
+	var found []int
+	for _, pid := range recentPids.pids {
+		ok, exists := false, true
+		if left(inodes) > 0 {
+			ok, exists = scanFds(pid, inodes)
+		}
+		if exists {
+			alive = append(alive, pid)
+		}
+		if ok {
+			found = append(found, pid)
+		}
+	}
+	recentPids.pids = alive
+	for _, pid := range found {
+		rememberPid(pid)
+	}
+	if left(inodes) == 0 {
+		return
+	}
+
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil {
+			continue
+		}
+		if ok, _ := scanFds(pid, inodes); ok {
+			rememberPid(pid)
+			if left(inodes) == 0 {
+				return
+			}
+		}
+	}
+}
+
+// lookupProcesses 根据TCP四元组找到两端所属的本地进程
+func lookupProcesses(netFlow, transport gopacket.Flow) (src, dst *Process) {
+	srcIP, dstIP := netFlow.Endpoints()
+	srcPort, dstPort := transport.Endpoints()
+
+	entries := readSockets()
+	srcInode := findInode(entries, srcIP, dstIP, srcPort, dstPort)
+	dstInode := findInode(entries, dstIP, srcIP, dstPort, srcPort)
+	if srcInode == "" && dstInode == "" {
+		return nil, nil
+	}
+
+	inodes := make(map[string]*Process)
+	if srcInode != "" {
+		inodes[srcInode] = nil
+	}
+	if dstInode != "" {
+		inodes[dstInode] = nil
+	}
+	findProcesses(inodes)
+	return inodes[srcInode], inodes[dstInode]
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package httpdumper
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestParseProcNetAddr(t *testing.T) {
+	ip, port, ok := parseProcNetAddr("0100007F:2CAA")
+	if !ok || ip.String() != "127.0.0.1" || port != 11434 {
+		t.Fatalf("unexpected: %v %d %v", ip, port, ok)
+	}
+	ip, port, ok = parseProcNetAddr("00000000000000000000000001000000:0050")
+	if !ok || ip.String() != "::1" || port != 80 {
+		t.Fatalf("unexpected: %v %d %v", ip, port, ok)
+	}
+}
+
+func TestLookupProcesses(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	local := conn.LocalAddr().(*net.TCPAddr)
+	remote := conn.RemoteAddr().(*net.TCPAddr)
+	netFlow := gopacket.NewFlow(layers.EndpointIPv4, local.IP.To4(), remote.IP.To4())
+	transport := gopacket.NewFlow(layers.EndpointTCPPort,
+		[]byte{byte(local.Port >> 8), byte(local.Port)},
+		[]byte{byte(remote.Port >> 8), byte(remote.Port)})
+
+	src, _ := lookupProcesses(netFlow, transport)
+	if src == nil {
+		t.Skip("no permission to read /proc")
+	}
+	if src.PID != os.Getpid() {
+		t.Fatalf("expected pid %d, got %s", os.Getpid(), src)
+	}
+	if !src.MatchName(src.Name) {
+		t.Fatalf("MatchName failed: %s", src)
+	}
+	if strconv.Itoa(local.Port) != transport.Src().String() {
+		t.Fatalf("bad test flow: %s", transport)
+	}
+
+	// 同一个进程的新连接先从最近的进程里找到
+	recentPids.Lock()
+	recent := recentPids.pids
+	recentPids.Unlock()
+	if len(recent) == 0 || recent[len(recent)-1] != os.Getpid() {
+		t.Fatalf("pid not remembered: %v", recent)
+	}
+	if src, _ := lookupProcesses(netFlow, transport); src == nil || src.PID != os.Getpid() {
+		t.Fatalf("cached lookup failed: %s", src)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package httpdumper
+
+import "github.com/google/gopacket"
+
+// ProcessSupported 当前系统是否支持解析连接所属的本地进程
+const ProcessSupported = false
+
+// lookupProcesses 目前只支持linux
+func lookupProcesses(net, transport gopacket.Flow) (src, dst *Process) {
+	return nil, nil
+}
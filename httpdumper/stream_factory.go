@@ -22,14 +22,94 @@ import (
 type tcpState struct {
 	mutex    sync.Mutex
 	discard  atomic.Bool
-	requests []*Request // 请求列表
-	reqIndex int        // 当前读取的偏移
+	requests []*Request    // 请求列表
+	reqIndex int           // 当前读取的偏移
+	added    chan struct{} // 添加了新的请求
+
+	containersOnce sync.Once             // 确保每个连接只解析一次容器
+	containers     map[string]*Container // 端点 -> 所属容器
+
+	sessionOnce sync.Once   // 确保两个方向的流只通知一次会话
+	session     *TcpSession // 会话信息
+}
+
+// notifySession 通知新的TCP会话，两个方向的流只会通知一次
+func (ts *tcpState) notifySession(s *httpStream, net, transport gopacket.Flow) {
+	f := s.factory
+	ts.sessionOnce.Do(func() {
+		ts.session = &TcpSession{
+			ID:        createConnectionKey(net, transport),
+			Net:       net,
+			Transport: transport,
+			Time:      s.created,
+		}
+		if f.resolveProcess {
+			ts.session.SrcProcess, ts.session.DstProcess = lookupProcesses(net, transport)
+		}
+		f.notifier.OnTcpSession(ts.session)
+	})
+}
+
+// srcProcess 返回net和transport的源端所属的进程
+func (ts *tcpState) srcProcess(net gopacket.Flow, transport gopacket.Flow) *Process {
+	if ts.session == nil {
+		return nil
+	}
+	if ts.session.Net == net && ts.session.Transport == transport {
+		return ts.session.SrcProcess
+	}
+	return ts.session.DstProcess
+}
+
+// resolveContainers 解析连接两端所属的容器，同一个连接只解析一次
+func (ts *tcpState) resolveContainers(resolver ContainerResolver, net, transport gopacket.Flow) (src, dst *Container) {
+	if resolver == nil {
+		return nil, nil
+	}
+
+	srcIP, dstIP := net.Endpoints()
+	srcPort, dstPort := transport.Endpoints()
+	ts.containersOnce.Do(func() {
+		ts.containers = map[string]*Container{
+			srcIP.String() + ":" + srcPort.String(): resolver.ResolveContainer(srcIP.String(), srcPort.String()),
+			dstIP.String() + ":" + dstPort.String(): resolver.ResolveContainer(dstIP.String(), dstPort.String()),
+		}
+	})
+	return ts.containers[srcIP.String()+":"+srcPort.String()], ts.containers[dstIP.String()+":"+dstPort.String()]
+}
+
+// requestWaitTimeout 响应等待对应请求的最长时间
+const requestWaitTimeout = time.Second
+
+func newTcpState() *tcpState {
+	return &tcpState{added: make(chan struct{}, 1)}
 }
 
 func (ts *tcpState) appendRequest(req *Request) {
 	ts.mutex.Lock()
-	defer ts.mutex.Unlock()
 	ts.requests = append(ts.requests, req)
+	ts.mutex.Unlock()
+
+	select {
+	case ts.added <- struct{}{}:
+	default:
+	}
+}
+
+// waitRequest 等待下一个请求，全速读取pcap文件时，请求可能还在另一个方向的流中解析
+func (ts *tcpState) waitRequest(timeout time.Duration) *Request {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		if req := ts.getLastRequest(); req != nil {
+			return req
+		}
+		select {
+		case <-ts.added:
+		case <-timer.C:
+			return ts.getLastRequest()
+		}
+	}
 }
 
 func (ts *tcpState) getLastRequest() *Request {
@@ -52,6 +132,11 @@ type httpStreamFactory struct {
 	wg       sync.WaitGroup
 	notifier Notifier
 	Verbose  bool
+
+	containerResolver ContainerResolver
+	resolveProcess    bool
+
+	packetTime atomic.Int64 // 当前正在组包的数据包时间，UnixNano
 }
 
 type RequestOrResponse int
@@ -75,6 +160,13 @@ type httpStream struct {
 	state             *tcpState          // 两端共享的状态
 	closeOnce         sync.Once          // 确保ReassemblyComplete只被调用一次
 	Verbose           bool               // 是否打印详细信息
+	created           time.Time          // 第一个包的时间
+	lastSeen          atomic.Int64       // 最后一次组包的数据包时间，UnixNano
+}
+
+// seen 最后一次组包的数据包时间
+func (r *httpStream) seen() time.Time {
+	return time.Unix(0, r.lastSeen.Load())
 }
 
 // ReassemblyComplete implements tcpassembly.Stream's ReassemblyComplete function.
@@ -90,6 +182,9 @@ func (r *httpStream) Reassembled(reassembly []tcpassembly.Reassembly) {
 	if r.state.discard.Load() {
 		return
 	}
+	if len(reassembly) > 0 {
+		r.lastSeen.Store(reassembly[len(reassembly)-1].Seen.UnixNano())
+	}
 
 	//for _, pkt := range reassembly {
 	//	log.Printf("reassembled: %s:%s -> %s:%s, %d bytes, skip=%v, start=%v, end=%v\n",
@@ -128,11 +223,14 @@ func (s *httpStream) readRequest(buf *bufio.Reader) error {
 	}
 
 	newReq := NewRequest(req.Clone(context.Background()), s.net, s.transport)
+	newReq.SrcContainer, newReq.DstContainer = s.state.resolveContainers(s.factory.containerResolver, s.net, s.transport)
+	newReq.Process = s.state.srcProcess(s.net, s.transport)
 	s.state.appendRequest(newReq)
 
 	body, _ := io.ReadAll(req.Body)
 	req.Body.Close()
 
+	newReq.Time = s.seen()
 	newReq.SetBody(body)
 
 	s.factory.notifier.OnRequest(newReq)
@@ -145,15 +243,11 @@ func (s *httpStream) readResponse(buf *bufio.Reader) error {
 		return err
 	}
 
-	req := s.state.getLastRequest()
+	req := s.state.waitRequest(requestWaitTimeout)
 	var rawReq *http.Request
 	if req != nil {
 		rawReq = req.Request
-		i := 0
-		for !req.processedBody && i < 10 {
-			time.Sleep(time.Millisecond * 100)
-			i++
-		}
+		req.waitBody(requestWaitTimeout)
 	}
 
 	resp, err := http.ReadResponse(buf, rawReq)
@@ -161,9 +255,11 @@ func (s *httpStream) readResponse(buf *bufio.Reader) error {
 		return err
 	}
 	newResp := NewResponse(req, resp, s.net, s.transport)
+	newResp.SrcContainer, newResp.DstContainer = s.state.resolveContainers(s.factory.containerResolver, s.net, s.transport)
 
 	body, _ := io.ReadAll(resp.Body)
 	resp.Body.Close()
+	newResp.Time = s.seen()
 	newResp.SetBody(body)
 
 	s.factory.notifier.OnResponse(newResp)
@@ -178,6 +274,8 @@ func (s *httpStream) run() {
 		s.factory.wg.Done()
 	}()
 
+	s.state.notifySession(s, s.net, s.transport)
+
 	buf := bufio.NewReader(s)
 	_, err := buf.Peek(1) // 必须读一次，确保s.requestOrResponse被设置
 
@@ -228,17 +326,12 @@ func createConnectionKey(netFlow, tcpFlow gopacket.Flow) string {
 }
 
 func (f *httpStreamFactory) getHttpStream(net, transport gopacket.Flow) *httpStream {
-	id := createConnectionKey(net, transport)
-
 	// 设置共享状态
-	state, loaded := f.m.LoadOrStore(net.String()+":"+transport.String(), &tcpState{})
+	state, _ := f.m.LoadOrStore(net.String()+":"+transport.String(), newTcpState())
 	f.m.LoadOrStore(net.Reverse().String()+":"+transport.Reverse().String(), state)
 
-	if !loaded {
-		f.notifier.OnTcpSession(id, net, transport)
-	}
-
-	return &httpStream{
+	created := time.Unix(0, f.packetTime.Load())
+	s := &httpStream{
 		id:           net.String() + ":" + transport.String(),
 		ReaderStream: tcpreader.NewReaderStream(),
 		factory:      f,
@@ -247,7 +340,10 @@ func (f *httpStreamFactory) getHttpStream(net, transport gopacket.Flow) *httpStr
 		state:        state.(*tcpState),
 		isFirstPkt:   true,
 		Verbose:      f.Verbose,
+		created:      created,
 	}
+	s.lastSeen.Store(created.UnixNano())
+	return s
 }
 
 // New 方法在检测到新的 TCP 流时被调用
@@ -0,0 +1,32 @@
+package httpdumper
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWaitRequest(t *testing.T) {
+	ts := newTcpState()
+	if ts.waitRequest(10*time.Millisecond) != nil {
+		t.Fatal("no request expected")
+	}
+
+	req := newTestRequest(http.MethodPost)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ts.appendRequest(req)
+		time.Sleep(10 * time.Millisecond)
+		req.SetBody([]byte("{}"))
+	}()
+	if got := ts.waitRequest(time.Second); got != req {
+		t.Fatal("request not received")
+	}
+	if !req.waitBody(time.Second) || string(req.Body) != "{}" {
+		t.Fatal("body not received")
+	}
+	req.SetBody([]byte("again"))
+	if string(req.Body) != "{}" {
+		t.Fatal("body can only be set once")
+	}
+}
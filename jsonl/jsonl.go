@@ -0,0 +1,205 @@
+package jsonl
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/LubyRuffy/localdumper/llmparser"
+)
+
+// Writer 把事件按每行一个json对象写出，可以并发调用
+type Writer struct {
+	mutex sync.Mutex
+	enc   *json.Encoder
+}
+
+// NewWriter 创建一个jsonl写入器
+func NewWriter(w io.Writer) *Writer {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &Writer{enc: enc}
+}
+
+// Write 写入一行
+func (w *Writer) Write(v any) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.enc.Encode(v)
+}
+
+// Endpoint 连接的一端
+type Endpoint struct {
+	IP        string                `json:"ip"`
+	Port      string                `json:"port"`
+	Process   *httpdumper.Process   `json:"process,omitempty"`
+	Container *httpdumper.Container `json:"container,omitempty"`
+}
+
+// Body http的body，不是utf8文本时使用base64编码
+type Body struct {
+	Body         string `json:"body,omitempty"`
+	BodyEncoding string `json:"body_encoding,omitempty"` // 空表示原始文本，否则为base64
+}
+
+// NewBody 创建body
+func NewBody(data []byte) Body {
+	if utf8.Valid(data) {
+		return Body{Body: string(data)}
+	}
+	return Body{Body: base64.StdEncoding.EncodeToString(data), BodyEncoding: "base64"}
+}
+
+// SessionEvent tcp会话事件
+type SessionEvent struct {
+	Type string    `json:"type"` // tcp_session
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	Src  Endpoint  `json:"src"`
+	Dst  Endpoint  `json:"dst"`
+}
+
+// NewSessionEvent 创建tcp会话事件
+func NewSessionEvent(session *httpdumper.TcpSession) *SessionEvent {
+	return &SessionEvent{
+		Type: httpdumper.EventTcpSession.String(),
+		ID:   session.ID,
+		Time: session.Time,
+		Src:  Endpoint{IP: session.Net.Src().String(), Port: session.Transport.Src().String(), Process: session.SrcProcess},
+		Dst:  Endpoint{IP: session.Net.Dst().String(), Port: session.Transport.Dst().String(), Process: session.DstProcess},
+	}
+}
+
+// RequestEvent http请求事件
+type RequestEvent struct {
+	Type    string      `json:"type"` // request
+	ID      string      `json:"id"`
+	Time    time.Time   `json:"time"`
+	Src     Endpoint    `json:"src"`
+	Dst     Endpoint    `json:"dst"`
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Proto   string      `json:"proto"`
+	Host    string      `json:"host"`
+	Headers http.Header `json:"headers"`
+	Body
+}
+
+// NewRequestEvent 创建http请求事件
+func NewRequestEvent(req *httpdumper.Request) *RequestEvent {
+	return &RequestEvent{
+		Type:    httpdumper.EventRequest.String(),
+		ID:      req.ID,
+		Time:    req.Time,
+		Src:     Endpoint{IP: req.Net.Src().String(), Port: req.Transport.Src().String(), Process: req.Process, Container: req.SrcContainer},
+		Dst:     Endpoint{IP: req.Net.Dst().String(), Port: req.Transport.Dst().String(), Container: req.DstContainer},
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Proto:   req.Proto,
+		Host:    req.Host,
+		Headers: req.Header,
+		Body:    NewBody(req.Body),
+	}
+}
+
+// ResponseEvent http响应事件，ID和对应请求的ID相同
+type ResponseEvent struct {
+	Type       string      `json:"type"` // response
+	ID         string      `json:"id"`
+	Time       time.Time   `json:"time"`
+	Src        Endpoint    `json:"src"`
+	Dst        Endpoint    `json:"dst"`
+	Proto      string      `json:"proto"`
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Headers    http.Header `json:"headers"`
+	Body
+}
+
+// NewResponseEvent 创建http响应事件
+func NewResponseEvent(resp *httpdumper.Response) *ResponseEvent {
+	e := &ResponseEvent{
+		Type:       httpdumper.EventResponse.String(),
+		Time:       resp.Time,
+		Src:        Endpoint{IP: resp.Net.Src().String(), Port: resp.Transport.Src().String(), Container: resp.SrcContainer},
+		Dst:        Endpoint{IP: resp.Net.Dst().String(), Port: resp.Transport.Dst().String(), Container: resp.DstContainer},
+		Proto:      resp.Proto,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Headers:    resp.Header,
+		Body:       NewBody(resp.Body),
+	}
+	if resp.Request != nil {
+		e.ID = resp.Request.ID
+		e.Dst.Process = resp.Request.Process
+	}
+	return e
+}
+
+// Notifier 实现httpdumper.Notifier，把所有事件写为jsonl
+type Notifier struct {
+	w *Writer
+}
+
+// NewNotifier 创建jsonl通知器
+func NewNotifier(w *Writer) *Notifier {
+	return &Notifier{w: w}
+}
+
+func (n *Notifier) OnTcpSession(session *httpdumper.TcpSession) {
+	n.w.Write(NewSessionEvent(session))
+}
+
+func (n *Notifier) OnRequest(req *httpdumper.Request) {
+	n.w.Write(NewRequestEvent(req))
+}
+
+func (n *Notifier) OnResponse(resp *httpdumper.Response) {
+	n.w.Write(NewResponseEvent(resp))
+}
+
+// ExchangeEvent 解析后的一次llm调用，ID和请求的ID相同
+type ExchangeEvent struct {
+	Type         string                `json:"type"` // llm_exchange
+	ID           string                `json:"id"`
+	RequestTime  time.Time             `json:"request_time"`
+	ResponseTime time.Time             `json:"response_time"`
+	DurationMs   int64                 `json:"duration_ms"`
+	Src          Endpoint              `json:"src"`
+	Dst          Endpoint              `json:"dst"`
+	URL          string                `json:"url"`
+	Model        string                `json:"model"`
+	StatusCode   int                   `json:"status_code"`
+	Request      *llmparser.LLMRequest `json:"request"`
+	Response     string                `json:"response"`
+	Reasoning    string                `json:"reasoning,omitempty"`
+	Conversation string                `json:"conversation,omitempty"` // 会话ID，见llmparser.Threader
+	Turn         int                   `json:"turn,omitempty"`         // 会话中的第几次请求
+	Usage        *llmparser.Metrics    `json:"usage,omitempty"`        // token用量和性能指标
+	Error        *llmparser.LLMError   `json:"error,omitempty"`        // 错误响应或者流式响应中的错误
+}
+
+// NewExchangeEvent 创建llm调用事件，response和reasoning是重组后的响应内容和思考过程
+func NewExchangeEvent(resp *httpdumper.Response, llmReq *llmparser.LLMRequest, response, reasoning string) *ExchangeEvent {
+	req := resp.Request
+	return &ExchangeEvent{
+		Type:         "llm_exchange",
+		ID:           req.ID,
+		RequestTime:  req.Time,
+		ResponseTime: resp.Time,
+		DurationMs:   resp.Time.Sub(req.Time).Milliseconds(),
+		Src:          Endpoint{IP: req.Net.Src().String(), Port: req.Transport.Src().String(), Process: req.Process, Container: req.SrcContainer},
+		Dst:          Endpoint{IP: req.Net.Dst().String(), Port: req.Transport.Dst().String(), Container: req.DstContainer},
+		URL:          req.URL.String(),
+		Model:        llmReq.Model,
+		StatusCode:   resp.StatusCode,
+		Request:      llmReq,
+		Response:     response,
+		Reasoning:    reasoning,
+	}
+}
@@ -0,0 +1,53 @@
+package jsonl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewNotifier(NewWriter(&buf))
+
+	netFlow := gopacket.NewFlow(layers.EndpointIPv4, []byte{127, 0, 0, 1}, []byte{127, 0, 0, 1})
+	transport := gopacket.NewFlow(layers.EndpointTCPPort, []byte{0xc3, 0x50}, []byte{0x2c, 0xaa})
+
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost:11434/api/chat", nil)
+	req := httpdumper.NewRequest(r, netFlow, transport)
+	req.Time = time.Unix(1700000000, 0)
+	req.SetBody([]byte(`{"model":"qwen3"}`))
+	n.OnRequest(req)
+
+	resp := httpdumper.NewResponse(req, &http.Response{StatusCode: 200, Header: http.Header{}}, netFlow.Reverse(), transport.Reverse())
+	resp.SetBody([]byte{0xff, 0xfe, 0x00})
+	n.OnResponse(resp)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var reqEvent RequestEvent
+	if err := json.Unmarshal([]byte(lines[0]), &reqEvent); err != nil {
+		t.Fatal(err)
+	}
+	if reqEvent.Type != "request" || reqEvent.Body.Body != `{"model":"qwen3"}` || reqEvent.Dst.Port != "11434" || reqEvent.Src.Port != "50000" {
+		t.Fatalf("unexpected request event: %s", lines[0])
+	}
+
+	var respEvent ResponseEvent
+	if err := json.Unmarshal([]byte(lines[1]), &respEvent); err != nil {
+		t.Fatal(err)
+	}
+	if respEvent.ID != req.ID || respEvent.BodyEncoding != "base64" || respEvent.Body.Body != "//4A" {
+		t.Fatalf("unexpected response event: %s", lines[1])
+	}
+}
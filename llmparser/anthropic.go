@@ -0,0 +1,43 @@
+package llmparser
+
+import (
+	"github.com/tidwall/gjson"
+)
+
+// parseAnthropic 解析anthropic /v1/messages的响应和流式事件，填充到r中
+// 流式响应中tool_use的input是分段的json，需要按内容块合并，见DecodeResponse
+func (r *LLMResponse) parseAnthropic(data []byte) {
+	event := gjson.ParseBytes(data)
+	switch event.Get("type").String() {
+	case "message":
+		// 非流式响应
+		for _, block := range event.Get("content").Array() {
+			switch block.Get("type").String() {
+			case "text":
+				r.text += block.Get("text").String()
+			case "thinking":
+				r.reasoning += block.Get("thinking").String()
+			case "tool_use":
+				r.toolCalls = append(r.toolCalls, newToolCall(block.Get("name").String(), block.Get("input").Raw))
+			}
+		}
+		r.finishReason = event.Get("stop_reason").String()
+	case "message_start":
+		if usage := event.Get("message.usage"); usage.Exists() {
+			r.Usage = &Usage{InputTokens: int(usage.Get("input_tokens").Int()), OutputTokens: int(usage.Get("output_tokens").Int())}
+		}
+	case "content_block_delta":
+		delta := event.Get("delta")
+		switch delta.Get("type").String() {
+		case "text_delta":
+			r.text += delta.Get("text").String()
+		case "thinking_delta":
+			r.reasoning += delta.Get("thinking").String()
+		}
+	case "message_delta":
+		r.finishReason = event.Get("delta.stop_reason").String()
+		if usage := event.Get("usage"); usage.Exists() {
+			r.Usage = &Usage{InputTokens: int(usage.Get("input_tokens").Int()), OutputTokens: int(usage.Get("output_tokens").Int())}
+		}
+	}
+}
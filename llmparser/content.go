@@ -0,0 +1,242 @@
+package llmparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// ContentPart 多模态消息内容中的一部分
+type ContentPart struct {
+	Type     string `json:"type"` // text、image、audio、file
+	Text     string `json:"text,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	URL      string `json:"url,omitempty"`      // 非内联的图片或者文件地址
+	FileName string `json:"filename,omitempty"` // 文件名
+	Size     int    `json:"size,omitempty"`     // 内联数据解码后的字节数
+	Data     []byte `json:"-"`                  // 内联数据，base64解码后
+}
+
+// IsMedia 是否是图片、音频或者文件
+func (p *ContentPart) IsMedia() bool {
+	return p.Type != "text"
+}
+
+// Extension 保存到磁盘时的扩展名
+func (p *ContentPart) Extension() string {
+	switch p.MimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "audio/mpeg":
+		return ".mp3"
+	}
+	if exts, _ := mime.ExtensionsByType(p.MimeType); len(exts) > 0 {
+		return exts[0]
+	}
+	if _, sub, ok := strings.Cut(p.MimeType, "/"); ok && sub != "" && !strings.ContainsAny(sub, "+.;") {
+		return "." + sub
+	}
+	return ".bin"
+}
+
+// Summary 图片、音频、文件的简短描述，如 [image image/png 1024x768 245.3KB]
+func (p *ContentPart) Summary() string {
+	if p.Type == "text" {
+		return p.Text
+	}
+	items := []string{p.Type}
+	if p.MimeType != "" {
+		items = append(items, p.MimeType)
+	}
+	if p.FileName != "" {
+		items = append(items, p.FileName)
+	}
+	if p.Type == "image" && len(p.Data) > 0 {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(p.Data)); err == nil {
+			items = append(items, fmt.Sprintf("%dx%d", cfg.Width, cfg.Height))
+		}
+	}
+	if p.Size > 0 {
+		items = append(items, formatSize(p.Size))
+	}
+	if p.URL != "" {
+		items = append(items, p.URL)
+	}
+	return "[" + strings.Join(items, " ") + "]"
+}
+
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// newDataPart 从base64数据创建内容，mimeType为空时根据内容判断
+func newDataPart(typ, mimeType, data string) ContentPart {
+	part := ContentPart{Type: typ, MimeType: mimeType}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	}
+	if err == nil {
+		part.Data = decoded
+		part.Size = len(decoded)
+		if part.MimeType == "" {
+			part.MimeType = http.DetectContentType(decoded)
+		}
+	}
+	return part
+}
+
+// newURLPart 从url创建内容，支持data:image/png;base64,...格式的内联数据
+func newURLPart(typ, url string) ContentPart {
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		if header, data, ok := strings.Cut(rest, ","); ok && strings.HasSuffix(header, ";base64") {
+			return newDataPart(typ, strings.TrimSuffix(header, ";base64"), data)
+		}
+	}
+	return ContentPart{Type: typ, URL: url}
+}
+
+// parseSource anthropic的source：{"type":"base64","media_type":"image/png","data":"..."} 或者 {"type":"url","url":"..."}
+func parseSource(typ string, source gjson.Result) ContentPart {
+	switch source.Get("type").String() {
+	case "base64":
+		return newDataPart(typ, source.Get("media_type").String(), source.Get("data").String())
+	case "text":
+		return ContentPart{Type: "text", Text: source.Get("data").String()}
+	}
+	return ContentPart{Type: typ, URL: source.Get("url").String()}
+}
+
+// parsePart 解析openai、anthropic、openai responses格式的内容块
+func parsePart(block gjson.Result) (ContentPart, bool) {
+	if block.Type == gjson.String {
+		return ContentPart{Type: "text", Text: block.String()}, true
+	}
+
+	switch typ := block.Get("type").String(); typ {
+	case "text", "input_text", "output_text":
+		return ContentPart{Type: "text", Text: block.Get("text").String()}, true
+	case "image_url":
+		url := block.Get("image_url")
+		if url.IsObject() {
+			url = url.Get("url")
+		}
+		return newURLPart("image", url.String()), true
+	case "input_image":
+		return newURLPart("image", block.Get("image_url").String()), true
+	case "image":
+		return parseSource("image", block.Get("source")), true
+	case "input_audio":
+		audio := block.Get("input_audio")
+		return newDataPart("audio", "audio/"+audio.Get("format").String(), audio.Get("data").String()), true
+	case "file", "input_file":
+		file := block
+		if typ == "file" {
+			file = block.Get("file")
+		}
+		var part ContentPart
+		if data := file.Get("file_data"); data.Exists() {
+			part = newURLPart("file", data.String())
+		} else {
+			part = ContentPart{Type: "file", URL: file.Get("file_id").String()}
+		}
+		part.FileName = file.Get("filename").String()
+		return part, true
+	case "document":
+		part := parseSource("file", block.Get("source"))
+		part.FileName = block.Get("title").String()
+		return part, true
+	}
+
+	// 其他类型的内容块，有文本的保留文本
+	if text := block.Get("text"); text.Exists() {
+		return ContentPart{Type: "text", Text: text.String()}, true
+	}
+	return ContentPart{}, false
+}
+
+// parseContent 解析字符串或者内容块数组，返回所有文本块用空行连接后的文本和全部内容块
+func parseContent(v gjson.Result) (string, []ContentPart) {
+	if !v.IsArray() {
+		return v.String(), nil
+	}
+	var texts []string
+	var parts []ContentPart
+	for _, block := range v.Array() {
+		part, ok := parsePart(block)
+		if !ok {
+			continue
+		}
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(texts, "\n\n"), parts
+}
+
+// textContent 提取字符串或者内容块数组中的文本
+func textContent(v gjson.Result) string {
+	text, _ := parseContent(v)
+	return text
+}
+
+// ImageParts ollama的images字段，base64编码的图片列表
+func ImageParts(images []string) []ContentPart {
+	var parts []ContentPart
+	for _, data := range images {
+		parts = append(parts, newDataPart("image", "", data))
+	}
+	return parts
+}
+
+// UnmarshalJSON content可以是字符串，也可以是openai/anthropic的内容块数组
+// Content保留所有文本块，图片、音频、文件放在Parts中，ollama的images也会解码到Parts
+func (m *LLMMessage) UnmarshalJSON(data []byte) error {
+	type alias LLMMessage
+	aux := struct {
+		*alias
+		Content          json.RawMessage `json:"content"`
+		Thinking         string          `json:"thinking"`
+		ReasoningContent string          `json:"reasoning_content"`
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	// 有些服务同时返回reasoning和reasoning_content，内容相同，只取第一个非空的
+	for _, reasoning := range []string{aux.Thinking, aux.ReasoningContent} {
+		if m.Reasoning == "" {
+			m.Reasoning = reasoning
+		}
+	}
+	m.Content, m.Parts = parseContent(gjson.ParseBytes(aux.Content))
+	m.Parts = append(m.Parts, ImageParts(m.Images)...)
+	return nil
+}
+
+// Media 消息中的图片、音频和文件
+func (m *LLMMessage) Media() []ContentPart {
+	var media []ContentPart
+	for _, part := range m.Parts {
+		if part.IsMedia() {
+			media = append(media, part)
+		}
+	}
+	return media
+}
@@ -0,0 +1,67 @@
+package llmparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestMessageContent(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	img := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	cases := []struct {
+		name, data string
+		content    string
+		media      []string // 每个媒体内容的Summary前缀
+	}{
+		{"string", `{"role":"user","content":"hi"}`, "hi", nil},
+		{"null", `{"role":"assistant","content":null,"tool_calls":[{"function":{"name":"f","arguments":"{}"}}]}`, "", nil},
+		{"ollama", `{"role":"user","content":"what is it","images":["` + img + `"]}`, "what is it", []string{"[image image/png 4x3"}},
+		{"openai", `{"role":"user","content":[{"type":"text","text":"look"},{"type":"image_url","image_url":{"url":"data:image/png;base64,` + img + `"}},{"type":"image_url","image_url":{"url":"https://example.com/a.jpg"}},{"type":"text","text":"please"}]}`,
+			"look\n\nplease", []string{"[image image/png 4x3", "[image https://example.com/a.jpg]"}},
+		{"openai audio", `{"role":"user","content":[{"type":"input_audio","input_audio":{"data":"AAAA","format":"wav"}}]}`, "", []string{"[audio audio/wav 3B]"}},
+		{"anthropic", `{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + img + `"}},{"type":"text","text":"describe"}]}`,
+			"describe", []string{"[image image/png 4x3"}},
+		{"file", `{"role":"user","content":[{"type":"file","file":{"filename":"a.pdf","file_data":"data:application/pdf;base64,JVBERi0="}}]}`, "", []string{"[file application/pdf a.pdf 5B]"}},
+	}
+	for _, c := range cases {
+		var msg LLMMessage
+		if err := json.Unmarshal([]byte(c.data), &msg); err != nil {
+			t.Fatal(c.name, err)
+		}
+		if msg.Content != c.content {
+			t.Fatalf("%s: content %q, want %q", c.name, msg.Content, c.content)
+		}
+		media := msg.Media()
+		if len(media) != len(c.media) {
+			t.Fatalf("%s: %d media, want %d", c.name, len(media), len(c.media))
+		}
+		for i, part := range media {
+			if !strings.HasPrefix(part.Summary(), c.media[i]) {
+				t.Fatalf("%s: summary %q, want prefix %q", c.name, part.Summary(), c.media[i])
+			}
+		}
+	}
+}
+
+func TestParseRequestMultimodal(t *testing.T) {
+	llmReq := ParseRequest(newRequest(t, "/v1/chat/completions",
+		`{"model":"gpt-4o","messages":[{"role":"system","content":[{"type":"text","text":"be brief"}]},{"role":"user","content":[{"type":"text","text":"hi"},{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}]}`))
+	if llmReq == nil {
+		t.Fatal("multimodal request should be parsed")
+	}
+	if llmReq.Messages[1].Content != "hi" || len(llmReq.Messages[1].Media()) != 1 {
+		t.Fatal(llmReq.Messages[1])
+	}
+	if system := llmReq.SystemPrompts(); len(system) != 1 || system[0] != "be brief" {
+		t.Fatal(system)
+	}
+}
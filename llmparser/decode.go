@@ -0,0 +1,230 @@
+package llmparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/tidwall/gjson"
+)
+
+// SSEEvent server-sent events中的一个事件
+type SSEEvent struct {
+	Event string `json:"event,omitempty"`
+	ID    string `json:"id,omitempty"`
+	Data  string `json:"data"`
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// ParseSSE 按照server-sent events规范解析：
+// 行结束符可以是CRLF、LF或者CR，多行data用\n连接，冒号开头的是注释，字段值前的一个空格会去掉，空行分发事件
+// 没有data的事件会被忽略，最后一个事件即使没有空行结尾也会返回
+func ParseSSE(body []byte) []SSEEvent {
+	var events []SSEEvent
+	var event SSEEvent
+	var data []string
+	hasData := false
+	dispatch := func() {
+		if hasData {
+			event.Data = strings.Join(data, "\n")
+			events = append(events, event)
+		}
+		event, data, hasData = SSEEvent{}, nil, false
+	}
+
+	// 规范要求忽略开头的UTF-8 BOM
+	body = bytes.TrimPrefix(body, utf8BOM)
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+	body = bytes.ReplaceAll(body, []byte("\r"), []byte("\n"))
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" {
+			dispatch()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				event.ID = value
+			}
+		}
+	}
+	dispatch()
+	return events
+}
+
+// DecodedResponse 重组后的完整响应
+type DecodedResponse struct {
+	Content      string     `json:"content"`
+	Reasoning    string     `json:"reasoning,omitempty"`
+	ToolCalls    []LLMTool  `json:"tool_calls,omitempty"`
+	Metrics      *Metrics   `json:"metrics,omitempty"`
+	FinishReason string     `json:"finish_reason,omitempty"`
+	Error        *LLMError  `json:"error,omitempty"`
+	Events       []SSEEvent `json:"events,omitempty"` // sse的每个事件、ndjson的每一行，或者整个json
+}
+
+// partialKey 分段的工具调用属于哪个choice的第几个工具调用，anthropic没有choice，为0
+type partialKey struct {
+	choice, index int64
+}
+
+// partialToolCall 流式响应中分段的工具调用
+type partialToolCall struct {
+	name      string
+	arguments strings.Builder
+}
+
+// decoder 合并流式响应中的事件
+type decoder struct {
+	result   DecodedResponse
+	partials map[partialKey]*partialToolCall // openai的choice.index和delta.tool_calls的index，或者anthropic内容块的index
+}
+
+// DecodeResponse 根据Content-Type解析json、ndjson或者sse响应，重组为完整的响应
+// 没有Content-Type时根据内容判断，支持ollama、openai、anthropic、openai responses api、lmstudio和llama.cpp的格式
+func DecodeResponse(resp *httpdumper.Response) *DecodedResponse {
+	d := &decoder{partials: make(map[partialKey]*partialToolCall)}
+
+	ct := ""
+	if resp.Response != nil {
+		ct = resp.Header.Get("Content-Type")
+	}
+	body := bytes.TrimSpace(bytes.TrimPrefix(resp.Body, utf8BOM))
+	switch {
+	case strings.HasPrefix(ct, "text/event-stream"):
+		d.addSSE(resp.Body)
+	case strings.HasPrefix(ct, "application/x-ndjson"):
+		d.addLines(body)
+	case strings.HasPrefix(ct, "application/json"):
+		d.add(SSEEvent{Data: string(body)})
+	case bytes.HasPrefix(body, []byte("data:")) || bytes.HasPrefix(body, []byte("event:")) || bytes.HasPrefix(body, []byte(":")):
+		d.addSSE(resp.Body)
+	case json.Valid(body):
+		d.add(SSEEvent{Data: string(body)})
+	case len(body) > 0 && body[0] == '{':
+		d.addLines(body)
+	}
+	return d.finish(resp)
+}
+
+func (d *decoder) addSSE(body []byte) {
+	for _, event := range ParseSSE(body) {
+		d.add(event)
+	}
+}
+
+func (d *decoder) addLines(body []byte) {
+	for _, line := range strings.Split(string(body), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			d.add(SSEEvent{Data: line})
+		}
+	}
+}
+
+// add 合并一个事件
+func (d *decoder) add(event SSEEvent) {
+	d.result.Events = append(d.result.Events, event)
+	data := strings.TrimSpace(event.Data)
+	if data == "" || data == "[DONE]" {
+		return
+	}
+	var llmResp LLMResponse
+	if err := json.Unmarshal([]byte(data), &llmResp); err != nil {
+		return
+	}
+
+	r := &d.result
+	r.Content += llmResp.String()
+	r.Reasoning += llmResp.Reasoning()
+	r.ToolCalls = append(r.ToolCalls, llmResp.ToolCalls()...)
+	r.Metrics = r.Metrics.Merge(llmResp.Metrics())
+	if reason := llmResp.FinishReason(); reason != "" {
+		r.FinishReason = reason
+	}
+	if llmResp.Error != nil {
+		r.Error = llmResp.Error
+	}
+	d.addPartialToolCalls(gjson.Parse(data))
+}
+
+// addPartialToolCalls 合并openai delta.tool_calls和anthropic input_json_delta中分段的工具调用
+func (d *decoder) addPartialToolCalls(event gjson.Result) {
+	partial := func(key partialKey) *partialToolCall {
+		p, ok := d.partials[key]
+		if !ok {
+			p = &partialToolCall{}
+			d.partials[key] = p
+		}
+		return p
+	}
+
+	for _, choice := range event.Get("choices").Array() {
+		for _, call := range choice.Get("delta.tool_calls").Array() {
+			p := partial(partialKey{choice.Get("index").Int(), call.Get("index").Int()})
+			if name := call.Get("function.name").String(); name != "" {
+				p.name = name
+			}
+			// ollama的openai兼容api一次返回完整的arguments对象
+			if args := call.Get("function.arguments"); args.Type == gjson.String {
+				p.arguments.WriteString(args.String())
+			} else if args.IsObject() {
+				p.arguments.WriteString(args.Raw)
+			}
+		}
+	}
+
+	switch event.Get("type").String() {
+	case "content_block_start":
+		if block := event.Get("content_block"); block.Get("type").String() == "tool_use" {
+			p := partial(partialKey{index: event.Get("index").Int()})
+			p.name = block.Get("name").String()
+		}
+	case "content_block_delta":
+		if delta := event.Get("delta"); delta.Get("type").String() == "input_json_delta" {
+			partial(partialKey{index: event.Get("index").Int()}).arguments.WriteString(delta.Get("partial_json").String())
+		}
+	}
+}
+
+// finish 按index顺序添加合并后的工具调用，分离思考过程，最后检查错误
+func (d *decoder) finish(resp *httpdumper.Response) *DecodedResponse {
+	keys := make([]partialKey, 0, len(d.partials))
+	for key := range d.partials {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].choice != keys[j].choice {
+			return keys[i].choice < keys[j].choice
+		}
+		return keys[i].index < keys[j].index
+	})
+	for _, key := range keys {
+		p := d.partials[key]
+		if p.name == "" {
+			continue
+		}
+		args := p.arguments.String()
+		if args == "" {
+			args = "{}"
+		}
+		d.result.ToolCalls = append(d.result.ToolCalls, newToolCall(p.name, args))
+	}
+
+	r := &d.result
+	r.Content, r.Reasoning = ExtractReasoning(r.Content, r.Reasoning)
+	r.Error = ResponseError(resp, r.Error)
+	return r
+}
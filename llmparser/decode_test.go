@@ -0,0 +1,150 @@
+package llmparser
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+)
+
+func newTestResponse(contentType, body string) *httpdumper.Response {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp := httpdumper.NewResponse(nil, &http.Response{StatusCode: 200, Status: "200 OK", Header: header}, gopacket.Flow{}, gopacket.Flow{})
+	resp.SetBody([]byte(body))
+	return resp
+}
+
+func TestParseSSE(t *testing.T) {
+	body := ": keep-alive\r\n" +
+		"event: message\r\nid: 1\r\ndata: first\r\ndata:second\r\n\r\n" +
+		"id\r\n\r\n" +
+		"data: {\"a\":1}\r" +
+		"\rdata: last"
+	events := ParseSSE([]byte(body))
+	if len(events) != 3 {
+		t.Fatalf("got %d events: %+v", len(events), events)
+	}
+	if events[0].Event != "message" || events[0].ID != "1" || events[0].Data != "first\nsecond" {
+		t.Fatalf("unexpected first event: %+v", events[0])
+	}
+	if events[1].Data != `{"a":1}` || events[1].Event != "" {
+		t.Fatalf("unexpected second event: %+v", events[1])
+	}
+	if events[2].Data != "last" {
+		t.Fatalf("unexpected last event: %+v", events[2])
+	}
+
+	// 开头的BOM不属于第一个字段名
+	if events = ParseSSE([]byte("\xef\xbb\xbfdata: bom\n\n")); len(events) != 1 || events[0].Data != "bom" {
+		t.Fatalf("unexpected events with bom: %+v", events)
+	}
+	if r := DecodeResponse(newTestResponse("", "\xef\xbb\xbfdata: {\"response\":\"hi\"}\n\n")); r.Content != "hi" {
+		t.Fatalf("unexpected content with bom: %q", r.Content)
+	}
+}
+
+func TestDecodeResponseMultipleChoices(t *testing.T) {
+	body := `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}
+
+data: {"choices":[{"index":1,"delta":{"tool_calls":[{"index":0,"function":{"name":"get_time","arguments":"{\"tz\":\"CET\"}"}}]}}]}
+
+`
+	r := DecodeResponse(newTestResponse("text/event-stream", body))
+	if len(r.ToolCalls) != 2 || r.ToolCalls[0].Function.Name != "get_weather" || r.ToolCalls[1].Function.Name != "get_time" ||
+		r.ToolCalls[1].Function.Arguments["tz"] != "CET" {
+		t.Fatalf("tool calls of different choices should not be merged: %+v", r.ToolCalls)
+	}
+}
+
+func TestDecodeResponseOpenAIToolCalls(t *testing.T) {
+	body := `data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Let me check."}}]}
+
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}
+
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}
+
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}
+
+data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":12,"completion_tokens":7}}
+
+data: [DONE]
+
+`
+	r := DecodeResponse(newTestResponse("text/event-stream", body))
+	if r.Content != "Let me check." {
+		t.Fatalf("unexpected content: %q", r.Content)
+	}
+	if len(r.ToolCalls) != 1 || r.ToolCalls[0].Function.Name != "get_weather" || r.ToolCalls[0].Function.Arguments["city"] != "Paris" {
+		t.Fatalf("unexpected tool calls: %+v", r.ToolCalls)
+	}
+	if r.FinishReason != "tool_calls" {
+		t.Fatalf("unexpected finish reason: %q", r.FinishReason)
+	}
+	if r.Metrics == nil || r.Metrics.PromptTokens != 12 || r.Metrics.CompletionTokens != 7 {
+		t.Fatalf("unexpected metrics: %+v", r.Metrics)
+	}
+	if len(r.Events) != 6 {
+		t.Fatalf("got %d events", len(r.Events))
+	}
+}
+
+func TestDecodeResponseAnthropic(t *testing.T) {
+	body := "event: message_start\r\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":20,\"output_tokens\":1}}}\r\n\r\n" +
+		"event: content_block_start\r\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"thinking\",\"thinking\":\"\"}}\r\n\r\n" +
+		"event: content_block_delta\r\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"User wants files.\"}}\r\n\r\n" +
+		"event: content_block_start\r\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\r\n\r\n" +
+		"event: content_block_delta\r\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"text_delta\",\"text\":\"Listing.\"}}\r\n\r\n" +
+		"event: content_block_start\r\ndata: {\"type\":\"content_block_start\",\"index\":2,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"ls\",\"input\":{}}}\r\n\r\n" +
+		"event: content_block_delta\r\ndata: {\"type\":\"content_block_delta\",\"index\":2,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"path\\\":\"}}\r\n\r\n" +
+		"event: content_block_delta\r\ndata: {\"type\":\"content_block_delta\",\"index\":2,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"\\\"/tmp\\\"}\"}}\r\n\r\n" +
+		"event: message_delta\r\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":30}}\r\n\r\n" +
+		"event: message_stop\r\ndata: {\"type\":\"message_stop\"}\r\n\r\n"
+	r := DecodeResponse(newTestResponse("text/event-stream; charset=utf-8", body))
+	if r.Content != "Listing." || r.Reasoning != "User wants files." {
+		t.Fatalf("unexpected content %q, reasoning %q", r.Content, r.Reasoning)
+	}
+	if len(r.ToolCalls) != 1 || r.ToolCalls[0].Function.Name != "ls" || r.ToolCalls[0].Function.Arguments["path"] != "/tmp" {
+		t.Fatalf("unexpected tool calls: %+v", r.ToolCalls)
+	}
+	if r.FinishReason != "tool_use" {
+		t.Fatalf("unexpected finish reason: %q", r.FinishReason)
+	}
+	if r.Events[0].Event != "message_start" {
+		t.Fatalf("unexpected event: %+v", r.Events[0])
+	}
+}
+
+func TestDecodeResponseNDJSON(t *testing.T) {
+	body := "{\"message\":{\"role\":\"assistant\",\"content\":\"<think>hmm</think>Hel\"},\"done\":false}\r\n" +
+		"{\"message\":{\"role\":\"assistant\",\"content\":\"lo\"},\"done\":true,\"done_reason\":\"stop\",\"prompt_eval_count\":5,\"eval_count\":3}\r\n"
+	// 没有Content-Type时根据内容判断
+	for _, ct := range []string{"application/x-ndjson", ""} {
+		r := DecodeResponse(newTestResponse(ct, body))
+		if r.Content != "Hello" || r.Reasoning != "hmm" {
+			t.Fatalf("%q: unexpected content %q, reasoning %q", ct, r.Content, r.Reasoning)
+		}
+		if r.FinishReason != "stop" || len(r.Events) != 2 {
+			t.Fatalf("%q: unexpected finish reason %q, %d events", ct, r.FinishReason, len(r.Events))
+		}
+	}
+}
+
+func TestDecodeResponseToolCallsNotInContent(t *testing.T) {
+	bodies := []string{
+		`{"model":"qwen3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"x"}}}]},"done":true}`,
+		`{"choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"x\"}"}}]},"finish_reason":"tool_calls"}]}`,
+	}
+	for _, body := range bodies {
+		r := DecodeResponse(newTestResponse("application/json", body))
+		if r.Content != "" {
+			t.Fatalf("tool calls should not be in content: %q", r.Content)
+		}
+		if len(r.ToolCalls) != 1 || r.ToolCalls[0].Function.Arguments["city"] != "x" {
+			t.Fatalf("unexpected tool calls: %+v", r.ToolCalls)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package llmparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/tidwall/gjson"
+)
+
+// LLMError 错误响应或者流式响应中的错误事件
+// ollama和lmstudio是 {"error":"..."}，openai、anthropic和llama.cpp是 {"error":{"message":...,"type":...,"code":...}}
+type LLMError struct {
+	Status  int    `json:"status,omitempty"` // http状态码
+	Message string `json:"message"`
+	Type    string `json:"type,omitempty"`
+	Code    string `json:"code,omitempty"` // openai是字符串，llama.cpp是数字
+	Param   string `json:"param,omitempty"`
+}
+
+// UnmarshalJSON error可以是字符串，也可以是对象
+func (e *LLMError) UnmarshalJSON(data []byte) error {
+	v := gjson.ParseBytes(data)
+	if !v.IsObject() {
+		e.Message = v.String()
+		return nil
+	}
+	e.Message = v.Get("message").String()
+	e.Type = v.Get("type").String()
+	e.Code = v.Get("code").String()
+	e.Param = v.Get("param").String()
+	return nil
+}
+
+// String 比如 HTTP 404: model "qwen9" not found (not_found_error)
+func (e *LLMError) String() string {
+	var details []string
+	for _, s := range []string{e.Type, e.Code} {
+		if s != "" && !strings.Contains(e.Message, s) {
+			details = append(details, s)
+		}
+	}
+	if e.Param != "" {
+		details = append(details, "param "+e.Param)
+	}
+	s := e.Message
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	if e.Status > 0 {
+		s = fmt.Sprintf("HTTP %d: %s", e.Status, s)
+	}
+	return s
+}
+
+// maxErrorBody 非json错误响应最多显示的字节数
+const maxErrorBody = 1024
+
+// ResponseError 响应的错误：body或者流式事件中解析出的错误，或者4xx/5xx状态码时的原始body
+// 没有错误时返回nil
+func ResponseError(resp *httpdumper.Response, parsed *LLMError) *LLMError {
+	status := 0
+	if resp.Response != nil {
+		status = resp.StatusCode
+	}
+	if parsed != nil {
+		e := *parsed
+		if status >= 400 {
+			e.Status = status
+		}
+		return &e
+	}
+	if status < 400 {
+		return nil
+	}
+
+	e := &LLMError{Status: status, Message: strings.TrimSpace(string(resp.Body))}
+	if body := gjson.ParseBytes(resp.Body); body.IsObject() {
+		// 其他格式的json错误，比如 {"detail":"..."} 或者 {"message":"..."}
+		for _, key := range []string{"message", "detail", "error_message"} {
+			if v := body.Get(key); v.Exists() {
+				e.Message = v.String()
+				break
+			}
+		}
+	}
+	if !utf8.ValidString(e.Message) {
+		e.Message = fmt.Sprintf("%d bytes of binary data", len(resp.Body))
+	}
+	if len(e.Message) > maxErrorBody {
+		n := maxErrorBody
+		for n > 0 && !utf8.RuneStart(e.Message[n]) {
+			n--
+		}
+		e.Message = e.Message[:n] + "…"
+	}
+	if e.Message == "" && resp.Response != nil {
+		e.Message = resp.Status
+	}
+	return e
+}
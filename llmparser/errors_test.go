@@ -0,0 +1,46 @@
+package llmparser
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+)
+
+func TestResponseError(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"ollama", 404, `{"error":"model \"qwen9\" not found, try pulling it first"}`, `HTTP 404: model "qwen9" not found, try pulling it first`},
+		{"openai", 401, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key","param":null}}`,
+			"HTTP 401: Incorrect API key provided (invalid_request_error, invalid_api_key)"},
+		{"anthropic", 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, "HTTP 529: Overloaded (overloaded_error)"},
+		{"llama.cpp", 400, `{"error":{"code":400,"message":"the request exceeds the available context size","type":"exceed_context_size_error"}}`,
+			"HTTP 400: the request exceeds the available context size (exceed_context_size_error, 400)"},
+		{"ollama stream", 200, `{"error":"an error was encountered while running the model"}`, "an error was encountered while running the model"},
+		{"responses error event", 200, `{"type":"error","code":"rate_limit_exceeded","message":"Rate limit reached"}`, "Rate limit reached (rate_limit_exceeded)"},
+		{"responses failed", 200, `{"type":"response.failed","response":{"status":"failed","error":{"code":"server_error","message":"The model failed"}}}`,
+			"The model failed (server_error)"},
+		{"plain text", 502, "Bad Gateway\n", "HTTP 502: Bad Gateway"},
+		{"ok", 200, `{"message":{"role":"assistant","content":"hi"}}`, ""},
+	}
+	for _, c := range cases {
+		resp := httpdumper.NewResponse(nil, &http.Response{StatusCode: c.status, Status: http.StatusText(c.status)}, gopacket.Flow{}, gopacket.Flow{})
+		resp.SetBody([]byte(c.body))
+		var parsed *LLMError
+		if llmResp := ParseResponse(resp); llmResp != nil {
+			parsed = llmResp.Error
+		}
+		got := ""
+		if e := ResponseError(resp, parsed); e != nil {
+			got = e.String()
+		}
+		if got != c.want {
+			t.Fatalf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
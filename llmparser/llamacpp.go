@@ -0,0 +1,241 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/tidwall/gjson"
+)
+
+// llama.cpp llama-server原生接口中对话和生成以外的接口
+var llamaCppEndpoints = map[string]bool{
+	"/tokenize":   true,
+	"/detokenize": true,
+	"/embedding":  true,
+	"/embeddings": true, // 非openai格式的别名
+	"/slots":      true,
+}
+
+// isLlamaCppPath llama-server原生接口的路径，包括/completion、/infill和重排序
+func isLlamaCppPath(path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	return path == "/completion" || path == "/infill" || path == "/rerank" || path == "/reranking" ||
+		llamaCppEndpoints[path] || strings.HasPrefix(path, "/slots/")
+}
+
+// LlamaCppTimings llama.cpp响应中的耗时统计，时间单位为毫秒
+type LlamaCppTimings struct {
+	PromptN             int     `json:"prompt_n"`
+	PromptMS            float64 `json:"prompt_ms"`
+	PromptPerSecond     float64 `json:"prompt_per_second"`
+	PredictedN          int     `json:"predicted_n"`
+	PredictedMS         float64 `json:"predicted_ms"`
+	PredictedPerSecond  float64 `json:"predicted_per_second"`
+	PredictedPerTokenMS float64 `json:"predicted_per_token_ms"`
+}
+
+// metrics 转换为统一的指标
+func (t *LlamaCppTimings) metrics() *Metrics {
+	return &Metrics{
+		PromptTokens:     t.PromptN,
+		CompletionTokens: t.PredictedN,
+		PromptDuration:   milliseconds(t.PromptMS),
+		EvalDuration:     milliseconds(t.PredictedMS),
+		TokensPerSecond:  t.PredictedPerSecond,
+	}
+}
+
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// InfillExtra llama.cpp /infill的input_extra，额外的上下文文件
+type InfillExtra struct {
+	FileName string `json:"filename"`
+	Text     string `json:"text"`
+}
+
+// llamaCppPrompt llama.cpp的prompt可以是字符串、token数组、字符串和token混合的数组，或者多个prompt的数组
+// token显示为[N tokens]
+func llamaCppPrompt(v gjson.Result) string {
+	if !v.IsArray() {
+		return v.String()
+	}
+	var parts []string
+	tokens := 0
+	flush := func() {
+		if tokens > 0 {
+			parts = append(parts, fmt.Sprintf("[%d tokens]", tokens))
+			tokens = 0
+		}
+	}
+	for _, item := range v.Array() {
+		if item.Type == gjson.Number {
+			tokens++
+			continue
+		}
+		flush()
+		parts = append(parts, llamaCppPrompt(item))
+	}
+	flush()
+	return strings.Join(parts, "")
+}
+
+var grammarRuleRe = regexp.MustCompile(`(?m)^\s*([a-zA-Z0-9_-]+)\s*::=`)
+
+// GrammarRules llama.cpp的GBNF语法中定义的规则名
+func (o *GenerationOptions) GrammarRules() []string {
+	var rules []string
+	for _, m := range grammarRuleRe.FindAllStringSubmatch(o.Grammar, -1) {
+		rules = append(rules, m[1])
+	}
+	return rules
+}
+
+// LlamaCppRequest llama.cpp的分词、向量和slot接口
+type LlamaCppRequest struct {
+	Endpoint string `json:"-"`
+	Action   string `json:"-"` // /slots/{id}?action=save|restore|erase
+
+	Content    string `json:"content"` // tokenize/embedding
+	Input      any    `json:"input"`   // embedding也可以使用input
+	Tokens     []any  `json:"tokens"`  // detokenize
+	WithPieces bool   `json:"with_pieces,omitempty"`
+}
+
+// ParseLlamaCppRequest 解析llama.cpp的分词、向量和slot请求，不是这些接口时返回nil
+func ParseLlamaCppRequest(req *httpdumper.Request) *LlamaCppRequest {
+	if req.URL == nil {
+		return nil
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	r := &LlamaCppRequest{Endpoint: path}
+	switch {
+	case strings.HasPrefix(path, "/slots/"):
+		r.Action = req.URL.Query().Get("action")
+	case !llamaCppEndpoints[path]:
+		return nil
+	}
+	if len(req.Body) > 0 {
+		if err := json.Unmarshal(req.Body, r); err != nil {
+			return nil
+		}
+	}
+	return r
+}
+
+// Provider 模型服务的类型
+func (r *LlamaCppRequest) Provider() Provider {
+	return ProviderLlamaCpp
+}
+
+// Retrieval 向量计算的输入，其他接口返回nil
+func (r *LlamaCppRequest) Retrieval() *Retrieval {
+	if r.Endpoint != "/embedding" && r.Endpoint != "/embeddings" {
+		return nil
+	}
+	inputs := inputStrings(r.Input)
+	if r.Content != "" {
+		inputs = append(inputs, r.Content)
+	}
+	return &Retrieval{Kind: "embed", Inputs: inputs}
+}
+
+// Summary 一行摘要
+func (r *LlamaCppRequest) Summary() string {
+	switch r.Endpoint {
+	case "/tokenize":
+		return fmt.Sprintf("tokenize %d characters", len([]rune(r.Content)))
+	case "/detokenize":
+		return fmt.Sprintf("detokenize %d tokens", len(r.Tokens))
+	case "/embedding", "/embeddings":
+		return r.Retrieval().Summary()
+	case "/slots":
+		return "list slots"
+	}
+	return fmt.Sprintf("slot %s %s", strings.TrimPrefix(r.Endpoint, "/slots/"), r.Action)
+}
+
+// LlamaCppResponse llama.cpp的分词、向量和slot接口的响应
+type LlamaCppResponse struct {
+	Endpoint string
+	lines    []string
+}
+
+// Summary 多行摘要，每行一项
+func (r *LlamaCppResponse) Summary() []string {
+	return r.lines
+}
+
+// ParseResponse 解析对应的响应
+func (r *LlamaCppRequest) ParseResponse(resp *httpdumper.Response) APIResponse {
+	body := gjson.ParseBytes(resp.Body)
+	result := &LlamaCppResponse{Endpoint: r.Endpoint}
+	if msg := errorMessage(body); msg != "" {
+		result.lines = []string{"error: " + msg}
+		return result
+	}
+
+	switch r.Endpoint {
+	case "/tokenize":
+		tokens := body.Get("tokens").Array()
+		line := fmt.Sprintf("%d tokens", len(tokens))
+		// with_pieces时每个token是{"id":1,"piece":"hello"}
+		var pieces []string
+		for _, token := range tokens {
+			if piece := token.Get("piece"); piece.Exists() {
+				pieces = append(pieces, fmt.Sprintf("%q", piece.String()))
+			}
+		}
+		if len(pieces) > 0 {
+			line += ": " + strings.Join(pieces, " ")
+		}
+		result.lines = []string{line}
+	case "/detokenize":
+		content := body.Get("content").String()
+		result.lines = []string{fmt.Sprintf("%d characters: %s", len([]rune(content)), content)}
+	case "/embedding", "/embeddings":
+		// [{"index":0,"embedding":[[...]]}]，旧版本是{"embedding":[...]}
+		var vectors []gjson.Result
+		items := body.Array()
+		if body.IsObject() {
+			items = []gjson.Result{body}
+		}
+		for _, item := range items {
+			embedding := item.Get("embedding")
+			if first := embedding.Get("0"); first.IsArray() {
+				vectors = append(vectors, embedding.Array()...)
+			} else {
+				vectors = append(vectors, embedding)
+			}
+		}
+		dims := 0
+		if len(vectors) > 0 {
+			dims = len(vectors[0].Array())
+		}
+		result.lines = []string{fmt.Sprintf("%d embeddings, %d dimensions", len(vectors), dims)}
+	case "/slots":
+		slots := body.Array()
+		result.lines = []string{fmt.Sprintf("%d slots", len(slots))}
+		for _, slot := range slots {
+			state := "idle"
+			if slot.Get("is_processing").Bool() {
+				state = "processing"
+			}
+			result.lines = append(result.lines, fmt.Sprintf("  #%d n_ctx %d, %s", slot.Get("id").Int(), slot.Get("n_ctx").Int(), state))
+		}
+	default:
+		if n := body.Get("n_saved"); n.Exists() {
+			result.lines = []string{fmt.Sprintf("saved %d tokens to %s", n.Int(), body.Get("filename").String())}
+		} else if n := body.Get("n_restored"); n.Exists() {
+			result.lines = []string{fmt.Sprintf("restored %d tokens from %s", n.Int(), body.Get("filename").String())}
+		} else if n := body.Get("n_erased"); n.Exists() {
+			result.lines = []string{fmt.Sprintf("erased %d tokens", n.Int())}
+		}
+	}
+	return result
+}
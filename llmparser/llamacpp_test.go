@@ -0,0 +1,107 @@
+package llmparser
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+)
+
+func TestLlamaCppCompletion(t *testing.T) {
+	req := newRequest(t, "/completion", `{"prompt":["<|im_start|>user\n",[1,2,3],"hi"],"n_predict":64,"top_k":40,"cache_prompt":true,"stream":true,
+		"grammar":"root ::= answer\nanswer ::= \"yes\" | \"no\""}`)
+	llmReq := ParseRequest(req)
+	if llmReq == nil || DetectProvider(req) != ProviderLlamaCpp {
+		t.Fatal("parse llama.cpp request failed")
+	}
+	if llmReq.Prompt != "<|im_start|>user\n[3 tokens]hi" {
+		t.Fatalf("%q", llmReq.Prompt)
+	}
+	if got := strings.Join(llmReq.Summary(), " "); got != "stream=true format=gbnf n_predict=64 top_k=40 cache_prompt=true" {
+		t.Fatal(got)
+	}
+	if rules := llmReq.GrammarRules(); strings.Join(rules, ",") != "root,answer" || llmReq.MaxOutputTokens() != 64 {
+		t.Fatal(rules)
+	}
+
+	// 浮点数写法的参数不影响解析
+	if lenient := ParseRequest(newRequest(t, "/completion", `{"prompt":"hi","n_predict":128.0,"top_k":40.0}`)); lenient == nil ||
+		lenient.MaxOutputTokens() != 128 || *lenient.TopK != 40 {
+		t.Fatal("n_predict and top_k should be decoded leniently")
+	}
+	for _, path := range []string{"/completion_probabilities", "/api/infill/history", "/completions/export"} {
+		if IsLLMRequest(newRequest(t, path, `{"prompt":"hi"}`)) {
+			t.Fatal(path, "should not be an llm request")
+		}
+	}
+	if !IsLLMRequest(newRequest(t, "/llama/completion?slot=1", `{"prompt":"hi"}`)) {
+		t.Fatal("proxied /completion should be an llm request")
+	}
+
+	infill := ParseRequest(newRequest(t, "/infill", `{"input_prefix":"def add(a, b):\n","input_suffix":"\n","input_extra":[{"filename":"utils.py","text":"x = 1"}],"prompt":""}`))
+	if infill == nil || infill.InputPrefix != "def add(a, b):\n" || infill.InputExtra[0].FileName != "utils.py" {
+		t.Fatal("parse infill request failed")
+	}
+
+	chunks := []string{
+		`{"content":"yes","stop":false}`,
+		`{"content":"","stop":true,"stop_type":"eos","timings":{"prompt_n":12,"prompt_ms":24,"prompt_per_second":500,"predicted_n":1,"predicted_ms":20,"predicted_per_second":50}}`,
+	}
+	response, metrics := "", (*Metrics)(nil)
+	for _, chunk := range chunks {
+		resp := ParseResponse(&httpdumper.Response{Body: []byte(chunk)})
+		if resp == nil {
+			t.Fatal("parse chunk failed:", chunk)
+		}
+		response += resp.String()
+		metrics = metrics.Merge(resp.Metrics())
+	}
+	if response != "yes" || metrics == nil || metrics.String() != "prompt 12 tok, completion 1 tok, prompt eval 24ms (500.0 tok/s), eval 20ms (50.0 tok/s)" {
+		t.Fatal(response, metrics)
+	}
+}
+
+func TestLlamaCppAPI(t *testing.T) {
+	cases := []struct {
+		method, path, body string
+		response           string
+		request            string
+		summary            []string
+	}{
+		{"POST", "/tokenize", `{"content":"hello world","with_pieces":true}`,
+			`{"tokens":[{"id":15339,"piece":"hello"},{"id":1917,"piece":" world"}]}`,
+			"tokenize 11 characters", []string{`2 tokens: "hello" " world"`}},
+		{"POST", "/detokenize", `{"tokens":[15339,1917]}`, `{"content":"hello world"}`,
+			"detokenize 2 tokens", []string{"11 characters: hello world"}},
+		{"POST", "/embedding", `{"content":"hello"}`, `[{"index":0,"embedding":[[0.1,0.2,0.3,0.4]]}]`,
+			"embed: 1 inputs, 5 characters", []string{"1 embeddings, 4 dimensions"}},
+		{"GET", "/slots", "", `[{"id":0,"n_ctx":4096,"is_processing":true},{"id":1,"n_ctx":4096,"is_processing":false}]`,
+			"list slots", []string{"2 slots", "  #0 n_ctx 4096, processing", "  #1 n_ctx 4096, idle"}},
+		{"POST", "/slots/0?action=save", `{"filename":"slot0.bin"}`, `{"id_slot":0,"filename":"slot0.bin","n_saved":1745}`,
+			"slot 0 save", []string{"saved 1745 tokens to slot0.bin"}},
+	}
+	for _, c := range cases {
+		raw := c.method + " " + c.path + " HTTP/1.1\r\nHost: localhost:8080\r\n\r\n"
+		httpReq, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httpdumper.NewRequest(httpReq, gopacket.Flow{}, gopacket.Flow{})
+		req.SetBody([]byte(c.body))
+		apiReq := ParseAPIRequest(req)
+		if apiReq == nil || apiReq.Provider() != ProviderLlamaCpp {
+			t.Fatal(c.path, "parse request failed")
+		}
+		if got := apiReq.Summary(); got != c.request {
+			t.Fatalf("%s: got %q, want %q", c.path, got, c.request)
+		}
+		resp := httpdumper.NewResponse(req, &http.Response{StatusCode: 200}, gopacket.Flow{}, gopacket.Flow{})
+		resp.SetBody([]byte(c.response))
+		if got := apiReq.ParseResponse(resp).Summary(); strings.Join(got, "\n") != strings.Join(c.summary, "\n") {
+			t.Fatalf("%s: got %q, want %q", c.path, got, c.summary)
+		}
+	}
+}
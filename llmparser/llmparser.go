@@ -12,7 +12,7 @@ import (
 // IsLLMRequest 判断是否是llm请求
 // 1. 请求头Content-Type不是application/json
 // 2. 请求体中没有model字段
-// 3. 请求url包含/api/chat、/api/generate、/v1/chat/completions、/v1/completions、/api/v0/chat/completions、/api/v0/completions
+// 3. 请求路径以/api/chat、/api/generate、/v1/chat/completions、/v1/completions、/api/v0/chat/completions、/api/v0/completions、/v1/messages、/completion、/infill、/v1/responses结尾
 func IsLLMRequest(req *httpdumper.Request) bool {
 	if !strings.Contains(req.Header.Get("Content-Type"), "application/json") &&
 		!gjson.GetBytes(req.Body, "model").Exists() {
@@ -26,10 +26,15 @@ func IsLLMRequest(req *httpdumper.Request) bool {
 		"/v1/completions",          // openai 兼容的api，ollama/lmstudio
 		"/api/v0/chat/completions", // lmstudio 对话
 		"/api/v0/completions",      // lmstudio 生成
+		"/v1/messages",             // anthropic 兼容的api
+		"/completion",              // llama.cpp 生成
+		"/infill",                  // llama.cpp 代码补全
+		"/v1/responses",            // openai responses api
 	}
-	url := req.URL.String()
+	// 只比较路径的结尾，允许反向代理加前缀，但是/completion_probabilities这样的其他接口不算
+	path := strings.TrimSuffix(req.URL.Path, "/")
 	for _, u := range urls {
-		if strings.Contains(url, u) {
+		if strings.HasSuffix(path, u) {
 			return true
 		}
 	}
@@ -37,9 +42,12 @@ func IsLLMRequest(req *httpdumper.Request) bool {
 }
 
 type LLMMessage struct {
-	Role      string    `json:"role"`
-	Content   string    `json:"content"`
-	ToolCalls []LLMTool `json:"tool_calls"`
+	Role      string        `json:"role"`
+	Content   string        `json:"content"`             // 文本内容，多模态消息为所有文本块
+	Reasoning string        `json:"reasoning,omitempty"` // 思考过程，ollama的thinking，openai兼容api的reasoning_content或者reasoning
+	ToolCalls []LLMTool     `json:"tool_calls"`
+	Images    []string      `json:"images,omitempty"` // ollama的base64图片
+	Parts     []ContentPart `json:"parts,omitempty"`  // 多模态消息的全部内容块，包括解码后的images
 }
 
 // ToolCallsString 将tool_calls转换为字符串用于打印
@@ -52,6 +60,8 @@ func (m *LLMMessage) ToolCallsString() string {
 			if toolCall.Function.Arguments != nil {
 				json, _ := json.Marshal(toolCall.Function.Arguments)
 				args = string(json)
+			} else if toolCall.Function.RawArguments != "" {
+				args = toolCall.Function.RawArguments
 			}
 			if toolCall.Function.Parameters != nil {
 				json, _ := json.Marshal(toolCall.Function.Parameters)
@@ -73,6 +83,8 @@ type LLMTool struct {
 		Description string         `json:"description"`
 		Parameters  map[string]any `json:"parameters"`
 		Arguments   map[string]any `json:"arguments"`
+		// RawArguments 不能解析为对象的arguments原文，比如流式响应中不完整的json片段
+		RawArguments string `json:"raw_arguments,omitempty"`
 	} `json:"function"`
 }
 
@@ -81,12 +93,61 @@ type LLMRequest struct {
 	Model string `json:"model"`
 
 	// generate
-	System string `json:"system"`
-	Prompt string `json:"prompt"`
+	System string   `json:"system"`
+	Prompt string   `json:"prompt"`           // llama.cpp的token数组显示为[N tokens]
+	Images []string `json:"images,omitempty"` // ollama的base64图片
 
-	// chat
+	// llama.cpp /infill
+	InputPrefix string        `json:"input_prefix,omitempty"`
+	InputSuffix string        `json:"input_suffix,omitempty"`
+	InputExtra  []InfillExtra `json:"input_extra,omitempty"`
+
+	// chat，openai responses api的instructions放在System，input转换为Messages
 	Messages []LLMMessage `json:"messages"`
 	Tools    []LLMTool    `json:"tools"`
+
+	GenerationOptions
+}
+
+// UnmarshalJSON anthropic的system可以是字符串，也可以是[{"type":"text","text":"..."}]数组
+// llama.cpp的prompt可以是字符串，也可以是token数组
+// openai responses api使用instructions和input
+func (r *LLMRequest) UnmarshalJSON(data []byte) error {
+	type alias LLMRequest
+	aux := struct {
+		*alias
+		System       json.RawMessage `json:"system"`
+		Prompt       json.RawMessage `json:"prompt"`
+		Instructions string          `json:"instructions"`
+		Input        json.RawMessage `json:"input"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil && !isOptionError(err) {
+		return err
+	}
+	r.GenerationOptions.decodeLenient(gjson.ParseBytes(data))
+	r.System = textContent(gjson.ParseBytes(aux.System))
+	r.Prompt = llamaCppPrompt(gjson.ParseBytes(aux.Prompt))
+	if aux.Instructions != "" {
+		r.System = aux.Instructions
+	}
+	if input := gjson.ParseBytes(aux.Input); input.Exists() {
+		r.Messages = append(r.Messages, responsesInput(input)...)
+	}
+	return nil
+}
+
+// SystemPrompts 请求中所有的系统提示词，包括system字段和role为system的消息
+func (r *LLMRequest) SystemPrompts() []string {
+	system := []string{}
+	if r.System != "" {
+		system = append(system, r.System)
+	}
+	for _, msg := range r.Messages {
+		if msg.Role == "system" && msg.Content != "" {
+			system = append(system, msg.Content)
+		}
+	}
+	return system
 }
 
 // ParseRequest 解析请求
@@ -120,11 +181,14 @@ type LLMResponse struct {
 	// /api/chat
 	// /api/generate
 	// /v1/completions
-	Model     string     `json:"model"`
-	CreatedAt any        `json:"created_at"`
-	Response  string     `json:"response"`
-	Done      bool       `json:"done"`
-	Message   LLMMessage `json:"message"`
+	Model     string          `json:"model"`
+	CreatedAt any             `json:"created_at"`
+	Response  string          `json:"response"`
+	Thinking  string          `json:"thinking"`          // ollama /api/generate的思考过程
+	Content   json.RawMessage `json:"content,omitempty"` // llama.cpp /completion的文本，anthropic是内容块数组
+	Stop      bool            `json:"stop,omitempty"`    // llama.cpp 流式响应的最后一个事件
+	Done      bool            `json:"done"`
+	Message   LLMMessage      `json:"message"`
 	Choices   []struct {
 		Index        int        `json:"index"`
 		FinishReason string     `json:"finish_reason"`
@@ -132,20 +196,79 @@ type LLMResponse struct {
 		Message      LLMMessage `json:"message"`
 		Delta        LLMMessage `json:"delta"`
 	} `json:"choices"`
+
+	// token用量和性能指标，见Metrics
+	OllamaMetrics
+	Usage   *Usage           `json:"usage,omitempty"`   // openai/anthropic
+	Stats   *LMStudioStats   `json:"stats,omitempty"`   // lmstudio /api/v0
+	Timings *LlamaCppTimings `json:"timings,omitempty"` // llama.cpp
+
+	// lmstudio /api/v0，见RuntimeInfo
+	ModelInfo *LMStudioModelInfo `json:"model_info,omitempty"`
+	Runtime   *LMStudioRuntime   `json:"runtime,omitempty"`
+
+	// 错误响应或者流式响应中的错误事件，见ResponseError
+	Error *LLMError `json:"error,omitempty"`
+
+	// 结束原因，见FinishReason
+	DoneReason string `json:"done_reason,omitempty"` // ollama
+	StopReason string `json:"stop_reason,omitempty"` // anthropic
+	StopType   string `json:"stop_type,omitempty"`   // llama.cpp
+
+	// openai responses api和anthropic，见parseResponses和parseAnthropic
+	text         string
+	reasoning    string
+	toolCalls    []LLMTool
+	finishReason string
+}
+
+// UnmarshalJSON ollama的response是字符串，openai responses api的事件中response是对象
+// ollama的message是对象，responses api的error事件中message是字符串
+func (r *LLMResponse) UnmarshalJSON(data []byte) error {
+	type alias LLMResponse
+	aux := struct {
+		*alias
+		Response json.RawMessage `json:"response"`
+		Message  json.RawMessage `json:"message"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if response := gjson.ParseBytes(aux.Response); response.Type == gjson.String {
+		r.Response = response.String()
+	}
+	if message := gjson.ParseBytes(aux.Message); message.IsObject() {
+		if err := json.Unmarshal(aux.Message, &r.Message); err != nil {
+			return err
+		}
+	}
+	typ := gjson.GetBytes(data, "type").String()
+	switch {
+	case r.Object == "response" || strings.HasPrefix(typ, "response."):
+		r.parseResponses(data)
+	case typ == "message" || strings.HasPrefix(typ, "message_") || strings.HasPrefix(typ, "content_block_"):
+		r.parseAnthropic(data)
+	}
+	// openai responses api的error事件：{"type":"error","code":...,"message":...}
+	if msg := gjson.GetBytes(data, "message"); typ == "error" && r.Error == nil && msg.Exists() {
+		r.Error = &LLMError{Message: msg.String(), Code: gjson.GetBytes(data, "code").String()}
+	}
+	return nil
 }
 
-// String 将响应转换为字符串用于打印
+// String 响应中的文本，不包括工具调用，工具调用见ToolCalls
 func (r *LLMResponse) String() string {
 	response := ""
 	if r.Message.Content != "" {
 		response += r.Message.Content
 	}
-	if len(r.Message.ToolCalls) > 0 {
-		response += r.Message.ToolCallsString()
-	}
 	if r.Response != "" {
 		response += r.Response
 	}
+	if content := gjson.ParseBytes(r.Content); content.Type == gjson.String {
+		response += content.String()
+	}
+	response += r.text
 	if len(r.Choices) > 0 {
 		for _, choice := range r.Choices {
 			if choice.Delta.Content != "" {
@@ -157,11 +280,46 @@ func (r *LLMResponse) String() string {
 			if choice.Message.Content != "" {
 				response += choice.Message.Content
 			}
-			if len(choice.Message.ToolCalls) > 0 {
-				response += choice.Message.ToolCallsString()
-			}
 		}
 	}
 
 	return response
 }
+
+// ToolCalls 响应中完整的工具调用，openai和anthropic流式响应中分段的工具调用需要合并，见DecodeResponse
+func (r *LLMResponse) ToolCalls() []LLMTool {
+	toolCalls := append(r.Message.ToolCalls, r.toolCalls...)
+	for _, choice := range r.Choices {
+		toolCalls = append(toolCalls, choice.Message.ToolCalls...)
+	}
+	return toolCalls
+}
+
+// FinishReason 结束原因，比如stop、length、tool_calls、end_turn，没有时返回空
+func (r *LLMResponse) FinishReason() string {
+	for _, choice := range r.Choices {
+		if choice.FinishReason != "" {
+			return choice.FinishReason
+		}
+	}
+	switch {
+	case r.DoneReason != "":
+		return r.DoneReason
+	case r.StopReason != "":
+		return r.StopReason
+	case r.Stop && r.StopType != "":
+		return r.StopType
+	case r.Stats != nil && r.Stats.StopReason != "":
+		return r.Stats.StopReason
+	}
+	return r.finishReason
+}
+
+// Reasoning 响应中显式返回的思考过程，不包括正文中<think>标签包裹的部分，见ExtractReasoning
+func (r *LLMResponse) Reasoning() string {
+	reasoning := r.Thinking + r.Message.Reasoning + r.reasoning
+	for _, choice := range r.Choices {
+		reasoning += choice.Delta.Reasoning + choice.Message.Reasoning
+	}
+	return reasoning
+}
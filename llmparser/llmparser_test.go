@@ -0,0 +1,43 @@
+package llmparser
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+)
+
+func newRequest(t *testing.T, path, body string) *httpdumper.Request {
+	raw := "POST " + path + " HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\n\r\n"
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httpdumper.NewRequest(req, gopacket.Flow{}, gopacket.Flow{})
+	r.SetBody([]byte(body))
+	return r
+}
+
+func TestParseRequestSystem(t *testing.T) {
+	cases := []struct {
+		path, body string
+		system     []string
+	}{
+		{"/api/generate", `{"model":"qwen3","system":"be brief","prompt":"hi"}`, []string{"be brief"}},
+		{"/api/chat", `{"model":"qwen3","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`, []string{"be brief"}},
+		{"/v1/messages", `{"model":"claude","system":[{"type":"text","text":"part one"},{"type":"text","text":"part two","cache_control":{"type":"ephemeral"}}],"messages":[{"role":"user","content":"hi"}]}`, []string{"part one\n\npart two"}},
+		{"/v1/messages", `{"model":"claude","system":"be brief","messages":[{"role":"user","content":"hi"}]}`, []string{"be brief"}},
+	}
+	for _, c := range cases {
+		llmReq := ParseRequest(newRequest(t, c.path, c.body))
+		if llmReq == nil {
+			t.Fatal("parse failed:", c.body)
+		}
+		if got := llmReq.SystemPrompts(); strings.Join(got, "|") != strings.Join(c.system, "|") {
+			t.Fatalf("%s: got %q, want %q", c.body, got, c.system)
+		}
+	}
+}
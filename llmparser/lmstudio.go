@@ -0,0 +1,225 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/tidwall/gjson"
+)
+
+// LMStudioModelInfo lmstudio /api/v0对话和生成响应中的模型信息
+type LMStudioModelInfo struct {
+	Arch          string `json:"arch"`
+	Quant         string `json:"quant"`
+	Format        string `json:"format"`
+	ContextLength int    `json:"context_length"`
+}
+
+// LMStudioRuntime lmstudio /api/v0对话和生成响应中的推理引擎
+type LMStudioRuntime struct {
+	Name             string   `json:"name"`
+	Version          string   `json:"version"`
+	SupportedFormats []string `json:"supported_formats"`
+}
+
+// LMStudioInfo 响应中的模型信息和推理引擎，比如 qwen3 gguf Q4_K_M, context 32768, runtime llama.cpp-mac-arm64 1.3.0
+// 流式响应只有最后一个chunk带有这些信息，没有时返回空
+func LMStudioInfo(resp *httpdumper.Response) string {
+	info := ""
+	for _, line := range strings.Split(string(resp.Body), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "data:"))
+		if !gjson.Get(line, "model_info").Exists() && !gjson.Get(line, "runtime").Exists() {
+			continue
+		}
+		var llmResp LLMResponse
+		if err := json.Unmarshal([]byte(line), &llmResp); err == nil && llmResp.RuntimeInfo() != "" {
+			info = llmResp.RuntimeInfo()
+		}
+	}
+	return info
+}
+
+// RuntimeInfo lmstudio返回的模型信息和推理引擎的摘要
+func (r *LLMResponse) RuntimeInfo() string {
+	var items []string
+	if m := r.ModelInfo; m != nil {
+		var model []string
+		for _, s := range []string{m.Arch, m.Format, m.Quant} {
+			if s != "" {
+				model = append(model, s)
+			}
+		}
+		if len(model) > 0 {
+			items = append(items, strings.Join(model, " "))
+		}
+		if m.ContextLength > 0 {
+			items = append(items, fmt.Sprintf("context %d", m.ContextLength))
+		}
+	}
+	if rt := r.Runtime; rt != nil && rt.Name != "" {
+		items = append(items, strings.TrimSpace("runtime "+rt.Name+" "+rt.Version))
+	}
+	return strings.Join(items, ", ")
+}
+
+// LMStudioRequest lmstudio /api/v0对话和生成以外的接口：向量计算和模型列表
+type LMStudioRequest struct {
+	Endpoint string `json:"-"` // /api/v0/embeddings、/api/v0/models或者/api/v0/models/{model}
+	Model    string `json:"model"`
+	Input    any    `json:"input"` // 字符串或者字符串数组
+}
+
+// ParseLMStudioRequest 解析lmstudio的向量计算和模型列表请求，不是这些接口时返回nil
+func ParseLMStudioRequest(req *httpdumper.Request) *LMStudioRequest {
+	if req.URL == nil {
+		return nil
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	r := &LMStudioRequest{Endpoint: path}
+	switch {
+	case path == "/api/v0/embeddings":
+		if err := json.Unmarshal(req.Body, r); err != nil {
+			return nil
+		}
+	case path == "/api/v0/models":
+	case strings.HasPrefix(path, "/api/v0/models/"):
+		r.Model = strings.TrimPrefix(path, "/api/v0/models/")
+	default:
+		return nil
+	}
+	return r
+}
+
+// Provider 模型服务的类型
+func (r *LMStudioRequest) Provider() Provider {
+	return ProviderLMStudio
+}
+
+// Retrieval 向量计算的输入，其他接口返回nil
+func (r *LMStudioRequest) Retrieval() *Retrieval {
+	if r.Endpoint != "/api/v0/embeddings" {
+		return nil
+	}
+	return &Retrieval{Kind: "embed", Model: r.Model, Inputs: inputStrings(r.Input)}
+}
+
+// Summary 一行摘要
+func (r *LMStudioRequest) Summary() string {
+	switch r.Endpoint {
+	case "/api/v0/embeddings":
+		return r.Retrieval().Summary()
+	case "/api/v0/models":
+		return "list models"
+	}
+	return "show " + r.Model
+}
+
+// LMStudioModel /api/v0/models返回的模型
+type LMStudioModel struct {
+	ID                string `json:"id"`
+	Type              string `json:"type"` // llm、vlm、embeddings
+	Publisher         string `json:"publisher"`
+	Arch              string `json:"arch"`
+	CompatibilityType string `json:"compatibility_type"` // gguf、mlx
+	Quantization      string `json:"quantization"`
+	State             string `json:"state"` // loaded、not-loaded
+	MaxContextLength  int    `json:"max_context_length"`
+}
+
+// String 比如 qwen3-8b llm qwen3 gguf Q4_K_M, context 32768, loaded
+func (m *LMStudioModel) String() string {
+	var items []string
+	for _, s := range []string{m.ID, m.Type, m.Arch, m.CompatibilityType, m.Quantization} {
+		if s != "" {
+			items = append(items, s)
+		}
+	}
+	s := strings.Join(items, " ")
+	if m.MaxContextLength > 0 {
+		s += fmt.Sprintf(", context %d", m.MaxContextLength)
+	}
+	if m.State != "" {
+		s += ", " + m.State
+	}
+	return s
+}
+
+// LMStudioResponse lmstudio向量计算和模型列表的响应
+type LMStudioResponse struct {
+	Endpoint   string          `json:"-"`
+	Error      string          `json:"-"`
+	Embeddings [][]float64     `json:"embeddings,omitempty"`
+	Models     []LMStudioModel `json:"models,omitempty"` // /api/v0/models/{model}时只有一个
+	Usage      *Usage          `json:"usage,omitempty"`
+}
+
+// ParseResponse 解析对应的响应
+func (r *LMStudioRequest) ParseResponse(resp *httpdumper.Response) APIResponse {
+	result := &LMStudioResponse{Endpoint: r.Endpoint}
+	if msg := errorMessage(gjson.ParseBytes(resp.Body)); msg != "" {
+		result.Error = msg
+		return result
+	}
+
+	var err error
+	switch r.Endpoint {
+	case "/api/v0/embeddings":
+		var data struct {
+			Data []struct {
+				Embedding []float64 `json:"embedding"`
+			} `json:"data"`
+			Usage *Usage `json:"usage"`
+		}
+		if err = json.Unmarshal(resp.Body, &data); err == nil {
+			for _, d := range data.Data {
+				result.Embeddings = append(result.Embeddings, d.Embedding)
+			}
+			result.Usage = data.Usage
+		}
+	case "/api/v0/models":
+		var data struct {
+			Data []LMStudioModel `json:"data"`
+		}
+		if err = json.Unmarshal(resp.Body, &data); err == nil {
+			result.Models = data.Data
+		}
+	default:
+		var model LMStudioModel
+		if err = json.Unmarshal(resp.Body, &model); err == nil {
+			result.Models = []LMStudioModel{model}
+		}
+	}
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// Summary 多行摘要，每行一项
+func (r *LMStudioResponse) Summary() []string {
+	if r.Error != "" {
+		return []string{"error: " + r.Error}
+	}
+	if r.Endpoint == "/api/v0/embeddings" {
+		dims := 0
+		if len(r.Embeddings) > 0 {
+			dims = len(r.Embeddings[0])
+		}
+		line := fmt.Sprintf("%d embeddings, %d dimensions", len(r.Embeddings), dims)
+		if r.Usage != nil && r.Usage.PromptTokens > 0 {
+			line += fmt.Sprintf(", prompt %d tok", r.Usage.PromptTokens)
+		}
+		return []string{line}
+	}
+
+	var lines []string
+	if r.Endpoint == "/api/v0/models" {
+		lines = append(lines, fmt.Sprintf("%d models", len(r.Models)))
+	}
+	for _, m := range r.Models {
+		lines = append(lines, "  "+m.String())
+	}
+	return lines
+}
@@ -0,0 +1,68 @@
+package llmparser
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+)
+
+func TestLMStudio(t *testing.T) {
+	chat := newRequest(t, "/api/v0/chat/completions", `{"model":"qwen3-8b","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if DetectProvider(chat) != ProviderLMStudio || ParseRequest(chat) == nil || ParseAPIRequest(chat) != nil {
+		t.Fatal("lmstudio chat should be an llm request")
+	}
+	resp := httpdumper.NewResponse(chat, &http.Response{StatusCode: 200}, gopacket.Flow{}, gopacket.Flow{})
+	resp.SetBody([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\r\n\r\n" +
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":1},` +
+		`"stats":{"tokens_per_second":50,"time_to_first_token":0.1,"generation_time":0.02,"stop_reason":"eosFound"},` +
+		`"model_info":{"arch":"qwen3","quant":"Q4_K_M","format":"gguf","context_length":32768},` +
+		`"runtime":{"name":"llama.cpp-linux-x86_64","version":"1.3.0","supported_formats":["gguf"]}}` + "\n\ndata: [DONE]\n\n"))
+	if got := LMStudioInfo(resp); got != "qwen3 gguf Q4_K_M, context 32768, runtime llama.cpp-linux-x86_64 1.3.0" {
+		t.Fatal(got)
+	}
+
+	cases := []struct {
+		method, path, body string
+		response           string
+		request            string
+		summary            []string
+	}{
+		{"POST", "/api/v0/embeddings", `{"model":"nomic-embed","input":"hello"}`,
+			`{"object":"list","data":[{"object":"embedding","embedding":[0.1,0.2,0.3],"index":0}],"model":"nomic-embed","usage":{"prompt_tokens":2,"total_tokens":2}}`,
+			"embed nomic-embed: 1 inputs, 5 characters", []string{"1 embeddings, 3 dimensions, prompt 2 tok"}},
+		{"GET", "/api/v0/models", "",
+			`{"object":"list","data":[{"id":"qwen3-8b","object":"model","type":"llm","publisher":"qwen","arch":"qwen3","compatibility_type":"gguf","quantization":"Q4_K_M","state":"loaded","max_context_length":32768}]}`,
+			"list models", []string{"1 models", "  qwen3-8b llm qwen3 gguf Q4_K_M, context 32768, loaded"}},
+		{"GET", "/api/v0/models/qwen3-8b", "", `{"error":"model not found"}`,
+			"show qwen3-8b", []string{"error: model not found"}},
+	}
+	for _, c := range cases {
+		raw := c.method + " " + c.path + " HTTP/1.1\r\nHost: localhost\r\n\r\n"
+		httpReq, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httpdumper.NewRequest(httpReq, gopacket.Flow{}, gopacket.Flow{})
+		req.SetBody([]byte(c.body))
+		apiReq := ParseAPIRequest(req)
+		if apiReq == nil || apiReq.Provider() != ProviderLMStudio {
+			t.Fatal(c.path, "parse request failed")
+		}
+		if got := apiReq.Summary(); got != c.request {
+			t.Fatalf("%s: got %q, want %q", c.path, got, c.request)
+		}
+		resp := httpdumper.NewResponse(req, &http.Response{StatusCode: 200}, gopacket.Flow{}, gopacket.Flow{})
+		resp.SetBody([]byte(c.response))
+		apiResp := apiReq.ParseResponse(resp)
+		if apiResp == nil {
+			t.Fatal(c.path, "parse response failed")
+		}
+		if got := apiResp.Summary(); strings.Join(got, "\n") != strings.Join(c.summary, "\n") {
+			t.Fatalf("%s: got %q, want %q", c.path, got, c.summary)
+		}
+	}
+}
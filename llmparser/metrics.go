@@ -0,0 +1,205 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Usage openai和anthropic兼容api返回的token用量
+type Usage struct {
+	// openai
+	PromptTokens            int `json:"prompt_tokens,omitempty"`
+	CompletionTokens        int `json:"completion_tokens,omitempty"`
+	TotalTokens             int `json:"total_tokens,omitempty"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+	} `json:"completion_tokens_details,omitempty"`
+
+	// anthropic和openai responses api
+	InputTokens         int `json:"input_tokens,omitempty"`
+	OutputTokens        int `json:"output_tokens,omitempty"`
+	OutputTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+	} `json:"output_tokens_details,omitempty"`
+}
+
+// LMStudioStats lmstudio /api/v0返回的统计信息，时间单位为秒
+type LMStudioStats struct {
+	TokensPerSecond  float64 `json:"tokens_per_second"`
+	TimeToFirstToken float64 `json:"time_to_first_token"`
+	GenerationTime   float64 `json:"generation_time"`
+	StopReason       string  `json:"stop_reason"`
+}
+
+// OllamaMetrics ollama最后一个响应中的统计信息，时间单位为纳秒
+type OllamaMetrics struct {
+	TotalDuration      int64 `json:"total_duration,omitempty"`
+	LoadDuration       int64 `json:"load_duration,omitempty"`
+	PromptEvalCount    int   `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int   `json:"eval_count,omitempty"`
+	EvalDuration       int64 `json:"eval_duration,omitempty"`
+}
+
+// Metrics 统一后的token用量和性能指标，没有返回的字段为0
+type Metrics struct {
+	PromptTokens     int           `json:"prompt_tokens,omitempty"`
+	CompletionTokens int           `json:"completion_tokens,omitempty"`
+	ReasoningTokens  int           `json:"reasoning_tokens,omitempty"`
+	LoadDuration     time.Duration `json:"load_duration,omitempty"`
+	PromptDuration   time.Duration `json:"prompt_duration,omitempty"` // prompt处理耗时
+	EvalDuration     time.Duration `json:"eval_duration,omitempty"`   // 生成耗时
+	TotalDuration    time.Duration `json:"total_duration,omitempty"`
+	TimeToFirstToken time.Duration `json:"time_to_first_token,omitempty"`
+	TokensPerSecond  float64       `json:"tokens_per_second,omitempty"` // 生成速度
+	Estimated        bool          `json:"estimated,omitempty"`         // token数是本地估计的，后端没有返回
+}
+
+// Metrics 响应中的token用量和性能指标，没有时返回nil
+func (r *LLMResponse) Metrics() *Metrics {
+	m := &Metrics{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		LoadDuration:     time.Duration(r.LoadDuration),
+		PromptDuration:   time.Duration(r.PromptEvalDuration),
+		EvalDuration:     time.Duration(r.EvalDuration),
+		TotalDuration:    time.Duration(r.TotalDuration),
+	}
+	if u := r.Usage; u != nil {
+		m.PromptTokens = max(m.PromptTokens, u.PromptTokens, u.InputTokens)
+		m.CompletionTokens = max(m.CompletionTokens, u.CompletionTokens, u.OutputTokens)
+		if u.CompletionTokensDetails != nil {
+			m.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
+		}
+		if u.OutputTokensDetails != nil {
+			m.ReasoningTokens = max(m.ReasoningTokens, u.OutputTokensDetails.ReasoningTokens)
+		}
+	}
+	if s := r.Stats; s != nil {
+		m.TokensPerSecond = s.TokensPerSecond
+		m.TimeToFirstToken = seconds(s.TimeToFirstToken)
+		m.EvalDuration = max(m.EvalDuration, seconds(s.GenerationTime))
+	}
+	if t := r.Timings; t != nil {
+		m = m.Merge(t.metrics())
+	}
+	if *m == (Metrics{}) {
+		return nil
+	}
+	return m
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Merge 合并流式响应中多个chunk的指标，非0的字段覆盖，m为nil时返回other
+func (m *Metrics) Merge(other *Metrics) *Metrics {
+	if m == nil {
+		return other
+	}
+	if other == nil {
+		return m
+	}
+	merged := *m
+	for _, f := range []struct{ dst, src *int }{
+		{&merged.PromptTokens, &other.PromptTokens},
+		{&merged.CompletionTokens, &other.CompletionTokens},
+		{&merged.ReasoningTokens, &other.ReasoningTokens},
+	} {
+		if *f.src != 0 {
+			*f.dst = *f.src
+		}
+	}
+	for _, f := range []struct{ dst, src *time.Duration }{
+		{&merged.LoadDuration, &other.LoadDuration},
+		{&merged.PromptDuration, &other.PromptDuration},
+		{&merged.EvalDuration, &other.EvalDuration},
+		{&merged.TotalDuration, &other.TotalDuration},
+		{&merged.TimeToFirstToken, &other.TimeToFirstToken},
+	} {
+		if *f.src != 0 {
+			*f.dst = *f.src
+		}
+	}
+	if other.TokensPerSecond != 0 {
+		merged.TokensPerSecond = other.TokensPerSecond
+	}
+	merged.Estimated = merged.Estimated || other.Estimated
+	return &merged
+}
+
+// Speed 生成速度，后端没有返回时根据生成的token数和耗时计算
+func (m *Metrics) Speed() float64 {
+	if m.TokensPerSecond > 0 {
+		return m.TokensPerSecond
+	}
+	if m.CompletionTokens > 0 && m.EvalDuration > 0 {
+		return float64(m.CompletionTokens) / m.EvalDuration.Seconds()
+	}
+	return 0
+}
+
+// String 紧凑的一行显示，如 prompt 1200 tok, completion 300 tok, load 1.2s, prompt eval 350ms (3428.6 tok/s), eval 6.4s (46.9 tok/s), total 8s
+func (m *Metrics) String() string {
+	var items []string
+	approx := ""
+	if m.Estimated {
+		approx = "~"
+	}
+	if m.PromptTokens > 0 {
+		items = append(items, fmt.Sprintf("prompt %s%d tok", approx, m.PromptTokens))
+	}
+	if m.CompletionTokens > 0 {
+		item := fmt.Sprintf("completion %s%d tok", approx, m.CompletionTokens)
+		if m.ReasoningTokens > 0 {
+			item += fmt.Sprintf(" (reasoning %d)", m.ReasoningTokens)
+		}
+		items = append(items, item)
+	}
+	if m.LoadDuration > 0 {
+		items = append(items, "load "+roundDuration(m.LoadDuration))
+	}
+	if m.PromptDuration > 0 {
+		item := "prompt eval " + roundDuration(m.PromptDuration)
+		if m.PromptTokens > 0 {
+			item += fmt.Sprintf(" (%.1f tok/s)", float64(m.PromptTokens)/m.PromptDuration.Seconds())
+		}
+		items = append(items, item)
+	}
+	if m.TimeToFirstToken > 0 {
+		items = append(items, "ttft "+roundDuration(m.TimeToFirstToken))
+	}
+	if speed := m.Speed(); m.EvalDuration > 0 || speed > 0 {
+		item := "eval"
+		if m.EvalDuration > 0 {
+			item += " " + roundDuration(m.EvalDuration)
+		}
+		if speed > 0 {
+			item += fmt.Sprintf(" (%.1f tok/s)", speed)
+		}
+		items = append(items, item)
+	}
+	if m.TotalDuration > 0 {
+		items = append(items, "total "+roundDuration(m.TotalDuration))
+	}
+	return strings.Join(items, ", ")
+}
+
+func roundDuration(d time.Duration) string {
+	if d >= time.Second {
+		return d.Round(10 * time.Millisecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// JSON 用于保存的json，m为nil时返回空字符串
+func (m *Metrics) JSON() string {
+	if m == nil {
+		return ""
+	}
+	data, _ := json.Marshal(m)
+	return string(data)
+}
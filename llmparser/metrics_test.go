@@ -0,0 +1,55 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	cases := []struct {
+		name   string
+		chunks []string
+		want   Metrics
+		str    string
+	}{
+		{"ollama", []string{
+			`{"model":"qwen3","message":{"role":"assistant","content":"Hi"},"done":false}`,
+			`{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"total_duration":2500000000,"load_duration":500000000,"prompt_eval_count":100,"prompt_eval_duration":200000000,"eval_count":50,"eval_duration":1000000000}`,
+		}, Metrics{PromptTokens: 100, CompletionTokens: 50, LoadDuration: 500 * time.Millisecond, PromptDuration: 200 * time.Millisecond, EvalDuration: time.Second, TotalDuration: 2500 * time.Millisecond},
+			"prompt 100 tok, completion 50 tok, load 500ms, prompt eval 200ms (500.0 tok/s), eval 1s (50.0 tok/s), total 2.5s"},
+		{"openai stream", []string{
+			`{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":20,"completion_tokens":8,"total_tokens":28,"completion_tokens_details":{"reasoning_tokens":5}}}`,
+		}, Metrics{PromptTokens: 20, CompletionTokens: 8, ReasoningTokens: 5}, "prompt 20 tok, completion 8 tok (reasoning 5)"},
+		{"lmstudio", []string{
+			`{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"}}],"usage":{"prompt_tokens":20,"completion_tokens":8},"stats":{"tokens_per_second":42.5,"time_to_first_token":0.25,"generation_time":0.2}}`,
+		}, Metrics{PromptTokens: 20, CompletionTokens: 8, EvalDuration: 200 * time.Millisecond, TimeToFirstToken: 250 * time.Millisecond, TokensPerSecond: 42.5},
+			"prompt 20 tok, completion 8 tok, ttft 250ms, eval 200ms (42.5 tok/s)"},
+		{"anthropic", []string{
+			`{"id":"msg_1","usage":{"input_tokens":30,"output_tokens":4}}`,
+		}, Metrics{PromptTokens: 30, CompletionTokens: 4}, "prompt 30 tok, completion 4 tok"},
+	}
+	for _, c := range cases {
+		var metrics *Metrics
+		for _, chunk := range c.chunks {
+			var resp LLMResponse
+			if err := json.Unmarshal([]byte(chunk), &resp); err != nil {
+				t.Fatal(c.name, err)
+			}
+			metrics = metrics.Merge(resp.Metrics())
+		}
+		if metrics == nil || *metrics != c.want {
+			t.Fatalf("%s: got %+v, want %+v", c.name, metrics, c.want)
+		}
+		if metrics.String() != c.str {
+			t.Fatalf("%s: got %q, want %q", c.name, metrics.String(), c.str)
+		}
+	}
+
+	var resp LLMResponse
+	json.Unmarshal([]byte(`{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`), &resp)
+	if resp.Metrics() != nil {
+		t.Fatal("chunk without usage should have no metrics")
+	}
+}
@@ -0,0 +1,389 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+)
+
+// ollama的管理接口，不是对话和生成，但是能看到agent加载模型、计算向量等操作
+var ollamaEndpoints = map[string]bool{
+	"/api/embed":      true, // 批量计算向量
+	"/api/embeddings": true, // 旧版的单个向量
+	"/api/show":       true, // 模型详情
+	"/api/tags":       true, // 本地模型列表
+	"/api/ps":         true, // 已加载的模型
+	"/api/pull":       true, // 下载模型，流式返回进度
+	"/api/create":     true, // 创建模型，流式返回进度
+	"/api/copy":       true, // 复制模型
+}
+
+// OllamaEndpoint 请求是ollama管理接口时返回路径，比如/api/embed，否则返回空
+func OllamaEndpoint(req *httpdumper.Request) string {
+	if req.URL == nil {
+		return ""
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if ollamaEndpoints[path] {
+		return path
+	}
+	return ""
+}
+
+// OllamaRequest ollama管理接口的请求，/api/tags和/api/ps是没有body的GET请求
+type OllamaRequest struct {
+	Endpoint string `json:"-"`
+
+	Model string `json:"model"`
+	Name  string `json:"name"` // 旧版本的show、pull和create使用name
+
+	// embed/embeddings
+	Input      any    `json:"input"`  // 字符串或者字符串数组
+	Prompt     string `json:"prompt"` // /api/embeddings
+	Truncate   *bool  `json:"truncate,omitempty"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	KeepAlive  any    `json:"keep_alive,omitempty"`
+
+	// pull/create
+	Insecure bool              `json:"insecure,omitempty"`
+	Stream   *bool             `json:"stream,omitempty"`
+	From     string            `json:"from,omitempty"`
+	Files    map[string]string `json:"files,omitempty"`
+	Quantize string            `json:"quantize,omitempty"`
+	System   string            `json:"system,omitempty"`
+
+	// copy
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+
+	// show
+	Verbose bool `json:"verbose,omitempty"`
+}
+
+// ParseOllamaRequest 解析ollama管理接口的请求，不是管理接口时返回nil
+func ParseOllamaRequest(req *httpdumper.Request) *OllamaRequest {
+	endpoint := OllamaEndpoint(req)
+	if endpoint == "" {
+		return nil
+	}
+	r := &OllamaRequest{Endpoint: endpoint}
+	if len(req.Body) > 0 {
+		if err := json.Unmarshal(req.Body, r); err != nil {
+			return nil
+		}
+	}
+	return r
+}
+
+// Provider 模型服务的类型
+func (r *OllamaRequest) Provider() Provider {
+	return ProviderOllama
+}
+
+// ParseResponse 解析对应的响应
+func (r *OllamaRequest) ParseResponse(resp *httpdumper.Response) APIResponse {
+	if ollamaResp := ParseOllamaResponse(r.Endpoint, resp); ollamaResp != nil {
+		return ollamaResp
+	}
+	return nil
+}
+
+// ModelName 请求的模型，兼容旧版本的name字段
+func (r *OllamaRequest) ModelName() string {
+	if r.Model != "" {
+		return r.Model
+	}
+	return r.Name
+}
+
+// Inputs 需要计算向量的文本
+func (r *OllamaRequest) Inputs() []string {
+	if inputs := inputStrings(r.Input); len(inputs) > 0 {
+		return inputs
+	}
+	if r.Prompt != "" {
+		return []string{r.Prompt}
+	}
+	return nil
+}
+
+// Retrieval 向量计算的输入，其他接口返回nil
+func (r *OllamaRequest) Retrieval() *Retrieval {
+	if r.Endpoint != "/api/embed" && r.Endpoint != "/api/embeddings" {
+		return nil
+	}
+	return &Retrieval{Kind: "embed", Model: r.ModelName(), Inputs: r.Inputs()}
+}
+
+// inputStrings 向量接口的input，可以是字符串或者字符串数组
+func inputStrings(input any) []string {
+	switch v := input.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var inputs []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				inputs = append(inputs, s)
+			}
+		}
+		return inputs
+	}
+	return nil
+}
+
+// Summary 一行摘要，比如 embed nomic-embed-text: 3 inputs, 1200 characters
+func (r *OllamaRequest) Summary() string {
+	switch r.Endpoint {
+	case "/api/embed", "/api/embeddings":
+		summary := r.Retrieval().Summary()
+		if r.Dimensions > 0 {
+			summary += fmt.Sprintf(", dimensions=%d", r.Dimensions)
+		}
+		if r.KeepAlive != nil {
+			summary += fmt.Sprintf(", keep_alive=%v", r.KeepAlive)
+		}
+		return summary
+	case "/api/show":
+		return "show " + r.ModelName()
+	case "/api/tags":
+		return "list local models"
+	case "/api/ps":
+		return "list running models"
+	case "/api/pull":
+		summary := "pull " + r.ModelName()
+		if r.Insecure {
+			summary += " (insecure)"
+		}
+		return summary
+	case "/api/create":
+		summary := "create " + r.ModelName()
+		if r.From != "" {
+			summary += " from " + r.From
+		}
+		if len(r.Files) > 0 {
+			summary += fmt.Sprintf(" with %d files", len(r.Files))
+		}
+		if r.Quantize != "" {
+			summary += ", quantize=" + r.Quantize
+		}
+		return summary
+	case "/api/copy":
+		return fmt.Sprintf("copy %s -> %s", r.Source, r.Destination)
+	}
+	return r.Endpoint
+}
+
+// OllamaModelDetails 模型的基本信息
+type OllamaModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+// String 比如 qwen3 8.2B Q4_K_M
+func (d *OllamaModelDetails) String() string {
+	var items []string
+	for _, s := range []string{d.Family, d.ParameterSize, d.QuantizationLevel} {
+		if s != "" {
+			items = append(items, s)
+		}
+	}
+	return strings.Join(items, " ")
+}
+
+// OllamaModel /api/tags和/api/ps返回的模型
+type OllamaModel struct {
+	Name          string             `json:"name"`
+	Model         string             `json:"model"`
+	Size          int64              `json:"size"`
+	Digest        string             `json:"digest"`
+	ModifiedAt    string             `json:"modified_at,omitempty"`
+	Details       OllamaModelDetails `json:"details"`
+	SizeVRAM      int64              `json:"size_vram,omitempty"`      // ps
+	ExpiresAt     string             `json:"expires_at,omitempty"`     // ps
+	ContextLength int                `json:"context_length,omitempty"` // ps
+}
+
+// OllamaProgress /api/pull和/api/create流式返回的进度
+type OllamaProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// OllamaResponse ollama管理接口的响应，只有对应接口的字段有值
+type OllamaResponse struct {
+	Endpoint string `json:"-"`
+	Error    string `json:"error,omitempty"`
+
+	// embed/embeddings
+	Embeddings [][]float64 `json:"embeddings,omitempty"`
+	Embedding  []float64   `json:"embedding,omitempty"` // /api/embeddings
+	OllamaMetrics
+
+	// show
+	Parameters   string              `json:"parameters,omitempty"`
+	Template     string              `json:"template,omitempty"`
+	Details      *OllamaModelDetails `json:"details,omitempty"`
+	ModelInfo    map[string]any      `json:"model_info,omitempty"`
+	Capabilities []string            `json:"capabilities,omitempty"`
+
+	// tags/ps
+	Models []OllamaModel `json:"models,omitempty"`
+
+	// pull/create，按顺序去重的状态和每一层的最终进度
+	Statuses []string         `json:"statuses,omitempty"`
+	Layers   []OllamaProgress `json:"layers,omitempty"`
+}
+
+// ParseOllamaResponse 解析ollama管理接口的响应，pull和create可能是ndjson流
+func ParseOllamaResponse(endpoint string, resp *httpdumper.Response) *OllamaResponse {
+	r := &OllamaResponse{Endpoint: endpoint}
+	body := strings.TrimSpace(string(resp.Body))
+	if body == "" {
+		return r
+	}
+
+	switch endpoint {
+	case "/api/pull", "/api/create":
+		layers := make(map[string]int) // digest -> Layers的下标
+		for _, line := range strings.Split(body, "\n") {
+			var p struct {
+				OllamaProgress
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(line), &p); err != nil {
+				continue
+			}
+			if p.Error != "" {
+				r.Error = p.Error
+				continue
+			}
+			if p.Digest != "" {
+				if i, ok := layers[p.Digest]; ok {
+					r.Layers[i] = p.OllamaProgress
+				} else {
+					layers[p.Digest] = len(r.Layers)
+					r.Layers = append(r.Layers, p.OllamaProgress)
+				}
+				// 每一层的状态都是pulling <digest>，只在Layers中体现
+				continue
+			}
+			if p.Status != "" && (len(r.Statuses) == 0 || r.Statuses[len(r.Statuses)-1] != p.Status) {
+				r.Statuses = append(r.Statuses, p.Status)
+			}
+		}
+	default:
+		if err := json.Unmarshal([]byte(body), r); err != nil {
+			return nil
+		}
+	}
+	return r
+}
+
+// ContextLength /api/show的model_info中的上下文长度，比如qwen3.context_length
+func (r *OllamaResponse) ContextLength() int {
+	for key, v := range r.ModelInfo {
+		if strings.HasSuffix(key, ".context_length") {
+			if n, ok := v.(float64); ok {
+				return int(n)
+			}
+		}
+	}
+	return 0
+}
+
+// Summary 多行摘要，每行一项
+func (r *OllamaResponse) Summary() []string {
+	if r.Error != "" {
+		return []string{"error: " + r.Error}
+	}
+
+	var lines []string
+	switch r.Endpoint {
+	case "/api/embed", "/api/embeddings":
+		vectors := r.Embeddings
+		if len(r.Embedding) > 0 {
+			vectors = [][]float64{r.Embedding}
+		}
+		dims := 0
+		if len(vectors) > 0 {
+			dims = len(vectors[0])
+		}
+		line := fmt.Sprintf("%d embeddings, %d dimensions", len(vectors), dims)
+		if m := (&LLMResponse{OllamaMetrics: r.OllamaMetrics}).Metrics(); m != nil {
+			line += ", " + m.String()
+		}
+		lines = append(lines, line)
+	case "/api/show":
+		if r.Details != nil {
+			if details := r.Details.String(); details != "" {
+				lines = append(lines, "details: "+details)
+			}
+		}
+		if n := r.ContextLength(); n > 0 {
+			lines = append(lines, fmt.Sprintf("context length: %d", n))
+		}
+		if len(r.Capabilities) > 0 {
+			lines = append(lines, "capabilities: "+strings.Join(r.Capabilities, ", "))
+		}
+		if r.Parameters != "" {
+			params := strings.Fields(strings.ReplaceAll(r.Parameters, "\n", " "))
+			lines = append(lines, "parameters: "+strings.Join(params, " "))
+		}
+	case "/api/tags":
+		lines = append(lines, fmt.Sprintf("%d models", len(r.Models)))
+		for _, m := range r.Models {
+			lines = append(lines, fmt.Sprintf("  %s %s %s", m.Name, formatBytes(m.Size), m.Details.String()))
+		}
+	case "/api/ps":
+		lines = append(lines, fmt.Sprintf("%d running models", len(r.Models)))
+		for _, m := range r.Models {
+			line := fmt.Sprintf("  %s %s (vram %s)", m.Name, formatBytes(m.Size), formatBytes(m.SizeVRAM))
+			if m.ContextLength > 0 {
+				line += fmt.Sprintf(", context %d", m.ContextLength)
+			}
+			if expires, err := time.Parse(time.RFC3339Nano, m.ExpiresAt); err == nil {
+				line += ", expires " + expires.Format(time.DateTime)
+			}
+			lines = append(lines, line)
+		}
+	case "/api/pull", "/api/create":
+		var total, completed int64
+		for _, layer := range r.Layers {
+			total += layer.Total
+			completed += layer.Completed
+		}
+		if len(r.Layers) > 0 {
+			lines = append(lines, fmt.Sprintf("%d layers, %s / %s", len(r.Layers), formatBytes(completed), formatBytes(total)))
+		}
+		if len(r.Statuses) > 0 {
+			lines = append(lines, "status: "+strings.Join(r.Statuses, " -> "))
+		}
+	case "/api/copy":
+		lines = append(lines, "copied")
+	}
+	return lines
+}
+
+// formatBytes 比如 4.7GB
+func formatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	size := float64(n)
+	i := 0
+	for size >= 1000 && i < len(units)-1 {
+		size /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.1f%s", size, units[i])
+}
@@ -0,0 +1,81 @@
+package llmparser
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+)
+
+func TestOllamaManagement(t *testing.T) {
+	cases := []struct {
+		method, path, body string
+		response           string
+		request            string
+		summary            []string
+	}{
+		{"POST", "/api/embed", `{"model":"nomic-embed-text","input":["hello","world!"]}`,
+			`{"model":"nomic-embed-text","embeddings":[[0.1,0.2,0.3],[0.4,0.5,0.6]],"total_duration":20000000,"prompt_eval_count":4}`,
+			"embed nomic-embed-text: 2 inputs, 11 characters",
+			[]string{"2 embeddings, 3 dimensions, prompt 4 tok, total 20ms"}},
+		{"POST", "/api/embeddings", `{"model":"all-minilm","prompt":"hi"}`, `{"embedding":[0.1,0.2]}`,
+			"embed all-minilm: 1 inputs, 2 characters", []string{"1 embeddings, 2 dimensions"}},
+		{"POST", "/api/show", `{"model":"qwen3"}`,
+			`{"parameters":"temperature 0.6\nstop \"<|im_end|>\"","details":{"family":"qwen3","parameter_size":"8.2B","quantization_level":"Q4_K_M"},"model_info":{"qwen3.context_length":40960},"capabilities":["completion","tools"]}`,
+			"show qwen3",
+			[]string{"details: qwen3 8.2B Q4_K_M", "context length: 40960", "capabilities: completion, tools", `parameters: temperature 0.6 stop "<|im_end|>"`}},
+		{"GET", "/api/tags", "", `{"models":[{"name":"qwen3:8b","size":5200000000,"details":{"family":"qwen3","parameter_size":"8.2B"}}]}`,
+			"list local models", []string{"1 models", "  qwen3:8b 5.2GB qwen3 8.2B"}},
+		{"GET", "/api/ps", "", `{"models":[{"name":"qwen3:8b","size":6000000000,"size_vram":6000000000,"context_length":4096}]}`,
+			"list running models", []string{"1 running models", "  qwen3:8b 6.0GB (vram 6.0GB), context 4096"}},
+		{"POST", "/api/pull", `{"model":"qwen3:8b"}`, `{"status":"pulling manifest"}
+{"status":"pulling aaa","digest":"sha256:aaa","total":1000,"completed":10}
+{"status":"pulling aaa","digest":"sha256:aaa","total":1000,"completed":1000}
+{"status":"pulling bbb","digest":"sha256:bbb","total":500,"completed":500}
+{"status":"verifying sha256 digest"}
+{"status":"writing manifest"}
+{"status":"success"}`,
+			"pull qwen3:8b", []string{"2 layers, 1.5KB / 1.5KB", "status: pulling manifest -> verifying sha256 digest -> writing manifest -> success"}},
+		{"POST", "/api/create", `{"model":"mario","from":"qwen3","system":"You are Mario"}`, `{"status":"reading model metadata"}
+{"error":"model not found"}`,
+			"create mario from qwen3", []string{"error: model not found"}},
+		{"POST", "/api/copy", `{"source":"qwen3","destination":"qwen3-backup"}`, "",
+			"copy qwen3 -> qwen3-backup", []string{"copied"}},
+	}
+	for _, c := range cases {
+		raw := c.method + " " + c.path + " HTTP/1.1\r\nHost: localhost\r\n\r\n"
+		httpReq, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httpdumper.NewRequest(httpReq, gopacket.Flow{}, gopacket.Flow{})
+		req.SetBody([]byte(c.body))
+		if IsLLMRequest(req) {
+			t.Fatal(c.path, "should not be an llm request")
+		}
+		ollamaReq := ParseOllamaRequest(req)
+		if ollamaReq == nil {
+			t.Fatal(c.path, "parse request failed")
+		}
+		if got := ollamaReq.Summary(); got != c.request {
+			t.Fatalf("%s: got %q, want %q", c.path, got, c.request)
+		}
+
+		resp := httpdumper.NewResponse(req, &http.Response{StatusCode: 200}, gopacket.Flow{}, gopacket.Flow{})
+		resp.SetBody([]byte(c.response))
+		ollamaResp := ParseOllamaResponse(ollamaReq.Endpoint, resp)
+		if ollamaResp == nil {
+			t.Fatal(c.path, "parse response failed")
+		}
+		if got := ollamaResp.Summary(); strings.Join(got, "\n") != strings.Join(c.summary, "\n") {
+			t.Fatalf("%s: got %q, want %q", c.path, got, c.summary)
+		}
+	}
+
+	if ParseOllamaRequest(newRequest(t, "/api/chat", `{"model":"qwen3"}`)) != nil {
+		t.Fatal("/api/chat is not a management endpoint")
+	}
+}
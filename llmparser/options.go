@@ -0,0 +1,337 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// GenerationOptions 采样和输出格式参数
+type GenerationOptions struct {
+	Stream *bool `json:"stream,omitempty"`
+
+	// ollama
+	Options   map[string]any  `json:"options,omitempty"`    // temperature、num_ctx、top_p、seed、stop等
+	Format    json.RawMessage `json:"format,omitempty"`     // "json"或者json schema
+	KeepAlive any             `json:"keep_alive,omitempty"` // "5m"或者秒数
+	Think     any             `json:"think,omitempty"`      // true/false或者"low"/"medium"/"high"
+
+	// openai
+	Temperature         *float64        `json:"temperature,omitempty"`
+	TopP                *float64        `json:"top_p,omitempty"`
+	MaxTokens           *int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"`
+	Seed                *int            `json:"seed,omitempty"`
+	Stop                any             `json:"stop,omitempty"`
+	ResponseFormat      json.RawMessage `json:"response_format,omitempty"`
+	ToolChoice          json.RawMessage `json:"tool_choice,omitempty"`
+	StreamOptions       map[string]any  `json:"stream_options,omitempty"`
+
+	// openai responses api
+	MaxOutput          *int            `json:"max_output_tokens,omitempty"`
+	ReasoningOptions   map[string]any  `json:"reasoning,omitempty"` // {"effort":"high","summary":"auto"}
+	Text               json.RawMessage `json:"text,omitempty"`      // {"format":{"type":"json_schema",...}}
+	PreviousResponseID string          `json:"previous_response_id,omitempty"`
+	Store              *bool           `json:"store,omitempty"`
+
+	// llama.cpp
+	NPredict    *int            `json:"n_predict,omitempty"`
+	TopK        *int            `json:"top_k,omitempty"`
+	Grammar     string          `json:"grammar,omitempty"` // GBNF语法
+	JSONSchema  json.RawMessage `json:"json_schema,omitempty"`
+	CachePrompt *bool           `json:"cache_prompt,omitempty"`
+	IDSlot      *int            `json:"id_slot,omitempty"`
+}
+
+// optionFields GenerationOptions中所有参数的json名
+var optionFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(GenerationOptions{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = true
+	}
+	return fields
+}()
+
+// isOptionError 是否是参数字段的类型错误，参数的类型不对不能影响消息的解析
+func isOptionError(err error) bool {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		return false
+	}
+	name, _, _ := strings.Cut(typeErr.Field, ".")
+	return optionFields[name]
+}
+
+// lenientFloat 宽松读取数字，兼容 "0.7" 这样的字符串，不是数字时返回nil
+func lenientFloat(v gjson.Result) *float64 {
+	switch v.Type {
+	case gjson.Number:
+		f := v.Float()
+		return &f
+	case gjson.String:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v.Str), 64); err == nil {
+			return &f
+		}
+	}
+	return nil
+}
+
+// lenientInt 宽松读取整数，兼容 1e4、1024.0 和 "1024"
+func lenientInt(v gjson.Result) *int {
+	if f := lenientFloat(v); f != nil {
+		n := int(*f)
+		return &n
+	}
+	return nil
+}
+
+// lenientBool 宽松读取布尔值，兼容 "true"
+func lenientBool(v gjson.Result) *bool {
+	switch v.Type {
+	case gjson.True, gjson.False:
+		b := v.Bool()
+		return &b
+	case gjson.String:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v.Str)); err == nil {
+			return &b
+		}
+	}
+	return nil
+}
+
+// decodeLenient 重新宽松解析数字和布尔参数，客户端发送的类型不一定和文档一致
+func (o *GenerationOptions) decodeLenient(data gjson.Result) {
+	o.Stream = lenientBool(data.Get("stream"))
+	o.Temperature = lenientFloat(data.Get("temperature"))
+	o.TopP = lenientFloat(data.Get("top_p"))
+	o.MaxTokens = lenientInt(data.Get("max_tokens"))
+	o.MaxCompletionTokens = lenientInt(data.Get("max_completion_tokens"))
+	o.Seed = lenientInt(data.Get("seed"))
+	o.MaxOutput = lenientInt(data.Get("max_output_tokens"))
+	o.Store = lenientBool(data.Get("store"))
+	o.NPredict = lenientInt(data.Get("n_predict"))
+	o.TopK = lenientInt(data.Get("top_k"))
+	o.CachePrompt = lenientBool(data.Get("cache_prompt"))
+	o.IDSlot = lenientInt(data.Get("id_slot"))
+}
+
+// optionNumber 读取ollama options中的数字参数，兼容字符串
+func (o *GenerationOptions) optionNumber(name string) (float64, bool) {
+	switch v := o.Options[name].(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// optionInt 读取ollama options中的整数参数
+func (o *GenerationOptions) optionInt(name string) (int, bool) {
+	v, ok := o.optionNumber(name)
+	return int(v), ok
+}
+
+// NumCtx ollama的上下文长度，没有设置时返回0
+func (o *GenerationOptions) NumCtx() int {
+	n, _ := o.optionInt("num_ctx")
+	return n
+}
+
+// MaxOutputTokens 最大输出token数，没有设置时返回0
+func (o *GenerationOptions) MaxOutputTokens() int {
+	switch {
+	case o.MaxCompletionTokens != nil:
+		return *o.MaxCompletionTokens
+	case o.MaxOutput != nil:
+		return *o.MaxOutput
+	case o.MaxTokens != nil:
+		return *o.MaxTokens
+	}
+	if n, ok := o.optionInt("num_predict"); ok && n > 0 {
+		return n
+	}
+	if o.NPredict != nil && *o.NPredict > 0 {
+		return *o.NPredict
+	}
+	return 0
+}
+
+// TemperatureValue 温度，没有设置时ok为false
+func (o *GenerationOptions) TemperatureValue() (float64, bool) {
+	if o.Temperature != nil {
+		return *o.Temperature, true
+	}
+	return o.optionNumber("temperature")
+}
+
+// OutputSchema 结构化输出的json schema，ollama的format或者openai的response_format.json_schema.schema
+func (o *GenerationOptions) OutputSchema() map[string]any {
+	var schema gjson.Result
+	if format := gjson.ParseBytes(o.Format); format.IsObject() {
+		schema = format
+	} else if rf := gjson.ParseBytes(o.ResponseFormat); rf.Get("type").String() == "json_schema" {
+		schema = rf.Get("json_schema.schema")
+	} else if tf := gjson.GetBytes(o.Text, "format"); tf.Get("type").String() == "json_schema" {
+		schema = tf.Get("schema")
+	} else if js := gjson.ParseBytes(o.JSONSchema); js.IsObject() {
+		schema = js
+	}
+	if !schema.IsObject() {
+		return nil
+	}
+	m, _ := schema.Value().(map[string]any)
+	return m
+}
+
+// OutputFormat 输出格式的简短描述，如 json、json_schema:weather，没有设置时为空
+func (o *GenerationOptions) OutputFormat() string {
+	if format := gjson.ParseBytes(o.Format); format.Exists() {
+		if format.IsObject() {
+			return "json_schema"
+		}
+		return format.String()
+	}
+	if len(o.JSONSchema) > 0 {
+		return "json_schema"
+	}
+	if o.Grammar != "" {
+		return "gbnf"
+	}
+	if tf := gjson.GetBytes(o.Text, "format"); tf.Exists() {
+		// responses api的text.format，name和schema在同一层
+		typ := tf.Get("type").String()
+		if name := tf.Get("name").String(); typ == "json_schema" && name != "" {
+			return typ + ":" + name
+		}
+		return typ
+	}
+	rf := gjson.ParseBytes(o.ResponseFormat)
+	typ := rf.Get("type").String()
+	if name := rf.Get("json_schema.name").String(); typ == "json_schema" && name != "" {
+		return typ + ":" + name
+	}
+	return typ
+}
+
+// Summary 所有设置了的参数，用于显示，如 temperature=0.7 num_ctx=2048
+func (o *GenerationOptions) Summary() []string {
+	var items []string
+	add := func(name string, v any) {
+		items = append(items, name+"="+formatOption(v))
+	}
+
+	if o.Stream != nil {
+		add("stream", *o.Stream)
+	}
+	names := make([]string, 0, len(o.Options))
+	for name := range o.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(name, o.Options[name])
+	}
+	if format := o.OutputFormat(); format != "" {
+		add("format", format)
+	}
+	if o.KeepAlive != nil {
+		add("keep_alive", o.KeepAlive)
+	}
+	if o.Think != nil {
+		add("think", o.Think)
+	}
+	if o.Temperature != nil {
+		add("temperature", *o.Temperature)
+	}
+	if o.TopP != nil {
+		add("top_p", *o.TopP)
+	}
+	if o.MaxTokens != nil {
+		add("max_tokens", *o.MaxTokens)
+	}
+	if o.MaxCompletionTokens != nil {
+		add("max_completion_tokens", *o.MaxCompletionTokens)
+	}
+	if o.Seed != nil {
+		add("seed", *o.Seed)
+	}
+	if o.Stop != nil {
+		add("stop", o.Stop)
+	}
+	if choice := gjson.ParseBytes(o.ToolChoice); choice.Exists() {
+		if name := choice.Get("function.name"); name.Exists() {
+			add("tool_choice", name.String())
+		} else {
+			add("tool_choice", choice.Value())
+		}
+	}
+	if o.StreamOptions != nil {
+		add("stream_options", o.StreamOptions)
+	}
+	if o.MaxOutput != nil {
+		add("max_output_tokens", *o.MaxOutput)
+	}
+	if o.ReasoningOptions != nil {
+		add("reasoning", o.ReasoningOptions)
+	}
+	if o.PreviousResponseID != "" {
+		add("previous_response_id", o.PreviousResponseID)
+	}
+	if o.Store != nil {
+		add("store", *o.Store)
+	}
+	if o.NPredict != nil {
+		add("n_predict", *o.NPredict)
+	}
+	if o.TopK != nil {
+		add("top_k", *o.TopK)
+	}
+	if o.CachePrompt != nil {
+		add("cache_prompt", *o.CachePrompt)
+	}
+	if o.IDSlot != nil {
+		add("id_slot", *o.IDSlot)
+	}
+	return items
+}
+
+func formatOption(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64, bool, int:
+		return fmt.Sprint(v)
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// ContextWarning 请求可能超过上下文长度时返回提示，ollama会静默截断超出num_ctx的内容
+// counter为nil时按字节数粗略估计，见CountTokens
+func (r *LLMRequest) ContextWarning(counter TokenCounter) string {
+	numCtx := r.NumCtx()
+	if numCtx <= 0 {
+		return ""
+	}
+	tokens := r.CountTokens(counter).Total
+	if tokens > numCtx {
+		return fmt.Sprintf("prompt is about %d tokens but num_ctx is %d, ollama will truncate it", tokens, numCtx)
+	}
+	if maxTokens := r.MaxOutputTokens(); maxTokens > 0 && tokens+maxTokens > numCtx {
+		return fmt.Sprintf("prompt (about %d tokens) plus max output %d tokens exceeds num_ctx %d", tokens, maxTokens, numCtx)
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package llmparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerationOptions(t *testing.T) {
+	ollama := ParseRequest(newRequest(t, "/api/chat", `{"model":"qwen3","stream":false,"think":true,"keep_alive":"5m",
+		"options":{"temperature":0.2,"num_ctx":16,"seed":42,"stop":["</s>"]},
+		"format":{"type":"object","required":["city"],"properties":{"city":{"type":"string"}}},
+		"messages":[{"role":"user","content":"`+strings.Repeat("word ", 40)+`"}]}`))
+	if ollama == nil {
+		t.Fatal("parse ollama request failed")
+	}
+	if got := strings.Join(ollama.Summary(), " "); got != `stream=false num_ctx=16 seed=42 stop=["</s>"] temperature=0.2 format=json_schema keep_alive=5m think=true` {
+		t.Fatal(got)
+	}
+	if temp, ok := ollama.TemperatureValue(); !ok || temp != 0.2 {
+		t.Fatal(temp, ok)
+	}
+	if schema := ollama.OutputSchema(); schema == nil || RenderSchema(schema, "") != "└── city: string (required)\n" {
+		t.Fatal(schema)
+	}
+	if warning := ollama.ContextWarning(nil); !strings.Contains(warning, "num_ctx is 16") {
+		t.Fatal(warning)
+	}
+
+	openai := ParseRequest(newRequest(t, "/v1/chat/completions", `{"model":"gpt-4o","temperature":0,"max_tokens":512,
+		"response_format":{"type":"json_schema","json_schema":{"name":"weather","schema":{"type":"object","properties":{"temp":{"type":"number"}}}}},
+		"tool_choice":{"type":"function","function":{"name":"get_weather"}},"stream":true,"stream_options":{"include_usage":true},
+		"messages":[{"role":"user","content":"hi"}]}`))
+	if openai == nil {
+		t.Fatal("parse openai request failed")
+	}
+	if got := strings.Join(openai.Summary(), " "); got != `stream=true format=json_schema:weather temperature=0 max_tokens=512 tool_choice=get_weather stream_options={"include_usage":true}` {
+		t.Fatal(got)
+	}
+	if openai.MaxOutputTokens() != 512 || openai.OutputSchema() == nil || openai.ContextWarning(nil) != "" {
+		t.Fatal(openai.MaxOutputTokens(), openai.OutputSchema())
+	}
+}
+
+func TestGenerationOptionsLenient(t *testing.T) {
+	req := ParseRequest(newRequest(t, "/v1/chat/completions", `{"model":"gpt-4o","max_tokens":1e4,"seed":1024.0,
+		"temperature":"0.7","stream":"true","top_p":"high","messages":[{"role":"user","content":"hi"}]}`))
+	if req == nil {
+		t.Fatal("option types should not fail the request")
+	}
+	if len(req.Messages) != 1 || req.MaxOutputTokens() != 10000 || *req.Seed != 1024 || req.TopP != nil {
+		t.Fatal(req.Messages, req.MaxOutputTokens(), req.Seed, req.TopP)
+	}
+	if temp, ok := req.TemperatureValue(); !ok || temp != 0.7 || req.Stream == nil || !*req.Stream {
+		t.Fatal(temp, ok, req.Stream)
+	}
+
+	ollama := ParseRequest(newRequest(t, "/api/chat", `{"model":"qwen3","options":{"num_ctx":"2048","temperature":"0.2"},
+		"messages":[{"role":"user","content":"hi"}]}`))
+	if ollama == nil || ollama.NumCtx() != 2048 {
+		t.Fatal("ollama options:", ollama)
+	}
+	if temp, ok := ollama.TemperatureValue(); !ok || temp != 0.2 {
+		t.Fatal(temp, ok)
+	}
+}
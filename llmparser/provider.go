@@ -0,0 +1,85 @@
+package llmparser
+
+import (
+	"strings"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+)
+
+// Provider 模型服务的类型，根据请求的url判断
+type Provider string
+
+const (
+	ProviderOllama    Provider = "ollama"
+	ProviderLMStudio  Provider = "lmstudio"
+	ProviderOpenAI    Provider = "openai" // openai兼容的api，可能是ollama、lmstudio或者其他服务
+	ProviderAnthropic Provider = "anthropic"
+	ProviderLlamaCpp  Provider = "llamacpp" // llama.cpp llama-server的原生接口
+)
+
+// Name 用于显示的名字
+func (p Provider) Name() string {
+	switch p {
+	case ProviderOllama:
+		return "Ollama"
+	case ProviderLMStudio:
+		return "LM Studio"
+	case ProviderOpenAI:
+		return "OpenAI compatible"
+	case ProviderAnthropic:
+		return "Anthropic compatible"
+	case ProviderLlamaCpp:
+		return "llama.cpp"
+	}
+	return string(p)
+}
+
+// DetectProvider 根据请求的url判断模型服务的类型，无法判断时返回空
+func DetectProvider(req *httpdumper.Request) Provider {
+	if req.URL == nil {
+		return ""
+	}
+	path := req.URL.Path
+	switch {
+	case isLlamaCppPath(path):
+		return ProviderLlamaCpp
+	case strings.HasPrefix(path, "/api/v0/"):
+		return ProviderLMStudio
+	case strings.HasPrefix(path, "/api/"):
+		return ProviderOllama
+	case strings.HasSuffix(path, "/v1/messages"):
+		return ProviderAnthropic
+	case strings.Contains(path, "/v1/"):
+		return ProviderOpenAI
+	}
+	return ""
+}
+
+// APIRequest 对话和生成以外的接口请求，比如向量计算、模型列表和模型管理
+type APIRequest interface {
+	Provider() Provider
+	// Summary 一行摘要
+	Summary() string
+	// ParseResponse 解析对应的响应，失败时返回nil
+	ParseResponse(resp *httpdumper.Response) APIResponse
+}
+
+// APIResponse 对话和生成以外的接口响应
+type APIResponse interface {
+	// Summary 多行摘要，每行一项
+	Summary() []string
+}
+
+// ParseAPIRequest 依次尝试各个模型服务的接口，都不是时返回nil
+func ParseAPIRequest(req *httpdumper.Request) APIRequest {
+	if r := ParseOllamaRequest(req); r != nil {
+		return r
+	}
+	if r := ParseLMStudioRequest(req); r != nil {
+		return r
+	}
+	if r := ParseLlamaCppRequest(req); r != nil {
+		return r
+	}
+	return ParseRetrievalRequest(req)
+}
@@ -0,0 +1,65 @@
+package llmparser
+
+import "strings"
+
+const (
+	thinkStart = "<think>"
+	thinkEnd   = "</think>"
+)
+
+// SplitReasoning 把文本中<think>...</think>包裹的思考过程和正文分开，支持：
+// 1. 任意位置、多段的思考过程，多段之间用空行连接
+// 2. 没有结束标签的思考过程，比如流式响应被中断或者达到max_tokens，剩余部分都作为思考过程
+// 3. 只有结束标签的思考过程，有些模型的模板会把<think>放到提示词中，响应直接从思考内容开始，
+// 这时思考过程只能在消息的开头，并且结束标签在行首，正文中间提到</think>不算
+func SplitReasoning(text string) (content, reasoning string) {
+	var contents, reasonings []string
+	rest := text
+
+	// 只有结束标签，之前的内容都是思考过程
+	if end := strings.Index(rest, thinkEnd); end >= 0 && !strings.Contains(rest[:end], thinkStart) &&
+		(strings.TrimSpace(rest[:end]) == "" || strings.HasSuffix(strings.TrimRight(rest[:end], " \t"), "\n")) {
+		reasonings = append(reasonings, rest[:end])
+		rest = rest[end+len(thinkEnd):]
+	}
+
+	for {
+		start := strings.Index(rest, thinkStart)
+		if start < 0 {
+			contents = append(contents, rest)
+			break
+		}
+		contents = append(contents, rest[:start])
+		rest = rest[start+len(thinkStart):]
+
+		end := strings.Index(rest, thinkEnd)
+		if end < 0 {
+			reasonings = append(reasonings, rest)
+			break
+		}
+		reasonings = append(reasonings, rest[:end])
+		rest = rest[end+len(thinkEnd):]
+	}
+
+	if len(reasonings) == 0 {
+		return text, ""
+	}
+	return strings.TrimSpace(strings.Join(contents, "")), joinReasoning(reasonings...)
+}
+
+// ExtractReasoning 从重组后的响应中分离思考过程，reasoning是响应字段中显式返回的思考过程，和正文中<think>标签包裹的合并
+func ExtractReasoning(content, reasoning string) (string, string) {
+	content, tagged := SplitReasoning(content)
+	return content, joinReasoning(reasoning, tagged)
+}
+
+// joinReasoning 用空行连接非空的思考过程
+func joinReasoning(reasonings ...string) string {
+	var result []string
+	for _, r := range reasonings {
+		if r = strings.TrimSpace(r); r != "" {
+			result = append(result, r)
+		}
+	}
+	return strings.Join(result, "\n\n")
+}
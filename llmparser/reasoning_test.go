@@ -0,0 +1,57 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSplitReasoning(t *testing.T) {
+	cases := []struct {
+		text, content, reasoning string
+	}{
+		{"hello", "hello", ""},
+		{"<think>\nplan\n</think>\n\nanswer", "answer", "plan"},
+		{"intro <think>a</think> middle <think>b</think> end", "intro  middle  end", "a\n\nb"},
+		{"<think>still thinking", "", "still thinking"},
+		{"answer <think>cut off", "answer", "cut off"},
+		{"implicit start\n</think>\n\nanswer", "answer", "implicit start"},
+		{"close it with </think> in the answer", "close it with </think> in the answer", ""},
+		{"<think></think>answer", "answer", ""},
+	}
+	for _, c := range cases {
+		content, reasoning := SplitReasoning(c.text)
+		if content != c.content || reasoning != c.reasoning {
+			t.Fatalf("%q: got (%q, %q), want (%q, %q)", c.text, content, reasoning, c.content, c.reasoning)
+		}
+	}
+}
+
+func TestResponseReasoning(t *testing.T) {
+	chunks := []string{
+		`{"model":"qwen3","message":{"role":"assistant","content":"","thinking":"let me "},"done":false}`,
+		`{"model":"qwen3","message":{"role":"assistant","content":"","thinking":"think"},"done":false}`,
+		`{"model":"qwen3","message":{"role":"assistant","content":"Hi!"},"done":true}`,
+		`{"choices":[{"index":0,"delta":{"reasoning_content":" more"}}]}`,
+		`{"choices":[{"index":0,"delta":{"reasoning":" and more"}}]}`,
+		`{"choices":[{"index":0,"delta":{"reasoning":" abc","reasoning_content":" abc"}}]}`,
+		`{"model":"qwen3","thinking":"!","response":""}`,
+	}
+	var content, reasoning string
+	for _, chunk := range chunks {
+		var resp LLMResponse
+		if err := json.Unmarshal([]byte(chunk), &resp); err != nil {
+			t.Fatal(err)
+		}
+		content += resp.String()
+		reasoning += resp.Reasoning()
+	}
+	content, reasoning = ExtractReasoning(content, reasoning)
+	if content != "Hi!" || reasoning != "let me think more and more abc!" {
+		t.Fatalf("got (%q, %q)", content, reasoning)
+	}
+
+	content, reasoning = ExtractReasoning("<think>tagged</think>answer", "explicit")
+	if content != "answer" || reasoning != "explicit\n\ntagged" {
+		t.Fatalf("got (%q, %q)", content, reasoning)
+	}
+}
@@ -0,0 +1,133 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// ResponseItem openai responses api (/v1/responses) 的输入和输出项
+type ResponseItem struct {
+	Type      string          `json:"type"` // message、function_call、function_call_output、reasoning，message可以省略
+	ID        string          `json:"id,omitempty"`
+	Role      string          `json:"role,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"` // 字符串或者input_text/output_text/input_image内容块
+	CallID    string          `json:"call_id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments string          `json:"arguments,omitempty"` // json字符串
+	Output    json.RawMessage `json:"output,omitempty"`    // function_call_output的结果，字符串或者内容块
+	Summary   []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"summary,omitempty"` // reasoning的摘要
+}
+
+// newToolCall 创建工具调用，arguments是json字符串
+func newToolCall(name, arguments string) LLMTool {
+	tool := LLMTool{Type: "function"}
+	tool.Function.Name = name
+	if json.Unmarshal([]byte(arguments), &tool.Function.Arguments) != nil {
+		tool.Function.Arguments = nil
+		tool.Function.RawArguments = arguments
+	}
+	return tool
+}
+
+// reasoningSummary reasoning项的摘要文本
+func (item *ResponseItem) reasoningSummary() string {
+	var texts []string
+	for _, s := range item.Summary {
+		texts = append(texts, s.Text)
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// Message 转换为对话消息：function_call为assistant的工具调用，function_call_output为tool消息，reasoning为assistant的思考过程
+func (item *ResponseItem) Message() LLMMessage {
+	switch item.Type {
+	case "function_call":
+		return LLMMessage{Role: "assistant", ToolCalls: []LLMTool{newToolCall(item.Name, item.Arguments)}}
+	case "function_call_output":
+		return LLMMessage{Role: "tool", Content: textContent(gjson.ParseBytes(item.Output))}
+	case "reasoning":
+		return LLMMessage{Role: "assistant", Reasoning: item.reasoningSummary()}
+	}
+	msg := LLMMessage{Role: item.Role}
+	msg.Content, msg.Parts = parseContent(gjson.ParseBytes(item.Content))
+	return msg
+}
+
+// responsesInput responses api的input，字符串是一条用户消息，数组是输入项
+func responsesInput(input gjson.Result) []LLMMessage {
+	if input.Type == gjson.String {
+		return []LLMMessage{{Role: "user", Content: input.String()}}
+	}
+	var messages []LLMMessage
+	for _, raw := range input.Array() {
+		var item ResponseItem
+		if err := json.Unmarshal([]byte(raw.Raw), &item); err != nil {
+			continue
+		}
+		messages = append(messages, item.Message())
+	}
+	return messages
+}
+
+// responsesEvent responses api的流式事件和非流式响应
+// 流式响应只使用增量事件的文本和output_item.done的完整工具调用，response.completed只取token用量，避免重复
+type responsesEvent struct {
+	Type     string          `json:"type"` // response.output_text.delta等，非流式响应没有
+	Delta    json.RawMessage `json:"delta"`
+	Item     *ResponseItem   `json:"item"`
+	Output   []ResponseItem  `json:"output"` // 非流式响应
+	Status   string          `json:"status"` // 非流式响应
+	Response *struct {
+		Status            string `json:"status"`
+		IncompleteDetails *struct {
+			Reason string `json:"reason"`
+		} `json:"incomplete_details"`
+		Usage *Usage    `json:"usage"`
+		Error *LLMError `json:"error"` // response.failed
+	} `json:"response"`
+}
+
+// parseResponses 解析responses api的事件，填充到r中
+func (r *LLMResponse) parseResponses(data []byte) {
+	var event responsesEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return
+	}
+
+	delta := gjson.ParseBytes(event.Delta).String()
+	switch event.Type {
+	case "response.output_text.delta", "response.refusal.delta":
+		r.text += delta
+	case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
+		r.reasoning += delta
+	case "response.output_item.done":
+		if item := event.Item; item != nil && item.Type == "function_call" {
+			r.toolCalls = append(r.toolCalls, newToolCall(item.Name, item.Arguments))
+		}
+	case "response.completed", "response.incomplete", "response.failed":
+		if event.Response != nil && event.Response.Usage != nil {
+			r.Usage = event.Response.Usage
+		}
+		if resp := event.Response; resp != nil {
+			r.Error = resp.Error
+			r.finishReason = resp.Status
+			if resp.IncompleteDetails != nil && resp.IncompleteDetails.Reason != "" {
+				r.finishReason = resp.IncompleteDetails.Reason
+			}
+		}
+	case "":
+		// 非流式响应，object为response
+		r.finishReason = event.Status
+		for _, item := range event.Output {
+			msg := item.Message()
+			r.text += msg.Content
+			r.reasoning += msg.Reasoning
+			r.toolCalls = append(r.toolCalls, msg.ToolCalls...)
+		}
+	}
+}
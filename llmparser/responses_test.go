@@ -0,0 +1,92 @@
+package llmparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+)
+
+func TestResponsesRequest(t *testing.T) {
+	llmReq := ParseRequest(newRequest(t, "/v1/responses", `{"model":"gpt-5","instructions":"You are a coding agent",
+		"input":[
+			{"role":"user","content":[{"type":"input_text","text":"list files"}]},
+			{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"need ls"}]},
+			{"type":"function_call","call_id":"call_1","name":"shell","arguments":"{\"cmd\":\"ls\"}"},
+			{"type":"function_call_output","call_id":"call_1","output":"main.go"},
+			{"type":"message","role":"assistant","content":[{"type":"output_text","text":"main.go"}]}],
+		"tools":[{"type":"function","name":"shell","description":"run a command","parameters":{"type":"object","properties":{"cmd":{"type":"string"}},"required":["cmd"]}}],
+		"reasoning":{"effort":"high"},"max_output_tokens":1024,"previous_response_id":"resp_0","store":false,
+		"text":{"format":{"type":"json_schema","name":"files","schema":{"type":"object","properties":{"files":{"type":"array"}}}}}}`))
+	if llmReq == nil {
+		t.Fatal("parse responses request failed")
+	}
+	if llmReq.System != "You are a coding agent" || len(llmReq.Messages) != 5 {
+		t.Fatal(llmReq.System, len(llmReq.Messages))
+	}
+	msgs := llmReq.Messages
+	if msgs[0].Role != "user" || msgs[0].Content != "list files" || msgs[1].Reasoning != "need ls" ||
+		msgs[2].ToolCallsString() != `Tool call: shell({"cmd":"ls"})` || msgs[3].Role != "tool" || msgs[3].Content != "main.go" ||
+		msgs[4].Role != "assistant" || msgs[4].Content != "main.go" {
+		t.Fatalf("%+v", msgs)
+	}
+	if len(llmReq.Tools) != 1 || llmReq.Tools[0].Signature() != "shell(cmd)" {
+		t.Fatal(llmReq.Tools)
+	}
+	if got := strings.Join(llmReq.Summary(), " "); got != `format=json_schema:files max_output_tokens=1024 reasoning={"effort":"high"} previous_response_id=resp_0 store=false` {
+		t.Fatal(got)
+	}
+	if llmReq.OutputSchema() == nil || llmReq.MaxOutputTokens() != 1024 {
+		t.Fatal("output schema or max output tokens missing")
+	}
+
+	simple := ParseRequest(newRequest(t, "/v1/responses", `{"model":"gpt-5","input":"hi"}`))
+	if simple == nil || len(simple.Messages) != 1 || simple.Messages[0].Content != "hi" {
+		t.Fatal("parse string input failed")
+	}
+}
+
+func TestResponsesStream(t *testing.T) {
+	events := []string{
+		`{"type":"response.created","response":{"id":"resp_1","object":"response","status":"in_progress","output":[]}}`,
+		`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","delta":"thinking"}`,
+		`{"type":"response.output_text.delta","item_id":"msg_1","output_index":1,"content_index":0,"delta":"Hel"}`,
+		`{"type":"response.output_text.delta","item_id":"msg_1","output_index":1,"content_index":0,"delta":"lo"}`,
+		`{"type":"response.output_text.done","item_id":"msg_1","text":"Hello"}`,
+		`{"type":"response.output_item.added","item":{"type":"function_call","name":"shell","call_id":"call_1","arguments":""}}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","delta":"{\"cmd\":"}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","delta":"\"ls\"}"}`,
+		`{"type":"response.output_item.done","item":{"type":"function_call","name":"shell","call_id":"call_1","arguments":"{\"cmd\":\"ls\"}"}}`,
+		`{"type":"response.completed","response":{"id":"resp_1","object":"response","status":"completed",
+			"output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Hello"}]}],
+			"usage":{"input_tokens":20,"output_tokens":12,"output_tokens_details":{"reasoning_tokens":4},"total_tokens":32}}}`,
+	}
+	var response, reasoning string
+	var toolCalls []LLMTool
+	var metrics *Metrics
+	for _, event := range events {
+		resp := ParseResponse(&httpdumper.Response{Body: []byte(event)})
+		if resp == nil {
+			t.Fatal("parse event failed:", event)
+		}
+		response += resp.String()
+		reasoning += resp.Reasoning()
+		toolCalls = append(toolCalls, resp.ToolCalls()...)
+		metrics = metrics.Merge(resp.Metrics())
+	}
+	if response != "Hello" || reasoning != "thinking" || len(toolCalls) != 1 || toolCalls[0].Function.Arguments["cmd"] != "ls" {
+		t.Fatal(response, reasoning, toolCalls)
+	}
+	if metrics == nil || metrics.String() != "prompt 20 tok, completion 12 tok (reasoning 4)" {
+		t.Fatal(metrics)
+	}
+
+	resp := ParseResponse(&httpdumper.Response{Body: []byte(`{"id":"resp_2","object":"response","status":"completed",
+		"output":[{"type":"reasoning","summary":[{"type":"summary_text","text":"short"}]},
+			{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Done"}]},
+			{"type":"function_call","name":"shell","call_id":"call_2","arguments":"{}"}],
+		"usage":{"input_tokens":5,"output_tokens":3}}`)})
+	if resp == nil || resp.String() != "Done" || resp.Reasoning() != "short" || len(resp.ToolCalls()) != 1 {
+		t.Fatal("parse non-stream response failed")
+	}
+}
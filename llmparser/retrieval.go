@@ -0,0 +1,252 @@
+package llmparser
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/tidwall/gjson"
+)
+
+// Retrieval 向量计算和重排序请求的输入，RAG agent通常会把这些文本片段放进之后的对话请求
+type Retrieval struct {
+	Kind   string // embed、rerank
+	Model  string
+	Query  string   // rerank的查询
+	Inputs []string // 向量计算的文本或者重排序的文档
+}
+
+// Retriever 向量计算和重排序请求实现这个接口，不是这两类请求时返回nil
+type Retriever interface {
+	Retrieval() *Retrieval
+}
+
+// UsedIn 在对话请求的系统提示词、prompt或者消息中原样出现过的输入的下标
+func (r *Retrieval) UsedIn(llmReq *LLMRequest) []int {
+	texts := []string{llmReq.System, llmReq.Prompt}
+	for _, msg := range llmReq.Messages {
+		texts = append(texts, msg.Content)
+	}
+	context := strings.Join(texts, "\n")
+
+	var used []int
+	for i, input := range r.Inputs {
+		if input = strings.TrimSpace(input); input != "" && strings.Contains(context, input) {
+			used = append(used, i)
+		}
+	}
+	return used
+}
+
+// EmbeddingRequest openai兼容的 /v1/embeddings
+type EmbeddingRequest struct {
+	Model          string `json:"model"`
+	Input          any    `json:"input"` // 字符串、字符串数组或者token数组
+	Dimensions     int    `json:"dimensions,omitempty"`
+	EncodingFormat string `json:"encoding_format,omitempty"` // float或者base64
+}
+
+// RerankRequest 重排序请求，/v1/rerank、/rerank等，llama.cpp、vllm、jina和cohere的格式基本相同
+type RerankRequest struct {
+	provider  Provider
+	Model     string `json:"model"`
+	Query     string `json:"query"`
+	Documents []any  `json:"documents"` // 字符串或者{"text":"..."}
+	TopN      int    `json:"top_n,omitempty"`
+}
+
+// isRerankPath 重排序接口的路径
+func isRerankPath(path string) bool {
+	switch strings.TrimSuffix(path, "/") {
+	case "/rerank", "/reranking", "/v1/rerank", "/v1/reranking", "/v2/rerank":
+		return true
+	}
+	return false
+}
+
+// ParseRetrievalRequest 解析openai兼容的向量请求和重排序请求，不是时返回nil
+func ParseRetrievalRequest(req *httpdumper.Request) APIRequest {
+	if req.URL == nil {
+		return nil
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	switch {
+	case path == "/v1/embeddings":
+		var r EmbeddingRequest
+		if err := json.Unmarshal(req.Body, &r); err != nil {
+			return nil
+		}
+		return &r
+	case isRerankPath(path):
+		r := RerankRequest{provider: DetectProvider(req)}
+		if err := json.Unmarshal(req.Body, &r); err != nil {
+			return nil
+		}
+		return &r
+	}
+	return nil
+}
+
+// Provider 模型服务的类型
+func (r *EmbeddingRequest) Provider() Provider {
+	return ProviderOpenAI
+}
+
+// Retrieval 需要计算向量的文本，token数组的输入没有文本
+func (r *EmbeddingRequest) Retrieval() *Retrieval {
+	return &Retrieval{Kind: "embed", Model: r.Model, Inputs: inputStrings(r.Input)}
+}
+
+// Summary 一行摘要
+func (r *EmbeddingRequest) Summary() string {
+	summary := r.Retrieval().Summary()
+	if r.Dimensions > 0 {
+		summary += fmt.Sprintf(", dimensions=%d", r.Dimensions)
+	}
+	return summary
+}
+
+// ParseResponse 解析对应的响应，base64编码的向量按float32计算维度
+func (r *EmbeddingRequest) ParseResponse(resp *httpdumper.Response) APIResponse {
+	body := gjson.ParseBytes(resp.Body)
+	if msg := errorMessage(body); msg != "" {
+		return &retrievalResponse{lines: []string{"error: " + msg}}
+	}
+	data := body.Get("data").Array()
+	dims := 0
+	if len(data) > 0 {
+		embedding := data[0].Get("embedding")
+		if embedding.Type == gjson.String {
+			decoded, _ := base64.StdEncoding.DecodeString(embedding.String())
+			dims = len(decoded) / 4
+		} else {
+			dims = len(embedding.Array())
+		}
+	}
+	line := fmt.Sprintf("%d embeddings, %d dimensions", len(data), dims)
+	if tokens := body.Get("usage.prompt_tokens").Int(); tokens > 0 {
+		line += fmt.Sprintf(", prompt %d tok", tokens)
+	}
+	return &retrievalResponse{lines: []string{line}}
+}
+
+// Provider 模型服务的类型
+func (r *RerankRequest) Provider() Provider {
+	if r.provider == "" {
+		return ProviderOpenAI
+	}
+	return r.provider
+}
+
+// documents 文档的文本
+func (r *RerankRequest) documents() []string {
+	docs := make([]string, 0, len(r.Documents))
+	for _, doc := range r.Documents {
+		switch v := doc.(type) {
+		case string:
+			docs = append(docs, v)
+		case map[string]any:
+			text, _ := v["text"].(string)
+			docs = append(docs, text)
+		default:
+			docs = append(docs, "")
+		}
+	}
+	return docs
+}
+
+// Retrieval 查询和需要排序的文档
+func (r *RerankRequest) Retrieval() *Retrieval {
+	return &Retrieval{Kind: "rerank", Model: r.Model, Query: r.Query, Inputs: r.documents()}
+}
+
+// Summary 一行摘要
+func (r *RerankRequest) Summary() string {
+	summary := r.Retrieval().Summary()
+	if r.TopN > 0 {
+		summary += fmt.Sprintf(", top_n=%d", r.TopN)
+	}
+	return summary
+}
+
+// ParseResponse 按相关性从高到低显示文档，文档内容来自请求
+func (r *RerankRequest) ParseResponse(resp *httpdumper.Response) APIResponse {
+	body := gjson.ParseBytes(resp.Body)
+	if msg := errorMessage(body); msg != "" {
+		return &retrievalResponse{lines: []string{"error: " + msg}}
+	}
+	type result struct {
+		index int
+		score float64
+	}
+	var results []result
+	for _, item := range body.Get("results").Array() {
+		score := item.Get("relevance_score")
+		if !score.Exists() {
+			score = item.Get("score")
+		}
+		results = append(results, result{int(item.Get("index").Int()), score.Float()})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+
+	docs := r.documents()
+	lines := []string{fmt.Sprintf("%d results", len(results))}
+	for rank, res := range results {
+		doc := ""
+		if res.index >= 0 && res.index < len(docs) {
+			doc = Truncate(docs[res.index], 80)
+		}
+		lines = append(lines, fmt.Sprintf("  #%d %.4f [%d] %s", rank+1, res.score, res.index+1, doc))
+	}
+	return &retrievalResponse{lines: lines}
+}
+
+// retrievalResponse 向量和重排序的响应摘要
+type retrievalResponse struct {
+	lines []string
+}
+
+// Summary 多行摘要，每行一项
+func (r *retrievalResponse) Summary() []string {
+	return r.lines
+}
+
+// errorMessage {"error":"..."}或者{"error":{"message":"..."}}中的错误信息，没有错误时返回空
+func errorMessage(body gjson.Result) string {
+	e := body.Get("error")
+	if msg := e.Get("message"); msg.Exists() {
+		return msg.String()
+	}
+	return e.String()
+}
+
+// Summary 一行摘要，比如 embed nomic-embed-text: 3 inputs, 1200 characters
+func (r *Retrieval) Summary() string {
+	chars := 0
+	for _, input := range r.Inputs {
+		chars += len([]rune(input))
+	}
+	name, unit := r.Kind, "inputs"
+	if r.Model != "" {
+		name += " " + r.Model
+	}
+	if r.Kind == "rerank" {
+		unit = "documents"
+	}
+	return fmt.Sprintf("%s: %d %s, %d characters", name, len(r.Inputs), unit, chars)
+}
+
+// Truncate 截断过长的文本用于单行显示，换行替换为空格
+func Truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,64 @@
+package llmparser
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/google/gopacket"
+)
+
+func TestRetrieval(t *testing.T) {
+	chunks := []string{"Go was designed at Google.", "Rust has a borrow checker.", "Go has goroutines."}
+
+	embedReq := newRequest(t, "/v1/embeddings", `{"model":"text-embedding-3-small","input":["`+strings.Join(chunks, `","`)+`"],"encoding_format":"base64"}`)
+	apiReq := ParseAPIRequest(embedReq)
+	if apiReq == nil || apiReq.Provider() != ProviderOpenAI {
+		t.Fatal("parse embeddings request failed")
+	}
+	if got := apiReq.Summary(); got != "embed text-embedding-3-small: 3 inputs, 70 characters" {
+		t.Fatal(got)
+	}
+	vector := make([]byte, 4*8)
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(vector[i*4:], math.Float32bits(float32(i)))
+	}
+	encoded := base64.StdEncoding.EncodeToString(vector)
+	resp := httpdumper.NewResponse(embedReq, &http.Response{StatusCode: 200}, gopacket.Flow{}, gopacket.Flow{})
+	resp.SetBody([]byte(`{"object":"list","data":[{"embedding":"` + encoded + `","index":0},{"embedding":"` + encoded + `","index":1},{"embedding":"` + encoded + `","index":2}],"usage":{"prompt_tokens":18}}`))
+	if got := apiReq.ParseResponse(resp).Summary(); strings.Join(got, "|") != "3 embeddings, 8 dimensions, prompt 18 tok" {
+		t.Fatal(got)
+	}
+
+	rerankReq := newRequest(t, "/v1/rerank", `{"model":"bge-reranker","query":"go concurrency","documents":["`+chunks[0]+`",{"text":"`+chunks[1]+`"},"`+chunks[2]+`"],"top_n":2}`)
+	apiReq = ParseAPIRequest(rerankReq)
+	if apiReq == nil {
+		t.Fatal("parse rerank request failed")
+	}
+	if got := apiReq.Summary(); got != "rerank bge-reranker: 3 documents, 70 characters, top_n=2" {
+		t.Fatal(got)
+	}
+	resp = httpdumper.NewResponse(rerankReq, &http.Response{StatusCode: 200}, gopacket.Flow{}, gopacket.Flow{})
+	resp.SetBody([]byte(`{"results":[{"index":0,"relevance_score":0.3},{"index":2,"relevance_score":0.9}]}`))
+	want := []string{"2 results", "  #1 0.9000 [3] Go has goroutines.", "  #2 0.3000 [1] Go was designed at Google."}
+	if got := apiReq.ParseResponse(resp).Summary(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got %q", got)
+	}
+	if DetectProvider(newRequest(t, "/rerank", `{}`)) != ProviderLlamaCpp {
+		t.Fatal("/rerank should be llama.cpp")
+	}
+
+	r := apiReq.(Retriever).Retrieval()
+	chat := ParseRequest(newRequest(t, "/v1/chat/completions", `{"model":"qwen3","messages":[
+		{"role":"system","content":"Answer with the context:\n`+chunks[2]+`\n`+chunks[0]+`"},{"role":"user","content":"go concurrency"}]}`))
+	if used := r.UsedIn(chat); len(used) != 2 || used[0] != 0 || used[1] != 2 {
+		t.Fatal(used)
+	}
+	if r.Query != "go concurrency" || Truncate("a\nb  c", 3) != "a b…" {
+		t.Fatal("unexpected query or truncate")
+	}
+}
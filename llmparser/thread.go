@@ -0,0 +1,164 @@
+package llmparser
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Thread 一次对话请求在会话中的位置
+type Thread struct {
+	ConversationID string       // 会话ID
+	Turn           int          // 会话中的第几次请求，从1开始
+	Continuation   bool         // 是否是之前请求的延续
+	NewMessages    []LLMMessage // 相比上一次请求新增的消息，不包含已经显示过的上一次响应
+	Hidden         int          // 省略的历史消息数
+}
+
+// conversation 会话
+type conversation struct {
+	id       string
+	turn     int
+	messages []LLMMessage // 最近一次请求的全部消息
+	response string       // 最近一次请求的响应
+}
+
+// Threader 把连续的对话请求归到同一个会话
+// Agent每次调用/api/chat都会重新发送完整的历史消息，所以如果之前某个请求的消息是当前请求消息的前缀，就认为是同一个会话
+type Threader struct {
+	mutex            sync.Mutex
+	conversations    []*conversation // 按最近使用排序，最近的在最后
+	nextID           int
+	MaxConversations int // 最多保留的会话数，默认256
+}
+
+// NewThreader 创建会话识别器
+func NewThreader() *Threader {
+	return &Threader{MaxConversations: 256}
+}
+
+// normalizeContent 比较消息时忽略前后空白和思考过程，agent回传历史消息时通常会去掉思考过程
+func normalizeContent(s string) string {
+	content, _ := SplitReasoning(s)
+	return strings.TrimSpace(content)
+}
+
+// sameMessage 判断两条消息是否相同
+func sameMessage(a, b LLMMessage) bool {
+	if a.Role != b.Role || normalizeContent(a.Content) != normalizeContent(b.Content) {
+		return false
+	}
+	if len(a.ToolCalls) != len(b.ToolCalls) {
+		return false
+	}
+	for i := range a.ToolCalls {
+		if a.ToolCalls[i].Function.Name != b.ToolCalls[i].Function.Name {
+			return false
+		}
+	}
+	if !slices.Equal(a.Images, b.Images) {
+		return false
+	}
+	return slices.EqualFunc(a.Media(), b.Media(), samePart)
+}
+
+// samePart 判断两个图片、音频或者文件内容块是否相同
+func samePart(a, b ContentPart) bool {
+	return a.Type == b.Type && a.MimeType == b.MimeType && a.URL == b.URL &&
+		a.FileName == b.FileName && bytes.Equal(a.Data, b.Data)
+}
+
+// hasDialog 是否包含用户或者助手的消息，只有相同的系统提示词不能说明是同一个会话
+func hasDialog(messages []LLMMessage) bool {
+	for _, m := range messages {
+		if m.Role == "user" || m.Role == "assistant" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrefix prefix是否是messages的前缀，prefix中至少要有一条用户或者助手的消息
+func isPrefix(prefix, messages []LLMMessage) bool {
+	if len(prefix) > len(messages) || !hasDialog(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if !sameMessage(prefix[i], messages[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Track 识别请求所属的会话，没有messages的请求（比如/api/generate）返回nil
+func (t *Threader) Track(llmReq *LLMRequest) *Thread {
+	if len(llmReq.Messages) == 0 {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// 找到最长的前缀
+	index := -1
+	for i, c := range t.conversations {
+		if isPrefix(c.messages, llmReq.Messages) && (index < 0 || len(c.messages) > len(t.conversations[index].messages)) {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		t.nextID++
+		c := &conversation{
+			id:       fmt.Sprintf("conv-%d", t.nextID),
+			turn:     1,
+			messages: llmReq.Messages,
+		}
+		t.conversations = append(t.conversations, c)
+		if t.MaxConversations > 0 && len(t.conversations) > t.MaxConversations {
+			t.conversations = t.conversations[len(t.conversations)-t.MaxConversations:]
+		}
+		return &Thread{
+			ConversationID: c.id,
+			Turn:           1,
+			NewMessages:    llmReq.Messages,
+		}
+	}
+
+	c := t.conversations[index]
+	thread := &Thread{
+		ConversationID: c.id,
+		Turn:           c.turn + 1,
+		Continuation:   true,
+		NewMessages:    llmReq.Messages[len(c.messages):],
+		Hidden:         len(c.messages),
+	}
+	// 上一次的响应已经显示过了
+	if len(thread.NewMessages) > 0 && thread.NewMessages[0].Role == "assistant" &&
+		c.response != "" && normalizeContent(thread.NewMessages[0].Content) == normalizeContent(c.response) {
+		thread.NewMessages = thread.NewMessages[1:]
+		thread.Hidden++
+	}
+
+	c.turn++
+	c.messages = llmReq.Messages
+	c.response = ""
+	// 移到最后表示最近使用
+	t.conversations = append(append(t.conversations[:index], t.conversations[index+1:]...), c)
+	return thread
+}
+
+// AddResponse 记录会话最近一次请求的响应，下一次请求中重复发送的这条响应不会再作为新消息
+func (t *Threader) AddResponse(conversationID, response string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, c := range t.conversations {
+		if c.id == conversationID {
+			c.response = response
+			return
+		}
+	}
+}
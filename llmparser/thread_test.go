@@ -0,0 +1,57 @@
+package llmparser
+
+import "testing"
+
+func TestThreader(t *testing.T) {
+	threader := NewThreader()
+	system := LLMMessage{Role: "system", Content: "You are a helpful assistant."}
+
+	first := threader.Track(&LLMRequest{Messages: []LLMMessage{system, {Role: "user", Content: "hi"}}})
+	if first.Continuation || first.Turn != 1 || len(first.NewMessages) != 2 {
+		t.Fatalf("unexpected first thread: %+v", first)
+	}
+	threader.AddResponse(first.ConversationID, "<think>\nthinking\n</think>\n\nHello!")
+
+	second := threader.Track(&LLMRequest{Messages: []LLMMessage{
+		system,
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "Hello!"},
+		{Role: "user", Content: "What is the capital of France?"},
+	}})
+	if !second.Continuation || second.ConversationID != first.ConversationID || second.Turn != 2 {
+		t.Fatalf("unexpected second thread: %+v", second)
+	}
+	if len(second.NewMessages) != 1 || second.NewMessages[0].Content != "What is the capital of France?" || second.Hidden != 3 {
+		t.Fatalf("unexpected new messages: %+v", second)
+	}
+
+	other := threader.Track(&LLMRequest{Messages: []LLMMessage{system, {Role: "user", Content: "another topic"}}})
+	if other.Continuation || other.ConversationID == first.ConversationID {
+		t.Fatalf("unexpected other thread: %+v", other)
+	}
+
+	// 只有系统提示词相同不是同一个会话
+	systemOnly := threader.Track(&LLMRequest{Messages: []LLMMessage{system}})
+	if systemOnly.Continuation {
+		t.Fatalf("system only request should start a conversation: %+v", systemOnly)
+	}
+	if next := threader.Track(&LLMRequest{Messages: []LLMMessage{system, {Role: "user", Content: "unrelated"}}}); next.Continuation {
+		t.Fatalf("system only prefix should not link: %+v", next)
+	}
+
+	// 图片不同的消息不是同一条
+	withImage := []LLMMessage{{Role: "user", Content: "what is this?", Images: []string{"aGVsbG8="}}}
+	image := threader.Track(&LLMRequest{Messages: withImage})
+	otherImage := threader.Track(&LLMRequest{Messages: []LLMMessage{
+		{Role: "user", Content: "what is this?", Images: []string{"d29ybGQ="}},
+		{Role: "assistant", Content: "a world"},
+		{Role: "user", Content: "sure?"},
+	}})
+	if otherImage.Continuation || otherImage.ConversationID == image.ConversationID {
+		t.Fatalf("messages with different images should not link: %+v", otherImage)
+	}
+
+	if threader.Track(&LLMRequest{Prompt: "generate"}) != nil {
+		t.Fatal("generate request should not be threaded")
+	}
+}
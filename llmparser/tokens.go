@@ -0,0 +1,92 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TokenCounter 计算文本的token数，比如tokenizer.Tokenizer
+type TokenCounter interface {
+	Count(text string) int
+}
+
+// approxCounter 没有分词器时按4个字节一个token粗略估计
+type approxCounter struct{}
+
+func (approxCounter) Count(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// messageOverhead 对话模板中每条消息的角色和分隔符大约占用的token数
+const messageOverhead = 4
+
+// PromptTokens 请求各部分估计的token数
+type PromptTokens struct {
+	System   int   // system字段和role为system的消息，system消息同时计入Messages
+	Prompt   int   // generate的prompt
+	Messages []int // 每条消息，包括system消息
+	Tools    int   // 工具定义
+	Total    int
+}
+
+// CountTokens 估计系统提示词、每条消息和工具定义的token数，counter为nil时按字节数粗略估计
+func (r *LLMRequest) CountTokens(counter TokenCounter) *PromptTokens {
+	if counter == nil {
+		counter = approxCounter{}
+	}
+
+	p := &PromptTokens{}
+	if r.System != "" {
+		p.System = counter.Count(r.System) + messageOverhead
+	}
+	if r.Prompt != "" {
+		p.Prompt = counter.Count(r.Prompt) + messageOverhead
+	}
+	for _, msg := range r.Messages {
+		n := counter.Count(msg.Content) + messageOverhead
+		for _, call := range msg.ToolCalls {
+			data, _ := json.Marshal(call.Function.Arguments)
+			n += counter.Count(call.Function.Name + string(data))
+		}
+		p.Messages = append(p.Messages, n)
+		if msg.Role == "system" {
+			p.System += n
+		}
+	}
+	for _, tool := range r.Tools {
+		p.Tools += counter.Count(tool.ToolDefinition())
+	}
+
+	p.Total = p.Prompt + p.Tools
+	if r.System != "" {
+		p.Total += counter.Count(r.System) + messageOverhead
+	}
+	for _, n := range p.Messages {
+		p.Total += n
+	}
+	return p
+}
+
+// String 紧凑的一行显示，如 system 1200, messages 3400 (last 120), tools 900, total 5500
+func (p *PromptTokens) String() string {
+	var items []string
+	if p.System > 0 {
+		items = append(items, fmt.Sprintf("system %d", p.System))
+	}
+	if p.Prompt > 0 {
+		items = append(items, fmt.Sprintf("prompt %d", p.Prompt))
+	}
+	if len(p.Messages) > 0 {
+		sum := 0
+		for _, n := range p.Messages {
+			sum += n
+		}
+		items = append(items, fmt.Sprintf("%d messages %d (last %d)", len(p.Messages), sum, p.Messages[len(p.Messages)-1]))
+	}
+	if p.Tools > 0 {
+		items = append(items, fmt.Sprintf("tools %d", p.Tools))
+	}
+	items = append(items, fmt.Sprintf("total %d", p.Total))
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,40 @@
+package llmparser
+
+import (
+	"strings"
+	"testing"
+)
+
+// wordCounter 按空白切分计数，用于测试
+type wordCounter struct{}
+
+func (wordCounter) Count(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestCountTokens(t *testing.T) {
+	var tool LLMTool
+	tool.Function.Name = "read_file"
+	llmReq := &LLMRequest{
+		Messages: []LLMMessage{
+			{Role: "system", Content: "you are a coding agent"},
+			{Role: "user", Content: "read the file please"},
+		},
+		Tools: []LLMTool{tool},
+	}
+	llmReq.Options = map[string]any{"num_ctx": float64(12)}
+
+	p := llmReq.CountTokens(wordCounter{})
+	if p.System != 5+messageOverhead || len(p.Messages) != 2 || p.Messages[1] != 4+messageOverhead || p.Tools != 1 {
+		t.Fatalf("%+v", p)
+	}
+	if p.Total != 9+2*messageOverhead+1 {
+		t.Fatalf("%+v", p)
+	}
+	if p.String() != "system 9, 2 messages 17 (last 8), tools 1, total 18" {
+		t.Fatal(p.String())
+	}
+	if warning := llmReq.ContextWarning(wordCounter{}); !strings.Contains(warning, "about 18 tokens") {
+		t.Fatal(warning)
+	}
+}
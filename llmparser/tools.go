@@ -0,0 +1,279 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnmarshalJSON 兼容两种工具格式：
+// 1. openai/ollama的 {"type":"function","function":{"name":...,"parameters":...}}
+// 2. anthropic和openai responses的扁平格式 {"name":...,"description":...,"input_schema"/"parameters":...}
+// openai的arguments是json字符串，ollama的是对象，统一解析为对象
+func (t *LLMTool) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Type     string `json:"type"`
+		Function *struct {
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			Parameters  map[string]any  `json:"parameters"`
+			Arguments   json.RawMessage `json:"arguments"`
+		} `json:"function"`
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+		InputSchema map[string]any `json:"input_schema"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	t.Type = aux.Type
+	if aux.Function == nil {
+		t.Function.Name = aux.Name
+		t.Function.Description = aux.Description
+		t.Function.Parameters = aux.Parameters
+		if t.Function.Parameters == nil {
+			t.Function.Parameters = aux.InputSchema
+		}
+		return nil
+	}
+
+	t.Function.Name = aux.Function.Name
+	t.Function.Description = aux.Function.Description
+	t.Function.Parameters = aux.Function.Parameters
+	t.Function.Arguments = nil
+	t.Function.RawArguments = ""
+	args := aux.Function.Arguments
+	var s string
+	if json.Unmarshal(args, &s) == nil {
+		args = []byte(s)
+	}
+	// 流式响应中arguments可能是不完整的json片段，保留原文
+	if json.Unmarshal(args, &t.Function.Arguments) != nil {
+		t.Function.Arguments = nil
+		t.Function.RawArguments = s
+	}
+	return nil
+}
+
+// Signature 工具的签名，可选参数带?，如 read_file(path, offset?)
+func (t *LLMTool) Signature() string {
+	required := requiredSet(t.Function.Parameters)
+	var args []string
+	for _, name := range propertyNames(t.Function.Parameters) {
+		if !required[name] {
+			name += "?"
+		}
+		args = append(args, name)
+	}
+	return fmt.Sprintf("%s(%s)", t.Function.Name, strings.Join(args, ", "))
+}
+
+// Render 将工具定义渲染为便于阅读的文本，参数的json schema显示为树
+func (t *LLMTool) Render() string {
+	var b strings.Builder
+	b.WriteString(t.Signature())
+	b.WriteString("\n")
+	if desc := strings.TrimSpace(t.Function.Description); desc != "" {
+		for _, line := range strings.Split(desc, "\n") {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString(RenderSchema(t.Function.Parameters, "  "))
+	return b.String()
+}
+
+// RenderSchema 将object类型json schema的属性渲染为树，每行以indent开头
+func RenderSchema(schema map[string]any, indent string) string {
+	var b strings.Builder
+	renderProperties(&b, schema, indent)
+	return b.String()
+}
+
+func renderProperties(b *strings.Builder, schema map[string]any, indent string) {
+	properties, _ := schema["properties"].(map[string]any)
+	required := requiredSet(schema)
+	names := propertyNames(schema)
+	for i, name := range names {
+		branch, next := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		property, _ := properties[name].(map[string]any)
+		line := name + ": " + schemaType(property)
+		if required[name] {
+			line += " (required)"
+		}
+		if enum, ok := property["enum"].([]any); ok {
+			line += " enum" + formatValues(enum)
+		}
+		if def, ok := property["default"]; ok {
+			line += fmt.Sprintf(" default=%v", formatValue(def))
+		}
+		if desc, _ := property["description"].(string); desc != "" {
+			line += " - " + firstLine(desc)
+		}
+		b.WriteString(indent + branch + line + "\n")
+
+		// 嵌套的对象和对象数组
+		if _, ok := property["properties"]; ok {
+			renderProperties(b, property, indent+next)
+		} else if items, ok := property["items"].(map[string]any); ok {
+			if _, ok := items["properties"]; ok {
+				renderProperties(b, items, indent+next)
+			}
+		}
+	}
+}
+
+// schemaType 参数类型的简短描述，如 string、array<string>、anyOf[string, null]
+func schemaType(schema map[string]any) string {
+	if schema == nil {
+		return "any"
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		return ref
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		if variants, ok := schema[key].([]any); ok {
+			var types []string
+			for _, v := range variants {
+				m, _ := v.(map[string]any)
+				types = append(types, schemaType(m))
+			}
+			return key + "[" + strings.Join(types, ", ") + "]"
+		}
+	}
+
+	var typ string
+	switch v := schema["type"].(type) {
+	case string:
+		typ = v
+	case []any:
+		var types []string
+		for _, t := range v {
+			types = append(types, fmt.Sprint(t))
+		}
+		typ = strings.Join(types, "|")
+	default:
+		if _, ok := schema["properties"]; ok {
+			typ = "object"
+		} else {
+			typ = "any"
+		}
+	}
+	if typ == "array" {
+		items, _ := schema["items"].(map[string]any)
+		typ += "<" + schemaType(items) + ">"
+	}
+	return typ
+}
+
+// propertyNames 参数名，必填的在前，其余按字母排序
+func propertyNames(schema map[string]any) []string {
+	properties, _ := schema["properties"].(map[string]any)
+	required := requiredSet(schema)
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func requiredSet(schema map[string]any) map[string]bool {
+	required := make(map[string]bool)
+	list, _ := schema["required"].([]any)
+	for _, name := range list {
+		if s, ok := name.(string); ok {
+			required[s] = true
+		}
+	}
+	return required
+}
+
+func formatValue(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func formatValues(values []any) string {
+	var s []string
+	for _, v := range values {
+		s = append(s, formatValue(v))
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if line, _, ok := strings.Cut(s, "\n"); ok {
+		return line + " ..."
+	}
+	return s
+}
+
+// ToolDefinition 工具定义的规范化json，用于判断两个工具是否相同
+func (t *LLMTool) ToolDefinition() string {
+	data, _ := json.Marshal(map[string]any{
+		"name":        t.Function.Name,
+		"description": t.Function.Description,
+		"parameters":  t.Function.Parameters,
+	})
+	return string(data)
+}
+
+// ToolsDiff 两个工具集合之间的变化
+type ToolsDiff struct {
+	Added   []LLMTool // 新增的工具
+	Removed []LLMTool // 删除的工具
+	Changed []LLMTool // 名字相同但定义变化的工具，为新的定义
+}
+
+// Empty 是否没有变化
+func (d *ToolsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffTools 按工具名比较两个工具集合
+func DiffTools(previous, current []LLMTool) ToolsDiff {
+	var diff ToolsDiff
+	oldTools := make(map[string]LLMTool)
+	for _, t := range previous {
+		oldTools[t.Function.Name] = t
+	}
+	newNames := make(map[string]bool)
+	for _, t := range current {
+		newNames[t.Function.Name] = true
+		o, ok := oldTools[t.Function.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, t)
+		case o.ToolDefinition() != t.ToolDefinition():
+			diff.Changed = append(diff.Changed, t)
+		}
+	}
+	for _, t := range previous {
+		if !newNames[t.Function.Name] {
+			diff.Removed = append(diff.Removed, t)
+		}
+	}
+	return diff
+}
+
+// ToolNames 工具名列表
+func ToolNames(tools []LLMTool) []string {
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		names = append(names, t.Function.Name)
+	}
+	return names
+}
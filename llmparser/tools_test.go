@@ -0,0 +1,79 @@
+package llmparser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const weatherTool = `{"type":"function","function":{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","required":["city"],"properties":{
+	"city":{"type":"string","description":"City name"},
+	"unit":{"type":"string","enum":["c","f"],"default":"c"},
+	"days":{"type":"array","items":{"type":"object","properties":{"date":{"type":"string"}}}}}}}}`
+
+func TestToolRender(t *testing.T) {
+	var tool LLMTool
+	if err := json.Unmarshal([]byte(weatherTool), &tool); err != nil {
+		t.Fatal(err)
+	}
+	if tool.Signature() != "get_weather(city, days?, unit?)" {
+		t.Fatal(tool.Signature())
+	}
+	want := `get_weather(city, days?, unit?)
+  Get the weather
+  ├── city: string (required) - City name
+  ├── days: array<object>
+  │   └── date: string
+  └── unit: string enum["c", "f"] default="c"
+`
+	if got := tool.Render(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestToolUnmarshal(t *testing.T) {
+	var tools []LLMTool
+	data := `[{"name":"read_file","description":"Read a file","input_schema":{"type":"object","properties":{"path":{"type":"string"}}}},
+		{"type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+		{"type":"function","function":{"name":"get_weather","arguments":{"city":"Paris"}}}]`
+	if err := json.Unmarshal([]byte(data), &tools); err != nil {
+		t.Fatal(err)
+	}
+	if tools[0].Function.Name != "read_file" || tools[0].Function.Parameters == nil {
+		t.Fatal("flat tool:", tools[0])
+	}
+	for _, tool := range tools[1:] {
+		if tool.Function.Arguments["city"] != "Paris" || tool.Function.RawArguments != "" {
+			t.Fatal("arguments:", tool.Function.Arguments)
+		}
+	}
+
+	// 不完整的json片段保留原文
+	var partial LLMTool
+	if err := json.Unmarshal([]byte(`{"function":{"name":"get_weather","arguments":"{\"city\":"}}`), &partial); err != nil {
+		t.Fatal(err)
+	}
+	if partial.Function.Arguments != nil || partial.Function.RawArguments != `{"city":` {
+		t.Fatalf("partial arguments: %+v", partial.Function)
+	}
+}
+
+func TestDiffTools(t *testing.T) {
+	tool := func(name, desc string) LLMTool {
+		var tool LLMTool
+		tool.Function.Name, tool.Function.Description = name, desc
+		return tool
+	}
+	diff := DiffTools(
+		[]LLMTool{tool("a", "a"), tool("b", "b"), tool("c", "c")},
+		[]LLMTool{tool("a", "a"), tool("b", "b2"), tool("d", "d")},
+	)
+	if strings.Join(ToolNames(diff.Added), ",") != "d" ||
+		strings.Join(ToolNames(diff.Removed), ",") != "c" ||
+		strings.Join(ToolNames(diff.Changed), ",") != "b" {
+		t.Fatalf("%+v", diff)
+	}
+	if diff = DiffTools(diff.Added, diff.Added); !diff.Empty() {
+		t.Fatalf("%+v", diff)
+	}
+}
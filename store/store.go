@@ -0,0 +1,305 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/localdumper/httpdumper"
+	"github.com/LubyRuffy/localdumper/llmparser"
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS exchanges (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	request_id    TEXT    NOT NULL,
+	request_time  INTEGER NOT NULL,
+	response_time INTEGER NOT NULL,
+	client        TEXT    NOT NULL,
+	server        TEXT    NOT NULL,
+	process       TEXT    NOT NULL DEFAULT '',
+	url           TEXT    NOT NULL,
+	model         TEXT    NOT NULL,
+	system        TEXT    NOT NULL DEFAULT '',
+	request       TEXT    NOT NULL,
+	status_code   INTEGER NOT NULL DEFAULT 0,
+	response      TEXT    NOT NULL DEFAULT '',
+	reasoning     TEXT    NOT NULL DEFAULT '',
+	tool_calls    TEXT    NOT NULL DEFAULT '',
+	usage         TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_exchanges_request_time ON exchanges(request_time);
+CREATE INDEX IF NOT EXISTS idx_exchanges_model ON exchanges(model);
+CREATE TABLE IF NOT EXISTS exchange_tools (
+	exchange_id INTEGER NOT NULL REFERENCES exchanges(id) ON DELETE CASCADE,
+	name        TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_exchange_tools_name ON exchange_tools(name);
+`
+
+// Exchange 一次llm调用的记录
+type Exchange struct {
+	ID           int64     `json:"id"`
+	RequestID    string    `json:"request_id"` // httpdumper.Request的ID
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+	Client       string    `json:"client"`  // ip:port
+	Server       string    `json:"server"`  // ip:port
+	Process      string    `json:"process"` // 发起请求的进程
+	URL          string    `json:"url"`
+	Model        string    `json:"model"`
+	System       string    `json:"system"`  // 系统提示词，多个用空行分隔
+	Request      string    `json:"request"` // 原始请求json
+	StatusCode   int       `json:"status_code"`
+	Response     string    `json:"response"` // 重组后的响应
+	Reasoning    string    `json:"reasoning"`
+	ToolCalls    string    `json:"tool_calls"` // 响应中的工具调用，json
+	Usage        string    `json:"usage"`      // token用量，json
+	Tools        []string  `json:"tools"`      // 请求中声明的工具名
+}
+
+// Duration 请求到响应完成的耗时
+func (e *Exchange) Duration() time.Duration {
+	return e.ResponseTime.Sub(e.RequestTime)
+}
+
+// Metrics 解析保存的token用量，没有时返回nil
+func (e *Exchange) Metrics() *llmparser.Metrics {
+	if e.Usage == "" {
+		return nil
+	}
+	var m llmparser.Metrics
+	if err := json.Unmarshal([]byte(e.Usage), &m); err != nil {
+		return nil
+	}
+	return &m
+}
+
+// Store 基于sqlite的llm调用记录存储
+type Store struct {
+	db *sql.DB
+}
+
+// Open 打开数据库，不存在则创建
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite写操作不能并发
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init schema failed: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save 保存一次调用，成功后会设置e.ID
+func (s *Store) Save(e *Exchange) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO exchanges (request_id, request_time, response_time, client, server, process,
+		url, model, system, request, status_code, response, reasoning, tool_calls, usage)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.RequestID, e.RequestTime.UnixNano(), e.ResponseTime.UnixNano(), e.Client, e.Server, e.Process,
+		e.URL, e.Model, e.System, e.Request, e.StatusCode, e.Response, e.Reasoning, e.ToolCalls, e.Usage)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range e.Tools {
+		if _, err = tx.Exec(`INSERT INTO exchange_tools (exchange_id, name) VALUES (?, ?)`, id, name); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// Query 查询条件，空值表示不限制
+type Query struct {
+	Model  string    // 模型名，包含即可
+	System string    // 系统提示词包含的文本
+	Text   string    // 请求或者响应包含的文本
+	Tool   string    // 请求中声明了这个工具
+	Since  time.Time // 请求时间下限
+	Until  time.Time // 请求时间上限
+	Limit  int       // 默认100
+}
+
+// likeEscaper 转义LIKE中的通配符，配合 ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern 包含s的LIKE模式
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+const selectColumns = `id, request_id, request_time, response_time, client, server, process, url, model, system,
+	request, status_code, response, reasoning, tool_calls, usage`
+
+// Search 按条件查询，按请求时间倒序
+func (s *Store) Search(q *Query) ([]*Exchange, error) {
+	var where []string
+	var args []any
+	if q.Model != "" {
+		where = append(where, `model LIKE ? ESCAPE '\'`)
+		args = append(args, containsPattern(q.Model))
+	}
+	if q.System != "" {
+		where = append(where, `system LIKE ? ESCAPE '\'`)
+		args = append(args, containsPattern(q.System))
+	}
+	if q.Text != "" {
+		where = append(where, `(request LIKE ? ESCAPE '\' OR response LIKE ? ESCAPE '\')`)
+		args = append(args, containsPattern(q.Text), containsPattern(q.Text))
+	}
+	if q.Tool != "" {
+		where = append(where, "id IN (SELECT exchange_id FROM exchange_tools WHERE name = ?)")
+		args = append(args, q.Tool)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "request_time >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "request_time <= ?")
+		args = append(args, q.Until.UnixNano())
+	}
+
+	query := "SELECT " + selectColumns + " FROM exchanges"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += " ORDER BY request_time DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exchanges []*Exchange
+	for rows.Next() {
+		e, err := scanExchange(rows)
+		if err != nil {
+			return nil, err
+		}
+		exchanges = append(exchanges, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range exchanges {
+		if e.Tools, err = s.tools(e.ID); err != nil {
+			return nil, err
+		}
+	}
+	return exchanges, nil
+}
+
+// Get 根据ID获取一次调用
+func (s *Store) Get(id int64) (*Exchange, error) {
+	e, err := scanExchange(s.db.QueryRow("SELECT "+selectColumns+" FROM exchanges WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if e.Tools, err = s.tools(e.ID); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *Store) tools(id int64) ([]string, error) {
+	rows, err := s.db.Query("SELECT name FROM exchange_tools WHERE exchange_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tools []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tools = append(tools, name)
+	}
+	return tools, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExchange(row scanner) (*Exchange, error) {
+	var e Exchange
+	var requestTime, responseTime int64
+	if err := row.Scan(&e.ID, &e.RequestID, &requestTime, &responseTime, &e.Client, &e.Server, &e.Process,
+		&e.URL, &e.Model, &e.System, &e.Request, &e.StatusCode, &e.Response, &e.Reasoning, &e.ToolCalls, &e.Usage); err != nil {
+		return nil, err
+	}
+	e.RequestTime = time.Unix(0, requestTime)
+	e.ResponseTime = time.Unix(0, responseTime)
+	return &e, nil
+}
+
+// NewExchange 从一次llm调用创建记录，response、reasoning、toolCalls和metrics是重组后的响应内容
+func NewExchange(resp *httpdumper.Response, llmReq *llmparser.LLMRequest, response, reasoning string, toolCalls []llmparser.LLMTool, metrics *llmparser.Metrics) *Exchange {
+	req := resp.Request
+	e := &Exchange{
+		RequestID:    req.ID,
+		RequestTime:  req.Time,
+		ResponseTime: resp.Time,
+		Client:       req.Net.Src().String() + ":" + req.Transport.Src().String(),
+		Server:       req.Net.Dst().String() + ":" + req.Transport.Dst().String(),
+		URL:          req.URL.String(),
+		Model:        llmReq.Model,
+		Request:      string(req.Body),
+		StatusCode:   resp.StatusCode,
+		Response:     response,
+		Reasoning:    reasoning,
+		Usage:        metrics.JSON(),
+	}
+	if req.Process != nil {
+		e.Process = req.Process.Name
+	}
+
+	e.System = strings.Join(llmReq.SystemPrompts(), "\n\n")
+
+	for _, tool := range llmReq.Tools {
+		e.Tools = append(e.Tools, tool.Function.Name)
+	}
+	if len(toolCalls) > 0 {
+		data, _ := json.Marshal(toolCalls)
+		e.ToolCalls = string(data)
+	}
+	return e
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveAndSearch(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	exchanges := []*Exchange{
+		{RequestID: "1", RequestTime: now.Add(-2 * time.Hour), ResponseTime: now.Add(-2 * time.Hour), Model: "qwen3:0.6b",
+			System: "You are a coding agent", Request: `{"model":"qwen3:0.6b"}`, Response: "hello", Tools: []string{"read_file", "write_file"}},
+		{RequestID: "2", RequestTime: now, ResponseTime: now.Add(time.Second), Model: "llama3",
+			System: "You are a helpful assistant", Request: `{"model":"llama3"}`, Response: "world", Usage: `{"prompt_tokens":12,"completion_tokens":3}`},
+	}
+	for _, e := range exchanges {
+		if err = s.Save(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		q   Query
+		ids []string
+	}{
+		{Query{}, []string{"2", "1"}},
+		{Query{Model: "qwen"}, []string{"1"}},
+		{Query{System: "helpful"}, []string{"2"}},
+		{Query{Tool: "read_file"}, []string{"1"}},
+		{Query{Tool: "read"}, nil},
+		{Query{Since: now.Add(-time.Hour)}, []string{"2"}},
+		{Query{Until: now.Add(-time.Hour)}, []string{"1"}},
+		{Query{Text: "world"}, []string{"2"}},
+		{Query{Limit: 1}, []string{"2"}},
+		{Query{Model: "qwen3_0"}, nil},
+		{Query{Model: "%"}, nil},
+	}
+	for _, c := range cases {
+		result, err := s.Search(&c.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != len(c.ids) {
+			t.Fatalf("%+v: expected %v, got %d results", c.q, c.ids, len(result))
+		}
+		for i := range result {
+			if result[i].RequestID != c.ids[i] {
+				t.Fatalf("%+v: expected %v, got %s at %d", c.q, c.ids, result[i].RequestID, i)
+			}
+		}
+	}
+
+	e, err := s.Get(exchanges[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Tools) != 2 || e.Duration() != 0 || !e.RequestTime.Equal(exchanges[0].RequestTime) {
+		t.Fatalf("unexpected exchange: %+v", e)
+	}
+	if e.Metrics() != nil {
+		t.Fatal("exchange without usage should have no metrics")
+	}
+	if e, err = s.Get(exchanges[1].ID); err != nil || e.Metrics() == nil || e.Metrics().PromptTokens != 12 {
+		t.Fatal(err, e)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWriter(s, 16)
+	for i := 0; i < 10; i++ {
+		w.Save(&Exchange{RequestID: "1", RequestTime: time.Now(), Model: "qwen3"})
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w.Save(&Exchange{RequestID: "2"})
+	if w.Dropped() != 1 {
+		t.Fatalf("save after close should be dropped: %d", w.Dropped())
+	}
+
+	if s, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	result, err := s.Search(&Query{})
+	if err != nil || len(result) != 10 {
+		t.Fatal(err, len(result))
+	}
+}
+
+func TestWriterWaitsWhenFull(t *testing.T) {
+	// 没有写入的goroutine，手动消费队列
+	w := &Writer{queue: make(chan *Exchange, 1), done: make(chan struct{})}
+	w.Save(&Exchange{RequestID: "1"})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		<-w.queue
+	}()
+	w.Save(&Exchange{RequestID: "2"})
+	if w.Dropped() != 0 {
+		t.Fatal("save should wait for the queue instead of dropping")
+	}
+
+	start := time.Now()
+	w.Save(&Exchange{RequestID: "3"})
+	if w.Dropped() != 1 || time.Since(start) < saveTimeout {
+		t.Fatalf("save should be dropped after %s: %d", saveTimeout, w.Dropped())
+	}
+}
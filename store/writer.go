@@ -0,0 +1,84 @@
+package store
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// saveTimeout 队列满了之后最多等待多久，超时后丢弃，避免数据库一直卡住时阻塞抓包
+const saveTimeout = time.Second
+
+// Writer 在单独的goroutine中按顺序保存，避免sqlite写入阻塞抓包
+type Writer struct {
+	store   *Store
+	queue   chan *Exchange
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// NewWriter 创建异步写入器，size是队列长度，满了之后最多等待saveTimeout
+func NewWriter(s *Store, size int) *Writer {
+	if size < 1 {
+		size = 1
+	}
+	w := &Writer{
+		store: s,
+		queue: make(chan *Exchange, size),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(w.done)
+		for e := range w.queue {
+			if err := w.store.Save(e); err != nil {
+				log.Println("save exchange failed:", err)
+			}
+		}
+	}()
+	return w
+}
+
+// Save 放入队列，不等待写入完成，队列满了时等待写入跟上
+func (w *Writer) Save(e *Exchange) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return
+	}
+	select {
+	case w.queue <- e:
+		return
+	default:
+	}
+	timer := time.NewTimer(saveTimeout)
+	defer timer.Stop()
+	select {
+	case w.queue <- e:
+	case <-timer.C:
+		if w.dropped.Add(1) == 1 {
+			log.Println("database is too slow, exchanges are dropped")
+		}
+	}
+}
+
+// Dropped 队列满了或者关闭后丢弃的记录数
+func (w *Writer) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close 等待队列中的记录写完，然后关闭数据库
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mutex.Unlock()
+	<-w.done
+	return w.store.Close()
+}
@@ -0,0 +1,306 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseModel 支持BPE、Unigram、WordPiece
+func parseModel(data json.RawMessage) (model, error) {
+	var cfg struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	switch cfg.Type {
+	case "BPE", "":
+		return parseBPE(data)
+	case "Unigram":
+		return parseUnigram(data)
+	case "WordPiece":
+		return parseWordPiece(data)
+	}
+	return nil, fmt.Errorf("unsupported model type: %s", cfg.Type)
+}
+
+// byteFallbackIDs sentencepiece的byte_fallback，未知字符按utf8字节编码为<0xXX>
+func byteFallbackIDs(vocab map[string]int, s string) ([]int, bool) {
+	var ids []int
+	for _, b := range []byte(s) {
+		id, ok := vocab[fmt.Sprintf("<0x%02X>", b)]
+		if !ok {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// bpe 字节对编码
+type bpe struct {
+	vocab        map[string]int
+	ranks        map[string]int // "a\x00b" -> 合并优先级
+	unkID        int            // -1表示没有unk
+	byteFallback bool
+	ignoreMerges bool
+}
+
+func parseBPE(data json.RawMessage) (*bpe, error) {
+	var cfg struct {
+		Vocab        map[string]int    `json:"vocab"`
+		Merges       []json.RawMessage `json:"merges"`
+		UnkToken     *string           `json:"unk_token"`
+		ByteFallback bool              `json:"byte_fallback"`
+		IgnoreMerges bool              `json:"ignore_merges"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Vocab) == 0 {
+		return nil, errors.New("empty vocab")
+	}
+
+	m := &bpe{
+		vocab:        cfg.Vocab,
+		ranks:        make(map[string]int, len(cfg.Merges)),
+		unkID:        -1,
+		byteFallback: cfg.ByteFallback,
+		ignoreMerges: cfg.IgnoreMerges,
+	}
+	if cfg.UnkToken != nil {
+		if id, ok := cfg.Vocab[*cfg.UnkToken]; ok {
+			m.unkID = id
+		}
+	}
+	// merges有"a b"和["a","b"]两种格式
+	for rank, raw := range cfg.Merges {
+		var pair []string
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			a, b, ok := strings.Cut(s, " ")
+			if !ok {
+				return nil, fmt.Errorf("invalid merge: %s", s)
+			}
+			pair = []string{a, b}
+		} else if err = json.Unmarshal(raw, &pair); err != nil || len(pair) != 2 {
+			return nil, fmt.Errorf("invalid merge: %s", raw)
+		}
+		m.ranks[pair[0]+"\x00"+pair[1]] = rank
+	}
+	return m, nil
+}
+
+func (m *bpe) encode(piece string) []int {
+	if id, ok := m.vocab[piece]; ok && m.ignoreMerges {
+		return []int{id}
+	}
+
+	symbols := make([]string, 0, utf8.RuneCountInString(piece))
+	for _, r := range piece {
+		symbols = append(symbols, string(r))
+	}
+	// 每次合并优先级最高的相邻对
+	for len(symbols) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(symbols)-1; i++ {
+			if rank, ok := m.ranks[symbols[i]+"\x00"+symbols[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		a, b := symbols[best], symbols[best+1]
+		next := make([]string, 0, len(symbols)-1)
+		for i := 0; i < len(symbols); i++ {
+			if i < len(symbols)-1 && symbols[i] == a && symbols[i+1] == b {
+				next = append(next, a+b)
+				i++
+			} else {
+				next = append(next, symbols[i])
+			}
+		}
+		symbols = next
+	}
+
+	ids := make([]int, 0, len(symbols))
+	for _, s := range symbols {
+		if id, ok := m.vocab[s]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		if m.byteFallback {
+			if fallback, ok := byteFallbackIDs(m.vocab, s); ok {
+				ids = append(ids, fallback...)
+				continue
+			}
+		}
+		if m.unkID >= 0 {
+			ids = append(ids, m.unkID)
+		}
+	}
+	return ids
+}
+
+// unigram sentencepiece的unigram模型，使用viterbi算法找到得分最高的切分
+type unigram struct {
+	scores       map[string]float64
+	ids          map[string]int
+	maxLen       int // 最长的词的字符数
+	unkID        int
+	unkScore     float64
+	byteFallback bool
+}
+
+func parseUnigram(data json.RawMessage) (*unigram, error) {
+	var cfg struct {
+		UnkID        *int    `json:"unk_id"`
+		Vocab        [][]any `json:"vocab"`
+		ByteFallback bool    `json:"byte_fallback"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Vocab) == 0 {
+		return nil, errors.New("empty vocab")
+	}
+
+	m := &unigram{
+		scores:       make(map[string]float64, len(cfg.Vocab)),
+		ids:          make(map[string]int, len(cfg.Vocab)),
+		unkID:        -1,
+		byteFallback: cfg.ByteFallback,
+	}
+	minScore := 0.0
+	for id, item := range cfg.Vocab {
+		if len(item) != 2 {
+			return nil, fmt.Errorf("invalid vocab item: %v", item)
+		}
+		piece, _ := item[0].(string)
+		score, _ := item[1].(float64)
+		m.scores[piece] = score
+		m.ids[piece] = id
+		m.maxLen = max(m.maxLen, utf8.RuneCountInString(piece))
+		minScore = min(minScore, score)
+	}
+	if cfg.UnkID != nil {
+		m.unkID = *cfg.UnkID
+	}
+	m.unkScore = minScore - 10
+	return m, nil
+}
+
+func (m *unigram) encode(piece string) []int {
+	runes := []rune(piece)
+	n := len(runes)
+	// best[i] 前i个字符的最高得分，from[i] 最后一个词的开始位置
+	best := make([]float64, n+1)
+	from := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(-1)
+	}
+	for i := 0; i < n; i++ {
+		if math.IsInf(best[i], -1) {
+			continue
+		}
+		for j := i + 1; j <= n && j-i <= m.maxLen; j++ {
+			if score, ok := m.scores[string(runes[i:j])]; ok && best[i]+score > best[j] {
+				best[j], from[j] = best[i]+score, i
+			}
+		}
+		// 未知字符
+		if best[i]+m.unkScore > best[i+1] {
+			best[i+1], from[i+1] = best[i]+m.unkScore, i
+		}
+	}
+
+	var pieces []string
+	for j := n; j > 0; j = from[j] {
+		pieces = append(pieces, string(runes[from[j]:j]))
+	}
+	ids := make([]int, 0, len(pieces))
+	for k := len(pieces) - 1; k >= 0; k-- {
+		if id, ok := m.ids[pieces[k]]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		if m.byteFallback {
+			if fallback, ok := byteFallbackIDs(m.ids, pieces[k]); ok {
+				ids = append(ids, fallback...)
+				continue
+			}
+		}
+		if m.unkID >= 0 {
+			ids = append(ids, m.unkID)
+		}
+	}
+	return ids
+}
+
+// wordPiece bert的wordpiece模型，贪心最长匹配
+type wordPiece struct {
+	vocab    map[string]int
+	unkID    int
+	prefix   string
+	maxChars int
+}
+
+func parseWordPiece(data json.RawMessage) (*wordPiece, error) {
+	var cfg struct {
+		Vocab                   map[string]int `json:"vocab"`
+		UnkToken                string         `json:"unk_token"`
+		ContinuingSubwordPrefix string         `json:"continuing_subword_prefix"`
+		MaxInputCharsPerWord    int            `json:"max_input_chars_per_word"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Vocab) == 0 {
+		return nil, errors.New("empty vocab")
+	}
+	m := &wordPiece{vocab: cfg.Vocab, unkID: -1, prefix: cfg.ContinuingSubwordPrefix, maxChars: cfg.MaxInputCharsPerWord}
+	if id, ok := cfg.Vocab[cfg.UnkToken]; ok {
+		m.unkID = id
+	}
+	if m.maxChars <= 0 {
+		m.maxChars = 100
+	}
+	return m, nil
+}
+
+func (m *wordPiece) encode(piece string) []int {
+	runes := []rune(piece)
+	unk := []int{}
+	if m.unkID >= 0 {
+		unk = []int{m.unkID}
+	}
+	if len(runes) > m.maxChars {
+		return unk
+	}
+
+	var ids []int
+	for start := 0; start < len(runes); {
+		end, id := len(runes), -1
+		for ; end > start; end-- {
+			sub := string(runes[start:end])
+			if start > 0 {
+				sub = m.prefix + sub
+			}
+			if v, ok := m.vocab[sub]; ok {
+				id = v
+				break
+			}
+		}
+		if id < 0 {
+			return unk
+		}
+		ids = append(ids, id)
+		start = end
+	}
+	return ids
+}
@@ -0,0 +1,95 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// normalizer 分词前的文本规范化
+type normalizer interface {
+	normalize(text string) string
+}
+
+type normalizerSequence []normalizer
+
+func (s normalizerSequence) normalize(text string) string {
+	for _, n := range s {
+		text = n.normalize(text)
+	}
+	return text
+}
+
+type normalizerFunc func(string) string
+
+func (f normalizerFunc) normalize(text string) string {
+	return f(text)
+}
+
+// parseNormalizer 支持Sequence、Prepend、Replace、Lowercase、Strip、BertNormalizer，unicode规范化（NFC等）忽略
+func parseNormalizer(data json.RawMessage) (normalizer, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var cfg struct {
+		Type        string            `json:"type"`
+		Normalizers []json.RawMessage `json:"normalizers"`
+		Prepend     string            `json:"prepend"`
+		Pattern     struct {
+			String string `json:"String"`
+		} `json:"pattern"`
+		Content    string `json:"content"`
+		Lowercase  bool   `json:"lowercase"`
+		StripLeft  bool   `json:"strip_left"`
+		StripRight bool   `json:"strip_right"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case "Sequence":
+		var seq normalizerSequence
+		for _, raw := range cfg.Normalizers {
+			n, err := parseNormalizer(raw)
+			if err != nil {
+				return nil, err
+			}
+			if n != nil {
+				seq = append(seq, n)
+			}
+		}
+		return seq, nil
+	case "Prepend":
+		return normalizerFunc(func(s string) string {
+			if s == "" {
+				return s
+			}
+			return cfg.Prepend + s
+		}), nil
+	case "Replace":
+		if cfg.Pattern.String == "" {
+			return nil, nil
+		}
+		return normalizerFunc(func(s string) string {
+			return strings.ReplaceAll(s, cfg.Pattern.String, cfg.Content)
+		}), nil
+	case "Lowercase":
+		return normalizerFunc(strings.ToLower), nil
+	case "BertNormalizer":
+		if cfg.Lowercase {
+			return normalizerFunc(strings.ToLower), nil
+		}
+		return nil, nil
+	case "Strip":
+		return normalizerFunc(func(s string) string {
+			if cfg.StripLeft {
+				s = strings.TrimLeft(s, " \t\r\n")
+			}
+			if cfg.StripRight {
+				s = strings.TrimRight(s, " \t\r\n")
+			}
+			return s
+		}), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,296 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// preTokenizer 把文本切分为片段，每个片段单独分词
+type preTokenizer interface {
+	split(pieces []string) []string
+}
+
+type preTokenizerSequence []preTokenizer
+
+func (s preTokenizerSequence) split(pieces []string) []string {
+	for _, p := range s {
+		pieces = p.split(pieces)
+	}
+	return pieces
+}
+
+// parsePreTokenizer 支持Sequence、ByteLevel、Metaspace、Split、Digits、Whitespace、WhitespaceSplit、BertPreTokenizer
+func parsePreTokenizer(data json.RawMessage) (preTokenizer, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var cfg struct {
+		Type           string            `json:"type"`
+		PreTokenizers  []json.RawMessage `json:"pretokenizers"`
+		AddPrefixSpace bool              `json:"add_prefix_space"`
+		UseRegex       *bool             `json:"use_regex"`
+		Replacement    string            `json:"replacement"`
+		PrependScheme  string            `json:"prepend_scheme"`
+		Split          *bool             `json:"split"`
+		Pattern        struct {
+			String string `json:"String"`
+			Regex  string `json:"Regex"`
+		} `json:"pattern"`
+		Behavior         string `json:"behavior"`
+		IndividualDigits bool   `json:"individual_digits"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case "Sequence":
+		var seq preTokenizerSequence
+		for _, raw := range cfg.PreTokenizers {
+			p, err := parsePreTokenizer(raw)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil {
+				seq = append(seq, p)
+			}
+		}
+		return seq, nil
+	case "ByteLevel":
+		return byteLevel{addPrefixSpace: cfg.AddPrefixSpace, useRegex: cfg.UseRegex == nil || *cfg.UseRegex}, nil
+	case "Metaspace":
+		m := metaspace{replacement: cfg.Replacement, splitSpace: cfg.Split == nil || *cfg.Split}
+		m.prepend = cfg.PrependScheme == "always" || cfg.PrependScheme == "first" || (cfg.PrependScheme == "" && cfg.AddPrefixSpace)
+		if m.replacement == "" {
+			m.replacement = "▁"
+		}
+		return m, nil
+	case "Split":
+		if cfg.Pattern.String != "" {
+			return literalSplit{pattern: cfg.Pattern.String, removed: cfg.Behavior == "Removed"}, nil
+		}
+		// 正则中的零宽断言go不支持，统一使用llama3/qwen风格的切分规则
+		return preTokenizerFunc(splitWords), nil
+	case "Digits":
+		if cfg.IndividualDigits {
+			return preTokenizerFunc(splitDigits), nil
+		}
+		return nil, nil
+	case "WhitespaceSplit":
+		return preTokenizerFunc(strings.Fields), nil
+	case "Whitespace", "BertPreTokenizer":
+		return preTokenizerFunc(func(s string) []string { return wordPunct.FindAllString(s, -1) }), nil
+	}
+	return nil, nil
+}
+
+var wordPunct = regexp.MustCompile(`\w+|[^\w\s]+`)
+
+// preTokenizerFunc 对每个片段分别切分
+type preTokenizerFunc func(string) []string
+
+func (f preTokenizerFunc) split(pieces []string) []string {
+	var result []string
+	for _, piece := range pieces {
+		result = append(result, f(piece)...)
+	}
+	return result
+}
+
+// byteLevel gpt2风格的字节级预分词，每个字节映射为一个可见字符
+type byteLevel struct {
+	addPrefixSpace bool
+	useRegex       bool
+}
+
+var byteToRune = func() [256]rune {
+	var table [256]rune
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			table[b] = rune(b)
+		} else {
+			table[b] = rune(256 + n)
+			n++
+		}
+	}
+	return table
+}()
+
+func (b byteLevel) split(pieces []string) []string {
+	var result []string
+	for i, piece := range pieces {
+		if b.addPrefixSpace && i == 0 && !strings.HasPrefix(piece, " ") {
+			piece = " " + piece
+		}
+		words := []string{piece}
+		if b.useRegex {
+			words = splitWords(piece)
+		}
+		for _, word := range words {
+			var sb strings.Builder
+			for _, c := range []byte(word) {
+				sb.WriteRune(byteToRune[c])
+			}
+			result = append(result, sb.String())
+		}
+	}
+	return result
+}
+
+// metaspace sentencepiece风格，空格替换为▁，并在▁之前切分
+type metaspace struct {
+	replacement string
+	prepend     bool
+	splitSpace  bool // 是否在▁之前切分
+}
+
+func (m metaspace) split(pieces []string) []string {
+	var result []string
+	for i, piece := range pieces {
+		piece = strings.ReplaceAll(piece, " ", m.replacement)
+		if m.prepend && i == 0 && !strings.HasPrefix(piece, m.replacement) {
+			piece = m.replacement + piece
+		}
+		if !m.splitSpace {
+			result = append(result, piece)
+			continue
+		}
+		// 在每个▁之前切分
+		for piece != "" {
+			next := strings.Index(piece[1:], m.replacement)
+			if next < 0 {
+				result = append(result, piece)
+				break
+			}
+			result = append(result, piece[:next+1])
+			piece = piece[next+1:]
+		}
+	}
+	return result
+}
+
+// literalSplit 按固定字符串切分，分隔符单独作为一个片段或者去掉
+type literalSplit struct {
+	pattern string
+	removed bool
+}
+
+func (l literalSplit) split(pieces []string) []string {
+	var result []string
+	for _, piece := range pieces {
+		parts := strings.Split(piece, l.pattern)
+		for i, part := range parts {
+			if i > 0 && !l.removed {
+				result = append(result, l.pattern)
+			}
+			if part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+func splitDigits(s string) []string {
+	var result []string
+	start := 0
+	for i, r := range s {
+		if unicode.IsDigit(r) {
+			if start < i {
+				result = append(result, s[start:i])
+			}
+			result = append(result, string(r))
+			start = i + len(string(r))
+		}
+	}
+	if start < len(s) {
+		result = append(result, s[start:])
+	}
+	return result
+}
+
+func isLetter(r rune) bool  { return unicode.IsLetter(r) }
+func isNumber(r rune) bool  { return unicode.IsNumber(r) }
+func isNewline(r rune) bool { return r == '\r' || r == '\n' }
+func isPunct(r rune) bool   { return !unicode.IsSpace(r) && !isLetter(r) && !isNumber(r) }
+
+// splitWords 手写实现的llama3/qwen预分词正则：
+// (?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitWords(s string) []string {
+	runes := []rune(s)
+	n := len(runes)
+	var words []string
+	for i := 0; i < n; {
+		j := matchWord(runes, i)
+		words = append(words, string(runes[i:j]))
+		i = j
+	}
+	return words
+}
+
+// matchWord 返回从i开始的片段的结束位置
+func matchWord(runes []rune, i int) int {
+	n := len(runes)
+	r := runes[i]
+
+	// 缩写
+	if r == '\'' && i+1 < n {
+		for _, suffix := range []string{"re", "ve", "ll", "s", "t", "m", "d"} {
+			end := i + 1 + len(suffix)
+			if end <= n && strings.EqualFold(string(runes[i+1:end]), suffix) {
+				return end
+			}
+		}
+	}
+
+	// 可选的一个非字母数字字符加上字母
+	if isLetter(r) || (!isNewline(r) && !isNumber(r) && i+1 < n && isLetter(runes[i+1])) {
+		j := i + 1
+		for j < n && isLetter(runes[j]) {
+			j++
+		}
+		return j
+	}
+
+	// 最多3个数字
+	if isNumber(r) {
+		j := i + 1
+		for j < n && j < i+3 && isNumber(runes[j]) {
+			j++
+		}
+		return j
+	}
+
+	// 可选的空格加上标点，以及之后的换行
+	if isPunct(r) || (r == ' ' && i+1 < n && isPunct(runes[i+1])) {
+		j := i + 1
+		for j < n && isPunct(runes[j]) {
+			j++
+		}
+		for j < n && isNewline(runes[j]) {
+			j++
+		}
+		return j
+	}
+
+	// 空白
+	j := i
+	lastNewline := -1
+	for j < n && unicode.IsSpace(runes[j]) {
+		if isNewline(runes[j]) {
+			lastNewline = j
+		}
+		j++
+	}
+	switch {
+	case lastNewline >= 0:
+		return lastNewline + 1
+	case j < n && j-i > 1:
+		// 最后一个空格留给后面的单词
+		return j - 1
+	}
+	return j
+}
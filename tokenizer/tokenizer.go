@@ -0,0 +1,144 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// model 分词模型：BPE、Unigram、WordPiece
+type model interface {
+	encode(piece string) []int
+}
+
+// addedToken tokenizer.json中的added_tokens，在分词之前按字面匹配
+type addedToken struct {
+	ID      int    `json:"id"`
+	Content string `json:"content"`
+	Special bool   `json:"special"`
+}
+
+// Tokenizer 从huggingface的tokenizer.json加载的分词器，纯go实现，用于估计token数
+// 支持BPE（包括ByteLevel和SentencePiece的byte_fallback）、Unigram、WordPiece模型，
+// 预分词的正则统一使用llama3/qwen风格的规则，所以结果和官方实现可能有少量差别
+type Tokenizer struct {
+	added        []addedToken // 按长度倒序
+	normalizer   normalizer
+	preTokenizer preTokenizer
+	model        model
+
+	mutex sync.Mutex
+	cache map[string][]int // 预分词后的片段 -> token
+}
+
+const maxCacheSize = 100000
+
+// Load 从tokenizer.json文件加载
+func Load(path string) (*Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse 解析tokenizer.json的内容
+func Parse(data []byte) (*Tokenizer, error) {
+	var file struct {
+		AddedTokens  []addedToken    `json:"added_tokens"`
+		Normalizer   json.RawMessage `json:"normalizer"`
+		PreTokenizer json.RawMessage `json:"pre_tokenizer"`
+		Model        json.RawMessage `json:"model"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	t := &Tokenizer{added: file.AddedTokens, cache: make(map[string][]int)}
+	sort.SliceStable(t.added, func(i, j int) bool {
+		return len(t.added[i].Content) > len(t.added[j].Content)
+	})
+
+	var err error
+	if t.normalizer, err = parseNormalizer(file.Normalizer); err != nil {
+		return nil, fmt.Errorf("normalizer: %w", err)
+	}
+	if t.preTokenizer, err = parsePreTokenizer(file.PreTokenizer); err != nil {
+		return nil, fmt.Errorf("pre_tokenizer: %w", err)
+	}
+	if t.model, err = parseModel(file.Model); err != nil {
+		return nil, fmt.Errorf("model: %w", err)
+	}
+	// 没有预分词的sentencepiece模型（比如llama2）整段文本作为一个片段，按▁切分，避免超长片段的BPE
+	if t.preTokenizer == nil {
+		t.preTokenizer = metaspace{replacement: "▁", splitSpace: true}
+	}
+	return t, nil
+}
+
+// Encode 分词，返回token id
+func (t *Tokenizer) Encode(text string) []int {
+	var ids []int
+	for text != "" {
+		// 找到最早出现的added token，长的优先
+		index, token := -1, addedToken{}
+		for _, added := range t.added {
+			if added.Content == "" {
+				continue
+			}
+			if i := strings.Index(text, added.Content); i >= 0 && (index < 0 || i < index) {
+				index, token = i, added
+			}
+		}
+		if index < 0 {
+			ids = append(ids, t.encodeText(text)...)
+			break
+		}
+		ids = append(ids, t.encodeText(text[:index])...)
+		ids = append(ids, token.ID)
+		text = text[index+len(token.Content):]
+	}
+	return ids
+}
+
+// Count token数
+func (t *Tokenizer) Count(text string) int {
+	return len(t.Encode(text))
+}
+
+func (t *Tokenizer) encodeText(text string) []int {
+	if text == "" {
+		return nil
+	}
+	if t.normalizer != nil {
+		text = t.normalizer.normalize(text)
+	}
+
+	var ids []int
+	for _, piece := range t.preTokenizer.split([]string{text}) {
+		ids = append(ids, t.encodePiece(piece)...)
+	}
+	return ids
+}
+
+func (t *Tokenizer) encodePiece(piece string) []int {
+	t.mutex.Lock()
+	ids, ok := t.cache[piece]
+	t.mutex.Unlock()
+	if ok {
+		return ids
+	}
+
+	ids = t.model.encode(piece)
+
+	t.mutex.Lock()
+	if len(t.cache) >= maxCacheSize {
+		t.cache = make(map[string][]int)
+	}
+	t.cache[piece] = ids
+	t.mutex.Unlock()
+	return ids
+}
@@ -0,0 +1,65 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	got := splitWords("Hello world's  test 12345\n\n  foo!!")
+	want := []string{"Hello", " world", "'s", " ", " test", " ", "123", "45", "\n\n", " ", " foo", "!!"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		text string
+		ids  []int
+	}{
+		{"byte level bpe", `{
+			"added_tokens":[{"id":17,"content":"<|im_start|>","special":true}],
+			"normalizer":null,
+			"pre_tokenizer":{"type":"Sequence","pretokenizers":[
+				{"type":"Split","pattern":{"Regex":"..."},"behavior":"Isolated","invert":false},
+				{"type":"ByteLevel","add_prefix_space":false,"trim_offsets":false,"use_regex":false}]},
+			"model":{"type":"BPE","vocab":{"h":0,"e":1,"l":2,"o":3,"Ġ":4,"w":5,"r":6,"d":7,"he":8,"ll":9,"hell":10,"hello":11,"Ġw":12,"or":13,"Ġwor":14,"Ġworl":15,"Ġworld":16,"!":18},
+				"merges":["h e","l l","he ll","hell o",["Ġ","w"],"o r","Ġw or","Ġwor l","Ġworl d"]}
+		}`, "<|im_start|>hello world!", []int{17, 11, 16, 18}},
+		{"sentencepiece bpe", `{
+			"normalizer":{"type":"Sequence","normalizers":[{"type":"Prepend","prepend":"▁"},{"type":"Replace","pattern":{"String":" "},"content":"▁"}]},
+			"pre_tokenizer":null,
+			"model":{"type":"BPE","unk_token":"<unk>","byte_fallback":true,
+				"vocab":{"<unk>":0,"<0xE4>":1,"<0xBD>":2,"<0xA0>":3,"▁":4,"▁h":5,"i":6,"▁hi":7},
+				"merges":["▁ h","▁h i"]}
+		}`, "hi 你", []int{7, 4, 1, 2, 3}},
+		{"unigram", `{
+			"pre_tokenizer":{"type":"Metaspace","replacement":"▁","prepend_scheme":"always","split":true},
+			"model":{"type":"Unigram","unk_id":0,"vocab":[["<unk>",0],["▁",-2],["▁hello",-1],["hel",-3],["lo",-3],["▁world",-1.5]]}
+		}`, "hello world xy", []int{2, 5, 1, 0, 0}},
+		{"wordpiece", `{
+			"normalizer":{"type":"BertNormalizer","lowercase":true},
+			"pre_tokenizer":{"type":"BertPreTokenizer"},
+			"model":{"type":"WordPiece","unk_token":"[UNK]","continuing_subword_prefix":"##","vocab":{"[UNK]":0,"token":1,"##izer":2,"!":3}}
+		}`, "Tokenizer! xyz", []int{1, 2, 3, 0}},
+	}
+	for _, c := range cases {
+		tk, err := Parse([]byte(c.json))
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if got := tk.Encode(c.text); !reflect.DeepEqual(got, c.ids) {
+			t.Fatalf("%s: got %v, want %v", c.name, got, c.ids)
+		}
+		if tk.Count(c.text) != len(c.ids) {
+			t.Fatal(c.name, "count from cache differs")
+		}
+	}
+
+	if _, err := Parse([]byte(`{"model":{"type":"Unknown"}}`)); err == nil {
+		t.Fatal("unknown model should fail")
+	}
+}